@@ -58,6 +58,7 @@ func (chklst *Checklist) MakeReport() (anyFailed bool, report string) {
 	total := len(chklst.Checks)
 	passed := 0
 	failed := 0
+	warned := 0
 	other := 0
 	for _ = range chklst.Checks {
 		code := <-codes
@@ -66,6 +67,8 @@ func (chklst *Checklist) MakeReport() (anyFailed bool, report string) {
 			passed++
 		case 1:
 			failed++
+		case 2:
+			warned++
 		default:
 			other++
 		}
@@ -75,6 +78,7 @@ func (chklst *Checklist) MakeReport() (anyFailed bool, report string) {
 	report += "↴\nTotal: " + fmt.Sprint(total)
 	report += "\nPassed: " + fmt.Sprint(passed)
 	report += "\nFailed: " + fmt.Sprint(failed)
+	report += "\nWarnings: " + fmt.Sprint(warned)
 	report += "\nOther: " + fmt.Sprint(other)
 	// append specific check reports
 	for _ = range chklst.Checks {
@@ -95,6 +99,8 @@ type CheckYAML struct {
 	ID string `json:"id"`
 	// the parameters to the check. To be validated upon check construction.
 	Parameters []string `json:"parameters"`
+	// what to do when the check fails, only used with Remediate
+	Remediate *RemediationYAML `json:"remediate,omitempty"`
 }
 
 // ChecklistYAML is the representation of a checklist that's parsed from the
@@ -133,6 +139,16 @@ func FromBytes(data []byte) (chklst Checklist, err error) {
 				"error":  err.Error(),
 			}).Fatal("Error while constructing check")
 		}
+		if chkYAML.Remediate != nil {
+			chkStruct.remediation, err = NewRemediation(*chkYAML.Remediate)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"check":       chkYAML.ID,
+					"remediation": *chkYAML.Remediate,
+					"error":       err.Error(),
+				}).Fatal("Error while constructing remediation")
+			}
+		}
 		chklst.Checks = append(chklst.Checks, chkStruct)
 	}
 	if len(chklst.Checks) < 1 {
@@ -254,8 +270,9 @@ func FromURL(urlstr string, cache bool) (chklst Checklist, err error) {
 
 // Little unobtrusive wrapper to chkutils.Check to untie that bind us ;)
 type CheckWrapper struct {
-	wrapped chkutil.Check
-	yaml    *CheckYAML
+	wrapped     chkutil.Check
+	yaml        *CheckYAML
+	remediation *Remediation
 }
 
 func constructCheck(chkYAML CheckYAML) *CheckWrapper {
@@ -280,5 +297,9 @@ func (cw *CheckWrapper) New(parameters []string) (chkutil.Check, error) {
 }
 
 func (cw *CheckWrapper) Status() (code int, msg string, err error) {
-	return cw.wrapped.Status()
+	code, msg, err = cw.wrapped.Status()
+	if code == 1 && Remediate && cw.remediation != nil {
+		return cw.remediate(code, msg, err)
+	}
+	return code, msg, err
 }
@@ -0,0 +1,176 @@
+package checklists
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/errutil"
+	log "github.com/Sirupsen/logrus"
+)
+
+// Remediate controls whether failing checks are remediated, for those checks
+// that have a remediate block.
+var Remediate = false
+
+// DryRun makes remediation only report what it would have done.
+var DryRun = false
+
+// RemediationYAML is the optional remediate block of a check. Exactly one of
+// Command or Action should be given.
+type RemediationYAML struct {
+	// a shell command to run
+	Command string `json:"command"`
+	// a built-in action: restart-unit | create-directory | set-permissions
+	Action string `json:"action"`
+	// parameters to the built-in action
+	Parameters []string `json:"parameters"`
+}
+
+// Remediation is a validated, runnable RemediationYAML
+type Remediation struct {
+	Description string
+	run         func() error
+}
+
+// Run performs the remediation
+func (rem *Remediation) Run() error { return rem.run() }
+
+// parseMode reads a file mode in either octal (0755) or symbolic (-rwxr-xr-x)
+// notation
+func parseMode(str string) (os.FileMode, error) {
+	if regexp.MustCompile(`^-([r-][w-][x-]){3}$`).MatchString(str) {
+		var mode os.FileMode
+		for i, c := range str[1:] {
+			if c != '-' {
+				mode |= 1 << uint(8-i)
+			}
+		}
+		return mode, nil
+	}
+	mode, err := strconv.ParseUint(str, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, errutil.ParameterTypeError{str, "filemode"}
+	}
+	return os.FileMode(mode), nil
+}
+
+// runCommand runs a shell command, including its output in any error
+func runCommand(cmd *exec.Cmd) error {
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %s", cmd.Args, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// NewRemediation validates a RemediationYAML and prepares it to be run
+func NewRemediation(remYAML RemediationYAML) (*Remediation, error) {
+	params := remYAML.Parameters
+	switch {
+	case remYAML.Command != "" && remYAML.Action != "":
+		return nil, errors.New("Remediation has both a command and an action")
+	case remYAML.Command != "":
+		return &Remediation{
+			Description: "run `" + remYAML.Command + "`",
+			run: func() error {
+				return runCommand(exec.Command("bash", "-c", remYAML.Command))
+			},
+		}, nil
+	}
+	switch strings.ToLower(remYAML.Action) {
+	case "restart-unit":
+		if len(params) != 1 {
+			return nil, errutil.ParameterLengthError{1, params}
+		}
+		return &Remediation{
+			Description: "restart unit " + params[0],
+			run: func() error {
+				return runCommand(exec.Command("systemctl", "restart", params[0]))
+			},
+		}, nil
+	case "create-directory":
+		if len(params) != 1 && len(params) != 2 {
+			return nil, errutil.ParameterLengthError{2, params}
+		}
+		mode := os.FileMode(0755)
+		if len(params) == 2 {
+			var err error
+			if mode, err = parseMode(params[1]); err != nil {
+				return nil, err
+			}
+		}
+		return &Remediation{
+			Description: fmt.Sprintf("create directory %s (%s)", params[0], mode),
+			run: func() error {
+				if err := os.MkdirAll(params[0], mode); err != nil {
+					return err
+				}
+				// MkdirAll applies the umask, and leaves existing
+				// directories alone
+				return os.Chmod(params[0], mode)
+			},
+		}, nil
+	case "set-permissions":
+		if len(params) != 2 {
+			return nil, errutil.ParameterLengthError{2, params}
+		}
+		mode, err := parseMode(params[1])
+		if err != nil {
+			return nil, err
+		}
+		return &Remediation{
+			Description: fmt.Sprintf("set permissions of %s to %s", params[0], mode),
+			run:         func() error { return os.Chmod(params[0], mode) },
+		}, nil
+	case "":
+		return nil, errors.New("Remediation has neither a command nor an action")
+	}
+	return nil, errors.New("Unknown remediation action: " + remYAML.Action)
+}
+
+// statusString describes a check's status for the remediation report
+func statusString(code int, msg string, err error) string {
+	status := "failing"
+	switch code {
+	case 0:
+		status = "passing"
+	case 2:
+		status = "warning"
+	}
+	if err != nil {
+		status += " (error: " + err.Error() + ")"
+	}
+	if msg != "" {
+		status += ": " + strings.Replace(msg, "\n", "\n\t\t", -1)
+	}
+	return status
+}
+
+// remediate runs the check's remediation after it has failed, and re-runs the
+// check. The report includes the status from before and after remediating.
+func (cw *CheckWrapper) remediate(code int, msg string, err error) (int, string, error) {
+	report := "Remediation for " + cw.ID() + ":"
+	report += "\n\tBefore: " + statusString(code, msg, err)
+	if DryRun {
+		report += "\n\tWould remediate: " + cw.remediation.Description
+		return code, report, err
+	}
+	log.WithFields(log.Fields{
+		"check":       cw.ID(),
+		"remediation": cw.remediation.Description,
+	}).Info("Remediating failed check")
+	if remErr := cw.remediation.Run(); remErr != nil {
+		report += "\n\tRemediation failed: " + cw.remediation.Description
+		report += "\n\tError: " + remErr.Error()
+		return code, report, err
+	}
+	report += "\n\tRemediated: " + cw.remediation.Description
+	code, msg, err = cw.wrapped.Status()
+	report += "\n\tAfter: " + statusString(code, msg, err)
+	return code, report, err
+}
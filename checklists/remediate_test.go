@@ -0,0 +1,141 @@
+package checklists
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+)
+
+// testDirExists is a minimal check, so that these tests don't depend on the
+// checks package
+type testDirExists struct{ path string }
+
+func (chk testDirExists) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.path = params[0]
+	return chk, nil
+}
+
+func (chk testDirExists) Status() (int, string, error) {
+	if _, err := os.Stat(chk.path); err != nil {
+		return 1, "No such directory: " + chk.path, nil
+	}
+	return errutil.Success()
+}
+
+func init() {
+	chkutil.Register("TestDirExists", func() chkutil.Check {
+		return &testDirExists{}
+	})
+}
+
+func TestNewRemediation(t *testing.T) {
+	t.Parallel()
+	valid := []RemediationYAML{
+		{Command: "true"},
+		{Action: "restart-unit", Parameters: []string{"nginx.service"}},
+		{Action: "create-directory", Parameters: []string{"/tmp/dir"}},
+		{Action: "create-directory", Parameters: []string{"/tmp/dir", "0700"}},
+		{Action: "set-permissions", Parameters: []string{"/tmp/f", "-rw-r-----"}},
+	}
+	invalid := []RemediationYAML{
+		{},
+		{Command: "true", Action: "restart-unit"},
+		{Action: "reboot"},
+		{Action: "restart-unit"},
+		{Action: "create-directory", Parameters: []string{"/tmp/dir", "rwx"}},
+		{Action: "set-permissions", Parameters: []string{"/tmp/f", "0999"}},
+	}
+	for _, remYAML := range valid {
+		if _, err := NewRemediation(remYAML); err != nil {
+			t.Errorf("NewRemediation failed on %v: %v", remYAML, err)
+		}
+	}
+	for _, remYAML := range invalid {
+		if _, err := NewRemediation(remYAML); err == nil {
+			t.Errorf("NewRemediation accepted %v", remYAML)
+		}
+	}
+}
+
+func TestCreateDirectoryMode(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "distributive-remediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	created, existing := filepath.Join(dir, "created"), filepath.Join(dir, "existing")
+	if err := os.Mkdir(existing, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{created, existing} {
+		rem, err := NewRemediation(RemediationYAML{Action: "create-directory",
+			Parameters: []string{path, "0777"}})
+		if err != nil {
+			t.Fatal(err)
+		} else if err := rem.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0777 {
+			t.Errorf("create-directory didn't set the mode of %s: %v, %v", path, info, err)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+	for str, expected := range map[string]os.FileMode{
+		"0755": 0755, "644": 0644, "-rwxr-x---": 0750, "-r--r--r--": 0444,
+	} {
+		if actual, err := parseMode(str); err != nil || actual != expected {
+			t.Errorf("parseMode(%v) = %v, %v, expected %v", str, actual, err, expected)
+		}
+	}
+}
+
+func TestRemediate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distributive-remediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "created")
+	chklst, err := FromBytes([]byte(`
+name: remediation
+checklist:
+  - id: TestDirExists
+    parameters: [ "` + path + `" ]
+    remediate:
+      action: create-directory
+      parameters: [ "` + path + `" ]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chk := chklst.Checks[0]
+
+	defer func() { Remediate, DryRun = false, false }()
+	Remediate, DryRun = true, true
+	code, msg, _ := chk.Status()
+	if code == 0 || !strings.Contains(msg, "Would remediate") {
+		t.Errorf("Dry run reported unexpectedly: %v, %v", code, msg)
+	} else if _, err := os.Stat(path); err == nil {
+		t.Error("Dry run created the directory")
+	}
+
+	DryRun = false
+	code, msg, _ = chk.Status()
+	if code != 0 || !strings.Contains(msg, "After: passing") {
+		t.Errorf("Remediation reported unexpectedly: %v, %v", code, msg)
+	} else if _, err := os.Stat(path); err != nil {
+		t.Error("Remediation didn't create the directory")
+	}
+}
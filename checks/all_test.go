@@ -193,3 +193,37 @@ func testCheck(goodEggs [][]string, badEggs [][]string, chk chkutil.Check, t *te
 		}
 	}
 }
+
+// testStatus checks that status returns the expected code, and a
+// message only on failure
+func testStatus(t *testing.T, params []string, expected int,
+	status func() (int, string, error)) {
+	code, msg, err := status()
+	if err != nil {
+		t.Errorf("Unexpected error for %v: %s", params, err)
+	} else if code != expected {
+		t.Errorf("Expected code %d for %v, got %d: %s", expected, params, code, msg)
+	} else if (code == 0) != (msg == "") {
+		t.Errorf("Unexpected message for %v: %q", params, msg)
+	}
+}
+
+// testStatuses builds a check from each set of parameters with newCheck, and
+// checks that status, which runs it, e.g. against parsed state rather than the
+// host, returns the code that the parameters are listed under
+func testStatuses(t *testing.T, statuses map[int][][]string,
+	newCheck func([]string) (chkutil.Check, error),
+	status func(chkutil.Check) (int, string, error)) {
+	for expected, inputs := range statuses {
+		for _, params := range inputs {
+			chk, err := newCheck(params)
+			if err != nil {
+				t.Errorf("Couldn't create check with %v: %s", params, err)
+				continue
+			}
+			testStatus(t, params, expected, func() (int, string, error) {
+				return status(chk)
+			})
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+	"github.com/CiscoCloud/distributive/tabular"
+)
+
+func init() {
+	chkutil.Register("DNSRecord", func() chkutil.Check {
+		return &DNSRecord{}
+	})
+	chkutil.Register("DNSSRVTargets", func() chkutil.Check {
+		return &DNSSRVTargets{}
+	})
+}
+
+// defaultDNSTimeout is how long DNS checks wait for a response
+const defaultDNSTimeout = 5 * time.Second
+
+// dnsValues lists the values of records
+func dnsValues(records []netstatus.DNSRecord) (values []string) {
+	for _, record := range records {
+		values = append(values, record.Value)
+	}
+	return values
+}
+
+/*
+#### DNSRecord
+Description: Does this name have records of this type, that meet these
+expectations? Every parameter after the type is an option, written as
+name=value:
+- server: Nameserver to ask, as host or host:port. Defaults to the first
+  nameserver in /etc/resolv.conf.
+- value: Value that one of the records must have (may be repeated). SRV
+  records are "priority weight port target" and MX records are
+  "preference host", without trailing dots.
+- matches: Regexp that every record's value must match
+- min-answers: Fewest records allowed, 1 by default
+- min-ttl, max-ttl: Range that every record's TTL must be in, in seconds
+- max-time: Longest the server may take to respond, e.g. 100ms
+Parameters:
+- Name (string): Name to look up. For PTR records, this may be an IP address.
+- Type (string): A | AAAA | CNAME | SRV | TXT | MX | PTR
+- Options (name=value, optional, any number)
+Example parameters:
+- example.com, _ldap._tcp.example.com, 10.0.0.5
+- A, SRV, PTR
+- server=10.0.0.2, value=93.184.216.34, "matches=^10\.", min-ttl=60
+*/
+
+type DNSRecord struct {
+	name, recordType string
+	server           string
+	values           []string
+	re               *regexp.Regexp
+	minAnswers       int
+	minTTL, maxTTL   int64
+	maxTime          time.Duration
+}
+
+// parseOption parses one name=value option into chk
+func (chk *DNSRecord) parseOption(option string) error {
+	i := strings.Index(option, "=")
+	if i < 1 {
+		return errutil.ParameterTypeError{option, "name=value"}
+	}
+	name, value := option[:i], option[i+1:]
+	invalid := errutil.ParameterTypeError{option, name + " option"}
+	var err error
+	switch name {
+	case "server":
+		chk.server = value
+	case "value":
+		chk.values = append(chk.values, value)
+	case "matches":
+		if chk.re, err = regexp.Compile(value); err != nil {
+			return invalid
+		}
+	case "min-answers":
+		if chk.minAnswers, err = strconv.Atoi(value); err != nil || chk.minAnswers < 0 {
+			return invalid
+		}
+	case "min-ttl":
+		if chk.minTTL, err = strconv.ParseInt(value, 10, 64); err != nil || chk.minTTL < 0 {
+			return invalid
+		}
+	case "max-ttl":
+		if chk.maxTTL, err = strconv.ParseInt(value, 10, 64); err != nil || chk.maxTTL < 0 {
+			return invalid
+		}
+	case "max-time":
+		if chk.maxTime, err = time.ParseDuration(value); err != nil || chk.maxTime <= 0 {
+			return invalid
+		}
+	default:
+		return errutil.ParameterTypeError{option, "DNSRecord option"}
+	}
+	return nil
+}
+
+func (chk DNSRecord) New(params []string) (chkutil.Check, error) {
+	if len(params) < 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	recordType := strings.ToUpper(params[1])
+	if !tabular.StrIn(recordType, netstatus.DNSRecordTypes) {
+		validTypes := strings.Join(netstatus.DNSRecordTypes, " | ")
+		return chk, errutil.ParameterTypeError{params[1], validTypes}
+	}
+	chk = DNSRecord{name: params[0], recordType: recordType, minAnswers: 1, maxTTL: -1}
+	for _, option := range params[2:] {
+		if err := chk.parseOption(option); err != nil {
+			return chk, err
+		}
+	}
+	return chk, nil
+}
+
+func (chk DNSRecord) Status() (int, string, error) {
+	timeout := defaultDNSTimeout
+	if chk.maxTime > 0 {
+		timeout = chk.maxTime
+	}
+	records, rtt, err := netstatus.QueryDNS(chk.server, chk.name, chk.recordType, timeout)
+	if err != nil {
+		return 1, "DNS query failed: " + err.Error(), nil
+	}
+	values := dnsValues(records)
+	if len(records) < chk.minAnswers {
+		msg := fmt.Sprintf("Too few %s records for %s", chk.recordType, chk.name)
+		return errutil.GenericError(msg, "at least "+strconv.Itoa(chk.minAnswers), values)
+	}
+	if chk.maxTime > 0 && rtt > chk.maxTime {
+		msg := "DNS server was too slow to respond"
+		return errutil.GenericError(msg, chk.maxTime, []string{rtt.String()})
+	}
+	for _, expected := range chk.values {
+		if !tabular.StrIn(expected, values) {
+			msg := fmt.Sprintf("%s records for %s didn't include value", chk.recordType, chk.name)
+			return errutil.GenericError(msg, expected, values)
+		}
+	}
+	for _, record := range records {
+		if chk.re != nil && !chk.re.MatchString(record.Value) {
+			msg := fmt.Sprintf("%s record for %s didn't match", chk.recordType, chk.name)
+			return errutil.GenericError(msg, chk.re.String(), []string{record.Value})
+		}
+		ttl := int64(record.TTL)
+		if ttl < chk.minTTL || (chk.maxTTL >= 0 && ttl > chk.maxTTL) {
+			msg := fmt.Sprintf("TTL of %s record for %s was out of range",
+				chk.recordType, chk.name)
+			specified := fmt.Sprintf("%d-%d", chk.minTTL, chk.maxTTL)
+			if chk.maxTTL < 0 {
+				specified = fmt.Sprintf("at least %d", chk.minTTL)
+			}
+			return errutil.GenericError(msg, specified, []string{fmt.Sprint(ttl)})
+		}
+	}
+	return errutil.Success()
+}
+
+/*
+#### DNSSRVTargets
+Description: Does this SRV name have at least this many distinct targets? This
+is meant for service discovery, e.g. Consul's DNS interface only returns
+healthy instances of web.service.consul.
+Parameters:
+- Name (string): SRV name to look up
+- Minimum (positive int): Fewest distinct target:port pairs allowed
+- Server (host or host:port, optional): Nameserver to ask, defaults to the first
+  nameserver in /etc/resolv.conf
+Example parameters:
+- web.service.consul, _http._tcp.example.com
+- 1, 3
+- 127.0.0.1:8600
+*/
+
+type DNSSRVTargets struct {
+	name, server string
+	min          int
+}
+
+func (chk DNSSRVTargets) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 && len(params) != 3 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	min, err := strconv.Atoi(params[1])
+	if err != nil || min < 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.name = params[0]
+	chk.min = min
+	if len(params) == 3 {
+		chk.server = params[2]
+	}
+	return chk, nil
+}
+
+func (chk DNSSRVTargets) Status() (int, string, error) {
+	records, _, err := netstatus.QueryDNS(chk.server, chk.name, "SRV", defaultDNSTimeout)
+	if err != nil {
+		return 1, "DNS query failed: " + err.Error(), nil
+	}
+	var targets []string
+	for _, record := range records {
+		// "priority weight port target"
+		fields := strings.Fields(record.Value)
+		if len(fields) != 4 {
+			continue
+		}
+		target := fields[3] + ":" + fields[2]
+		if !tabular.StrIn(target, targets) {
+			targets = append(targets, target)
+		}
+	}
+	if len(targets) >= chk.min {
+		return errutil.Success()
+	}
+	msg := "Too few SRV targets for " + chk.name
+	return errutil.GenericError(msg, "at least "+strconv.Itoa(chk.min), targets)
+}
@@ -0,0 +1,154 @@
+package checks
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// testZone is served by startDNSServer, keyed by question name and type
+var testZone = map[string][]string{
+	"example.test. A": {
+		"example.test. 300 IN A 192.0.2.1", "example.test. 300 IN A 192.0.2.2",
+	},
+	"example.test. AAAA":          {"example.test. 300 IN AAAA 2001:db8::1"},
+	"example.test. TXT":           {`example.test. 3600 IN TXT "v=spf1 -all"`},
+	"example.test. MX":            {"example.test. 3600 IN MX 10 mail.example.test."},
+	"www.example.test. A":         {"www.example.test. 60 IN CNAME example.test.", "example.test. 300 IN A 192.0.2.1"},
+	"www.example.test. CNAME":     {"www.example.test. 60 IN CNAME example.test."},
+	"1.2.0.192.in-addr.arpa. PTR": {"1.2.0.192.in-addr.arpa. 300 IN PTR example.test."},
+	"web.service.consul. SRV": {
+		"web.service.consul. 0 IN SRV 1 1 8080 node1.node.consul.",
+		"web.service.consul. 0 IN SRV 1 1 8080 node2.node.consul.",
+		"web.service.consul. 0 IN SRV 1 1 8080 node1.node.consul.",
+	},
+	"big.service.consul. SRV": {
+		"big.service.consul. 0 IN SRV 1 1 8080 node1.node.consul.",
+		"big.service.consul. 0 IN SRV 1 1 8080 node2.node.consul.",
+		"big.service.consul. 0 IN SRV 1 1 8080 node3.node.consul.",
+		"big.service.consul. 0 IN SRV 1 1 8080 node4.node.consul.",
+		"big.service.consul. 0 IN SRV 1 1 8080 node5.node.consul.",
+	},
+}
+
+// truncatedAnswers is how many records the test server answers with over UDP
+// for names starting with "big.", like Consul's udp_answer_limit
+const truncatedAnswers = 3
+
+// startDNSServer serves testZone over UDP and TCP on localhost, and returns
+// its address
+func startDNSServer(t *testing.T) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		resp := new(dns.Msg)
+		resp.SetReply(req)
+		question := req.Question[0]
+		if strings.HasPrefix(question.Name, "slow.") {
+			time.Sleep(200 * time.Millisecond)
+		}
+		key := question.Name + " " + dns.TypeToString[question.Qtype]
+		records, ok := testZone[key]
+		if !ok {
+			resp.SetRcode(req, dns.RcodeNameError)
+		}
+		for _, record := range records {
+			rr, err := dns.NewRR(record)
+			if err != nil {
+				t.Error(err)
+			}
+			resp.Answer = append(resp.Answer, rr)
+		}
+		_, udp := w.RemoteAddr().(*net.UDPAddr)
+		if udp && strings.HasPrefix(question.Name, "big.") {
+			resp.Answer = resp.Answer[:truncatedAnswers]
+			resp.Truncated = true
+		}
+		w.WriteMsg(resp)
+	})
+	var servers []*dns.Server
+	for _, server := range []*dns.Server{{PacketConn: conn}, {Listener: listener}} {
+		started := make(chan struct{})
+		server.Handler = handler
+		server.NotifyStartedFunc = func() { close(started) }
+		go server.ActivateAndServe()
+		<-started
+		servers = append(servers, server)
+	}
+	return conn.LocalAddr().String(), func() {
+		for _, server := range servers {
+			server.Shutdown()
+		}
+	}
+}
+
+func TestDNSRecord(t *testing.T) {
+	t.Parallel()
+	server, stop := startDNSServer(t)
+	defer stop()
+	s := "server=" + server
+
+	validInputs := [][]string{
+		{"example.com", "A"}, {"example.com", "aaaa", "server=10.0.0.2"},
+		{"example.com", "SRV", "value=1 1 80 web", "matches=^1 ", "min-answers=2"},
+		{"example.com", "TXT", "min-ttl=60", "max-ttl=3600", "max-time=100ms"},
+	}
+	invalidInputs := [][]string{
+		{}, {"example.com"}, {"example.com", "SOA"},
+		{"example.com", "A", "value"}, {"example.com", "A", "matches=(unclosed"},
+		{"example.com", "A", "min-answers=some"}, {"example.com", "A", "min-ttl=-1"},
+		{"example.com", "A", "max-time=fast"}, {"example.com", "A", "colour=blue"},
+	}
+	testParameters(validInputs, invalidInputs, DNSRecord{}, t)
+
+	goodEggs := [][]string{
+		{"example.test", "A", s},
+		{"example.test", "A", s, "value=192.0.2.2", "min-answers=2", "matches=^192\\.0\\.2\\."},
+		{"example.test", "AAAA", s, "value=2001:db8::1"},
+		{"www.example.test", "A", s, "value=192.0.2.1", "min-answers=1"},
+		{"www.example.test", "CNAME", s, "value=example.test"},
+		{"example.test", "TXT", s, "value=v=spf1 -all", "min-ttl=3600"},
+		{"example.test", "MX", s, "value=10 mail.example.test"},
+		{"192.0.2.1", "PTR", s, "value=example.test"},
+		{"web.service.consul", "SRV", s, "value=1 1 8080 node2.node.consul", "max-ttl=0"},
+		{"example.test", "A", s, "min-ttl=60", "max-ttl=300", "max-time=1s"},
+	}
+	badEggs := [][]string{
+		{"missing.example.test", "A", s},
+		{"example.test", "A", s, "min-answers=3"},
+		{"example.test", "A", s, "value=192.0.2.3"},
+		{"example.test", "A", s, "matches=^192\\.0\\.2\\.1$"},
+		{"example.test", "A", s, "min-ttl=301"},
+		{"example.test", "A", s, "max-ttl=299"},
+		{"example.test", "SRV", s},
+		{"slow.example.test", "A", s, "max-time=50ms", "min-answers=0"},
+	}
+	testCheck(goodEggs, badEggs, DNSRecord{}, t)
+}
+
+func TestDNSSRVTargets(t *testing.T) {
+	t.Parallel()
+	server, stop := startDNSServer(t)
+	defer stop()
+	validInputs := [][]string{{"web.service.consul", "1"}, {"web", "3", "127.0.0.1:8600"}}
+	invalidInputs := [][]string{{}, {"web"}, {"web", "many"}, {"web", "-1"}, {"a", "1", "b", "c"}}
+	testParameters(validInputs, invalidInputs, DNSSRVTargets{}, t)
+	goodEggs := [][]string{
+		{"web.service.consul", "1", server}, {"web.service.consul", "2", server},
+		// only complete over TCP
+		{"big.service.consul", "5", server},
+	}
+	badEggs := [][]string{
+		{"web.service.consul", "3", server}, {"db.service.consul", "1", server},
+	}
+	testCheck(goodEggs, badEggs, DNSSRVTargets{}, t)
+}
@@ -48,6 +48,9 @@ func (chk DockerImage) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk DockerImage) Status() (int, string, error) {
+	if err := chkutil.RequireHost("DockerImage"); err != nil {
+		return 1, "", err
+	}
 	images, err := dockerstatus.DockerImageRepositories()
 	if err != nil {
 		return 1, "", err
@@ -81,6 +84,9 @@ func (chk DockerImageRegexp) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk DockerImageRegexp) Status() (int, string, error) {
+	if err := chkutil.RequireHost("DockerImageRegexp"); err != nil {
+		return 1, "", err
+	}
 	images, err := dockerstatus.DockerImageRepositories()
 	if err != nil {
 		return 1, "", err
@@ -114,6 +120,9 @@ func (chk DockerRunning) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk DockerRunning) Status() (int, string, error) {
+	if err := chkutil.RequireHost("DockerRunning"); err != nil {
+		return 1, "", err
+	}
 	running, err := dockerstatus.RunningContainers()
 	if err != nil {
 		return 1, "", err
@@ -168,7 +177,7 @@ func (chk DockerRunningAPI) New(params []string) (chkutil.Check, error) {
 		return chk, errutil.ParameterLengthError{2, params}
 	}
 	path := params[0]
-	if _, err := os.Stat(path); err != nil {
+	if _, err := os.Stat(chkutil.HostPath(path)); err != nil {
 		return chk, errutil.ParameterTypeError{path, "filepath"}
 	}
 	chk.path = path
@@ -177,7 +186,7 @@ func (chk DockerRunningAPI) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk DockerRunningAPI) Status() (int, string, error) {
-	running := getRunningContainersAPI(chk.path)
+	running := getRunningContainersAPI(chkutil.HostPath(chk.path))
 	if tabular.StrContainedIn(chk.name, running) {
 		return errutil.Success()
 	}
@@ -209,6 +218,9 @@ func (chk DockerRunningRegexp) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk DockerRunningRegexp) Status() (int, string, error) {
+	if err := chkutil.RequireHost("DockerRunningRegexp"); err != nil {
+		return 1, "", err
+	}
 	running, err := dockerstatus.RunningContainers()
 	if err != nil {
 		return 1, "", err
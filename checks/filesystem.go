@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/CiscoCloud/distributive/chkutil"
@@ -18,7 +19,7 @@ type fileCondition func(path string) (bool, error)
 // isType checks if the resource at path is of the type specified by name by
 // passing path to checker. Mostly used to abstract Directory, File, Symlink.
 func isType(name string, checker fileCondition, path string) (int, string, error) {
-	boo, err := checker(path)
+	boo, err := checker(chkutil.HostLinkPath(path))
 	if os.IsNotExist(err) {
 		return 1, "No such file or directory: " + path, nil
 	} else if os.IsPermission(err) {
@@ -59,6 +60,21 @@ func init() {
     chkutil.Register("FileMatches", func() chkutil.Check {
         return &FileMatches{}
     })
+    chkutil.Register("Mount", func() chkutil.Check {
+        return &Mount{}
+    })
+    chkutil.Register("TLSCertFileExpiry", func() chkutil.Check {
+        return &TLSCertFileExpiry{}
+    })
+    chkutil.Register("TLSCertFileKey", func() chkutil.Check {
+        return &TLSCertFileKey{}
+    })
+    chkutil.Register("TLSCertFileHostname", func() chkutil.Check {
+        return &TLSCertFileHostname{}
+    })
+    chkutil.Register("TLSCertFileIssuer", func() chkutil.Check {
+        return &TLSCertFileIssuer{}
+    })
 }
 
 func (chk File) New(params []string) (chkutil.Check, error) {
@@ -155,7 +171,8 @@ func (chk Checksum) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk Checksum) Status() (int, string, error) {
-	if _, err := os.Stat(chk.path); err != nil {
+	path := chkutil.HostPath(chk.path)
+	if _, err := os.Stat(path); err != nil {
 		return 2, "", err
 	}
 
@@ -163,7 +180,7 @@ func (chk Checksum) Status() (int, string, error) {
 	fileChecksum := func(algorithm string, path string) string {
 		if path == "" {
 			log.Fatal("getFileChecksum got a blank path")
-		} else if _, err := os.Stat(chk.path); err != nil {
+		} else if _, err := os.Stat(path); err != nil {
 			log.WithFields(log.Fields{
 				"path": chk.path,
 			}).Fatal("fileChecksum got an invalid path")
@@ -172,7 +189,7 @@ func (chk Checksum) Status() (int, string, error) {
 		chksum, _ := fsstatus.Checksum(algorithm, chkutil.FileToBytes(path))
 		return chksum
 	}
-	actualChksum := fileChecksum(chk.algorithm, chk.path)
+	actualChksum := fileChecksum(chk.algorithm, path)
 	if actualChksum == chk.expectedChksum {
 		return errutil.Success()
 	}
@@ -211,10 +228,11 @@ func (chk FileMatches) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk FileMatches) Status() (int, string, error) {
-	if _, err := os.Stat(chk.path); err != nil {
+	path := chkutil.HostPath(chk.path)
+	if _, err := os.Stat(path); err != nil {
 		return 2, "", err
 	}
-	if chk.re.Match(chkutil.FileToBytes(chk.path)) {
+	if chk.re.Match(chkutil.FileToBytes(path)) {
 		return errutil.Success()
 	}
 	msg := "File does not match regexp:"
@@ -251,10 +269,11 @@ func (chk Permissions) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk Permissions) Status() (int, string, error) {
-	if _, err := os.Stat(chk.path); err != nil {
+	path := chkutil.HostPath(chk.path)
+	if _, err := os.Stat(path); err != nil {
 		return 1, "", err
 	}
-	passed, err := fsstatus.FileHasPermissions(chk.expectedPerms, chk.path)
+	passed, err := fsstatus.FileHasPermissions(chk.expectedPerms, path)
 	if err != nil {
 		return 1, "", err
 	}
@@ -263,3 +282,211 @@ func (chk Permissions) Status() (int, string, error) {
 	}
 	return 1, "File did not have permissions: " + chk.expectedPerms, nil
 }
+
+/*
+#### Mount
+Description: Is a filesystem mounted at this path, optionally of this type?
+Parameters:
+- Path (filepath): Mountpoint
+- Type (string, optional): Filesystem type
+Example parameters:
+- /, /boot, /var/lib/docker
+- ext4, xfs, tmpfs
+Dependencies:
+- /proc/self/mounts
+*/
+
+type Mount struct{ path, fstype string }
+
+func (chk Mount) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 && len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	chk.path = params[0]
+	if len(params) == 2 {
+		chk.fstype = params[1]
+	}
+	return chk, nil
+}
+
+func (chk Mount) Status() (int, string, error) {
+	mounts, err := fsstatus.Mounts()
+	if err != nil {
+		return 1, "", err
+	}
+	var mountpoints, types []string
+	for _, mount := range mounts {
+		if mount.Mountpoint == chk.path {
+			if chk.fstype == "" || mount.FSType == chk.fstype {
+				return errutil.Success()
+			}
+			types = append(types, mount.FSType)
+		}
+		mountpoints = append(mountpoints, mount.Mountpoint)
+	}
+	if len(types) > 0 {
+		msg := "Filesystem at " + chk.path + " had unexpected type"
+		return errutil.GenericError(msg, chk.fstype, types)
+	}
+	return errutil.GenericError("Nothing mounted at path", chk.path, mountpoints)
+}
+
+/*
+#### TLSCertFileExpiry
+Description: Will the certificates in this file stay valid for at least this
+many days? Every certificate in a bundle is checked.
+Parameters:
+- Path (filepath): Path to a PEM or DER certificate file
+- Days (positive int): Fewest days the certificates may have left
+Example parameters:
+- /etc/ssl/certs/example.com.pem, /etc/pki/tls/certs/server.crt
+- 14, 30
+*/
+
+type TLSCertFileExpiry struct {
+	path string
+	days int
+}
+
+func (chk TLSCertFileExpiry) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	days, err := strconv.Atoi(params[1])
+	if err != nil || days < 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.path = params[0]
+	chk.days = days
+	return chk, nil
+}
+
+func (chk TLSCertFileExpiry) Status() (int, string, error) {
+	certs, err := fsstatus.ReadCertificates(chkutil.HostPath(chk.path))
+	if err != nil {
+		return 1, "", err
+	}
+	return certExpiryStatus(certs, chk.days)
+}
+
+/*
+#### TLSCertFileKey
+Description: Is this the private key for the (first) certificate in this file?
+Parameters:
+- Certificate (filepath): Path to a PEM or DER certificate file
+- Key (filepath): Path to a PEM or DER private key (PKCS #1, PKCS #8 or EC)
+Example parameters:
+- /etc/pki/tls/certs/server.crt
+- /etc/pki/tls/private/server.key
+*/
+
+type TLSCertFileKey struct{ certPath, keyPath string }
+
+func (chk TLSCertFileKey) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	chk.certPath = params[0]
+	chk.keyPath = params[1]
+	return chk, nil
+}
+
+func (chk TLSCertFileKey) Status() (int, string, error) {
+	certs, err := fsstatus.ReadCertificates(chkutil.HostPath(chk.certPath))
+	if err != nil {
+		return 1, "", err
+	}
+	key, err := fsstatus.ReadPrivateKey(chkutil.HostPath(chk.keyPath))
+	if err != nil {
+		return 1, "", err
+	}
+	matches, err := fsstatus.KeyMatches(certs[0], key)
+	if err != nil {
+		return 1, "", err
+	} else if matches {
+		return errutil.Success()
+	}
+	return 1, "Private key doesn't match certificate: " + chk.keyPath, nil
+}
+
+/*
+#### TLSCertFileHostname
+Description: Is the (first) certificate in this file valid for this hostname,
+according to its subject alternative names?
+Parameters:
+- Path (filepath): Path to a PEM or DER certificate file
+- Hostname (string): Hostname or IP address
+Example parameters:
+- /etc/pki/tls/certs/server.crt
+- example.com, www.example.com, 10.0.0.5
+*/
+
+type TLSCertFileHostname struct{ path, hostname string }
+
+func (chk TLSCertFileHostname) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	chk.path = params[0]
+	chk.hostname = params[1]
+	return chk, nil
+}
+
+func (chk TLSCertFileHostname) Status() (int, string, error) {
+	certs, err := fsstatus.ReadCertificates(chkutil.HostPath(chk.path))
+	if err != nil {
+		return 1, "", err
+	}
+	if err := certs[0].VerifyHostname(chk.hostname); err == nil {
+		return errutil.Success()
+	}
+	names := certs[0].DNSNames
+	for _, ip := range certs[0].IPAddresses {
+		names = append(names, ip.String())
+	}
+	msg := "Certificate isn't valid for hostname"
+	return errutil.GenericError(msg, chk.hostname, names)
+}
+
+/*
+#### TLSCertFileIssuer
+Description: Does the issuer of the (first) certificate in this file match
+this regexp? The issuer is written like "CN=Example CA,O=Example Inc,C=US".
+Parameters:
+- Path (filepath): Path to a PEM or DER certificate file
+- Regexp (regexp): Regexp to match the issuer's distinguished name with
+Example parameters:
+- /etc/pki/tls/certs/server.crt
+- "CN=Let's Encrypt Authority", "O=Example Inc"
+*/
+
+type TLSCertFileIssuer struct {
+	path string
+	re   *regexp.Regexp
+}
+
+func (chk TLSCertFileIssuer) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	re, err := regexp.Compile(params[1])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[1], "regexp"}
+	}
+	chk.path = params[0]
+	chk.re = re
+	return chk, nil
+}
+
+func (chk TLSCertFileIssuer) Status() (int, string, error) {
+	certs, err := fsstatus.ReadCertificates(chkutil.HostPath(chk.path))
+	if err != nil {
+		return 1, "", err
+	}
+	issuer := certs[0].Issuer.String()
+	if chk.re.MatchString(issuer) {
+		return errutil.Success()
+	}
+	msg := "Certificate issuer didn't match"
+	return errutil.GenericError(msg, chk.re.String(), []string{issuer})
+}
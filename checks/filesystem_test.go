@@ -1,6 +1,7 @@
 package checks
 
 import (
+	"os"
 	"testing"
 )
 
@@ -116,3 +117,57 @@ func TestPermissions(t *testing.T) {
 	testParameters(validInputs, invalidInputs, Permissions{}, t)
 	testCheck(goodEggs, badEggs, Permissions{}, t)
 }
+
+// $1 - path, $2 - fstype (optional)
+func TestMount(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"/"}, {"/proc", "proc"}, {"/boot", "ext4"}}
+	invalidInputs := [][]string{{}, {"/", "ext4", "extra"}}
+	goodEggs := [][]string{{"/"}, {"/proc", "proc"}}
+	badEggs := [][]string{{"/proc", "ext4"}, {"/not/a/mountpoint"}}
+	testParameters(validInputs, invalidInputs, Mount{}, t)
+	testCheck(goodEggs, badEggs, Mount{}, t)
+}
+
+func TestTLSCertFiles(t *testing.T) {
+	t.Parallel()
+	certs := writeTestCerts(t)
+	defer os.RemoveAll(certs.dir)
+	missing := certs.dir + "/missing.pem"
+
+	testParameters([][]string{{certs.certFile, "10"}}, append(notLengthTwo,
+		[]string{certs.certFile, "ten"}), TLSCertFileExpiry{}, t)
+	testCheck([][]string{
+		{certs.certFile, "10"}, {certs.derFile, "29"}, {certs.caFile, "300"},
+	}, [][]string{
+		{certs.certFile, "31"}, {certs.caFile, "400"},
+	}, TLSCertFileExpiry{}, t)
+
+	testParameters([][]string{{certs.certFile, certs.keyFile}}, notLengthTwo,
+		TLSCertFileKey{}, t)
+	testCheck([][]string{
+		{certs.certFile, certs.keyFile}, {certs.derFile, certs.keyFile},
+	}, [][]string{
+		{certs.certFile, certs.otherKeyFile},
+	}, TLSCertFileKey{}, t)
+	if code, _, err := (TLSCertFileKey{certs.certFile, missing}).Status(); code == 0 || err == nil {
+		t.Error("TLSCertFileKey didn't fail on a missing key")
+	}
+
+	testParameters([][]string{{certs.certFile, "localhost"}}, notLengthTwo,
+		TLSCertFileHostname{}, t)
+	testCheck([][]string{
+		{certs.certFile, "example.test"}, {certs.derFile, "localhost"},
+		{certs.certFile, "127.0.0.1"},
+	}, [][]string{
+		{certs.certFile, "example.com"}, {certs.certFile, "10.0.0.1"},
+	}, TLSCertFileHostname{}, t)
+
+	testParameters([][]string{{certs.certFile, "CN=Distributive"}}, append(notLengthTwo,
+		[]string{certs.certFile, "(CN"}), TLSCertFileIssuer{}, t)
+	testCheck([][]string{
+		{certs.certFile, "CN=Distributive Test CA"}, {certs.derFile, "O=Distributive"},
+	}, [][]string{
+		{certs.certFile, "Let's Encrypt"},
+	}, TLSCertFileIssuer{}, t)
+}
@@ -0,0 +1,358 @@
+package checks
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+func init() {
+	chkutil.Register("FirewallRule", func() chkutil.Check {
+		return &FirewallRule{}
+	})
+	chkutil.Register("FirewallPolicy", func() chkutil.Check {
+		return &FirewallPolicy{}
+	})
+	chkutil.Register("FirewallPortOnlyFrom", func() chkutil.Check {
+		return &FirewallPortOnlyFrom{}
+	})
+}
+
+// ruleTexts lists the text of each rule, for messages
+func ruleTexts(rules []netstatus.FirewallRule) (texts []string) {
+	for _, rule := range rules {
+		texts = append(texts, rule.Text)
+	}
+	return texts
+}
+
+/*
+#### FirewallRule
+Description: Is there a firewall rule that matches all of these options? Rules
+are read from `nft -j list ruleset`, or from iptables-save and ip6tables-save
+if nft isn't installed or doesn't support JSON. Options are written as
+name=value, and names of tables, chains, targets and protocols are matched case
+insensitively, so that chain=input matches both iptables' INPUT and a typical
+nftables input chain.
+- table: Table the rule is in, e.g. filter
+- chain: Chain the rule is in, e.g. INPUT
+- target: What the rule does, e.g. ACCEPT, DROP, REJECT, or a chain it jumps to
+- protocol: Protocol the rule matches, e.g. tcp
+- port: Destination port the rule matches, by itself or in a list or range
+- source: Source address or CIDR the rule matches, e.g. 10.0.0.0/8, or with a
+  ! prefix, the address it excludes. For nftables rules that match a set of
+  addresses, this may be any one of them, or a named set like @trusted.
+- interface: Input interface the rule matches, e.g. lo
+Parameters:
+- Options (name=value, at least one)
+Example parameters:
+- chain=INPUT, target=ACCEPT, protocol=tcp, port=22, source=10.20.0.0/16
+Dependencies:
+- iptables-save, ip6tables-save or nft, and usually root
+*/
+
+type FirewallRule struct {
+	table, chain, target, protocol, source, iface string
+	port                                          int
+}
+
+func (chk FirewallRule) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.port = -1
+	for _, option := range params {
+		i := strings.Index(option, "=")
+		if i < 1 {
+			return chk, errutil.ParameterTypeError{option, "name=value"}
+		}
+		name, value := option[:i], option[i+1:]
+		switch name {
+		case "table":
+			chk.table = value
+		case "chain":
+			chk.chain = value
+		case "target":
+			chk.target = value
+		case "protocol":
+			chk.protocol = value
+		case "interface":
+			chk.iface = value
+		case "port":
+			port, err := parsePort(value)
+			if err != nil {
+				return chk, errutil.ParameterTypeError{option, "port"}
+			}
+			chk.port = int(port)
+		case "source":
+			negate := ""
+			if strings.HasPrefix(value, "!") {
+				negate, value = "!", value[1:]
+			}
+			if strings.HasPrefix(value, "@") {
+				chk.source = negate + value
+				continue
+			}
+			cidr, err := netstatus.NormalizeCIDR(value)
+			if err != nil {
+				return chk, errutil.ParameterTypeError{option, "CIDR"}
+			}
+			chk.source = negate + cidr
+		default:
+			return chk, errutil.ParameterTypeError{option, "FirewallRule option"}
+		}
+	}
+	return chk, nil
+}
+
+// matches reports whether a rule matches all of the options
+func (chk FirewallRule) matches(rule netstatus.FirewallRule) bool {
+	fields := []struct{ expected, actual string }{
+		{chk.table, rule.Table},
+		{chk.chain, rule.Chain},
+		{chk.target, rule.Target},
+		{chk.protocol, rule.Protocol},
+		{chk.iface, rule.InInterface},
+	}
+	for _, field := range fields {
+		if field.expected != "" && !strings.EqualFold(field.expected, field.actual) {
+			return false
+		}
+	}
+	if chk.source != "" {
+		found := false
+		for _, source := range rule.Sources {
+			found = found || source == chk.source
+		}
+		if !found {
+			return false
+		}
+	}
+	return chk.port < 0 || rule.HasPort(uint16(chk.port))
+}
+
+// ruleStatus looks for a rule that matches the options
+func (chk FirewallRule) ruleStatus(fw netstatus.Firewall) (int, string, error) {
+	for _, rule := range fw.Rules {
+		if chk.matches(rule) {
+			return errutil.Success()
+		}
+	}
+	var options []string
+	for _, option := range [][2]string{
+		{"table", chk.table}, {"chain", chk.chain}, {"target", chk.target},
+		{"protocol", chk.protocol}, {"source", chk.source},
+		{"interface", chk.iface},
+	} {
+		if option[1] != "" {
+			options = append(options, option[0]+"="+option[1])
+		}
+	}
+	if chk.port >= 0 {
+		options = append(options, "port="+strconv.Itoa(chk.port))
+	}
+	return errutil.GenericError("No firewall rule matched", strings.Join(options, " "),
+		ruleTexts(fw.Rules))
+}
+
+func (chk FirewallRule) Status() (int, string, error) {
+	if err := chkutil.RequireHost("FirewallRule"); err != nil {
+		return 1, "", err
+	}
+	fw, err := netstatus.ReadFirewall()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.ruleStatus(fw)
+}
+
+/*
+#### FirewallPolicy
+Description: Does this firewall chain have this default policy? Every chain by
+this name in the table must have it, e.g. both iptables' and ip6tables' INPUT.
+Names are matched case insensitively.
+Parameters:
+- Chain (string): Name of the chain, e.g. INPUT or input
+- Policy (string): ACCEPT | DROP
+- Table (string, optional): Table the chain is in, filter by default
+Example parameters:
+- INPUT, FORWARD
+- DROP
+- filter
+Dependencies:
+- iptables-save, ip6tables-save or nft, and usually root
+*/
+
+type FirewallPolicy struct{ chain, policy, table string }
+
+func (chk FirewallPolicy) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 && len(params) != 3 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	policy := strings.ToUpper(params[1])
+	if policy != "ACCEPT" && policy != "DROP" {
+		return chk, errutil.ParameterTypeError{params[1], "ACCEPT | DROP"}
+	}
+	chk.chain = params[0]
+	chk.policy = policy
+	chk.table = "filter"
+	if len(params) == 3 {
+		chk.table = params[2]
+	}
+	return chk, nil
+}
+
+// policyStatus checks the policies of the chains in fw
+func (chk FirewallPolicy) policyStatus(fw netstatus.Firewall) (int, string, error) {
+	found := false
+	for _, chain := range fw.Chains {
+		if !strings.EqualFold(chain.Name, chk.chain) || !strings.EqualFold(chain.Table, chk.table) {
+			continue
+		}
+		found = true
+		if chain.Policy != chk.policy {
+			msg := fmt.Sprintf("Firewall chain %s %s %s had the wrong policy",
+				chain.Family, chain.Table, chain.Name)
+			return errutil.GenericError(msg, chk.policy, []string{chain.Policy})
+		}
+	}
+	if !found {
+		return 1, fmt.Sprintf("No such firewall chain: %s %s", chk.table, chk.chain), nil
+	}
+	return errutil.Success()
+}
+
+func (chk FirewallPolicy) Status() (int, string, error) {
+	if err := chkutil.RequireHost("FirewallPolicy"); err != nil {
+		return 1, "", err
+	}
+	fw, err := netstatus.ReadFirewall()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.policyStatus(fw)
+}
+
+/*
+#### FirewallPortOnlyFrom
+Description: Do firewall rules only accept connections to this port from these
+networks? This fails if a rule that accepts packets to the port doesn't limit
+their source to one of these CIDRs. Only rules that name the port are
+considered, not broader rules like accepting everything from an interface, and
+rules in output, forward and NAT chains are ignored. An nftables rule that
+matches a set of addresses passes only if every address is in these networks.
+Use FirewallRule to check that the port is open to the networks, and
+FirewallPolicy to check that everything else is dropped.
+Parameters:
+- Protocol (string): tcp | udp
+- Port (uint16): Destination port
+- Networks ([]CIDR): Sources that may be accepted
+Example parameters:
+- tcp, udp
+- 22, 161
+- 10.20.0.0/16, fd00:20::/64
+Dependencies:
+- iptables-save, ip6tables-save or nft, and usually root
+*/
+
+type FirewallPortOnlyFrom struct {
+	protocol string
+	port     uint16
+	networks []*net.IPNet
+}
+
+func (chk FirewallPortOnlyFrom) New(params []string) (chkutil.Check, error) {
+	if len(params) < 3 {
+		return chk, errutil.ParameterLengthError{3, params}
+	}
+	chk.protocol = strings.ToLower(params[0])
+	if chk.protocol != "tcp" && chk.protocol != "udp" {
+		return chk, errutil.ParameterTypeError{params[0], "tcp | udp"}
+	}
+	port, err := parsePort(params[1])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[1], "uint16"}
+	}
+	chk.port = port
+	for _, param := range params[2:] {
+		cidr, err := netstatus.NormalizeCIDR(param)
+		if err != nil {
+			return chk, errutil.ParameterTypeError{param, "CIDR"}
+		}
+		_, network, _ := net.ParseCIDR(cidr)
+		chk.networks = append(chk.networks, network)
+	}
+	return chk, nil
+}
+
+// allowedSource reports whether source (a CIDR) is inside one of the networks
+func (chk FirewallPortOnlyFrom) allowedSource(source string) bool {
+	if strings.HasPrefix(source, "!") {
+		return false
+	}
+	ip, network, err := net.ParseCIDR(source)
+	if err != nil {
+		return false
+	}
+	ones, bits := network.Mask.Size()
+	for _, allowed := range chk.networks {
+		allowedOnes, allowedBits := allowed.Mask.Size()
+		if allowed.Contains(ip) && allowedBits == bits && allowedOnes <= ones {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedSources reports whether a rule only matches sources inside the
+// networks. Rules without a source match any address, and the contents of
+// named nftables sets aren't known, so neither is allowed.
+func (chk FirewallPortOnlyFrom) allowedSources(sources []string) bool {
+	for _, source := range sources {
+		if !chk.allowedSource(source) {
+			return false
+		}
+	}
+	return len(sources) > 0
+}
+
+// portStatus looks for rules in fw that accept the port from elsewhere
+func (chk FirewallPortOnlyFrom) portStatus(fw netstatus.Firewall) (int, string, error) {
+	var unexpected []netstatus.FirewallRule
+	for _, rule := range fw.Rules {
+		switch {
+		case !strings.EqualFold(rule.Target, "ACCEPT"):
+		case rule.Hook != "" && rule.Hook != "input":
+		case rule.Protocol != "" && !strings.EqualFold(rule.Protocol, chk.protocol):
+		case !rule.HasPort(chk.port):
+		case !chk.allowedSources(rule.Sources):
+			unexpected = append(unexpected, rule)
+		}
+	}
+	if len(unexpected) == 0 {
+		return errutil.Success()
+	}
+	var networks []string
+	for _, network := range chk.networks {
+		networks = append(networks, network.String())
+	}
+	msg := fmt.Sprintf("Firewall accepted %d/%s from unexpected sources",
+		chk.port, chk.protocol)
+	return errutil.GenericError(msg, strings.Join(networks, ", "), ruleTexts(unexpected))
+}
+
+func (chk FirewallPortOnlyFrom) Status() (int, string, error) {
+	if err := chkutil.RequireHost("FirewallPortOnlyFrom"); err != nil {
+		return 1, "", err
+	}
+	fw, err := netstatus.ReadFirewall()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.portStatus(fw)
+}
@@ -0,0 +1,158 @@
+package checks
+
+import (
+	"testing"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+var testIPTables = `*filter
+:INPUT DROP [0:0]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [0:0]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -s 10.20.0.0/16 -p tcp -m tcp --dport 22 -j ACCEPT
+-A INPUT -p tcp -m multiport --dports 80,443,8000:8100 -j ACCEPT
+-A INPUT -s 10.30.0.5/32 -p tcp -m tcp --dport 5432 -j ACCEPT
+-A INPUT -s 10.40.0.0/16 -p tcp -m tcp --dport 5432 -j ACCEPT
+-A INPUT -j REJECT
+-A OUTPUT -p tcp -m tcp --dport 5432 -j ACCEPT
+COMMIT
+`
+
+// testNFTSets accepts port 22 from a set of networks, and 23 from a named set
+var testNFTSets = `{"nftables": [
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "expr": [
+  {"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 22}},
+  {"match": {"op": "==", "left": {"payload": {"protocol": "ip", "field": "saddr"}},
+    "right": {"set": [{"prefix": {"addr": "10.0.0.0", "len": 8}}, "192.0.2.7"]}}},
+  {"accept": null}]}},
+{"rule": {"family": "inet", "table": "filter", "chain": "input", "expr": [
+  {"match": {"op": "==", "left": {"payload": {"protocol": "tcp", "field": "dport"}}, "right": 23}},
+  {"match": {"op": "==", "left": {"payload": {"protocol": "ip", "field": "saddr"}}, "right": "@trusted"}},
+  {"accept": null}]}}
+]}`
+
+// testFirewall parses testIPTables
+func testFirewall(t *testing.T) netstatus.Firewall {
+	fw, err := netstatus.ParseIPTablesSave("ip", []byte(testIPTables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fw
+}
+
+func TestFirewallRule(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"chain=INPUT", "target=ACCEPT", "protocol=tcp", "port=22", "source=10.20.0.0/16"},
+		{"table=filter", "interface=lo"},
+		{"source=!192.168.0.0/16"},
+	}
+	invalidInputs := [][]string{
+		{}, {"chain"}, {"=INPUT"}, {"colour=red"}, {"port=ssh"}, {"source=10.0.0.300"},
+	}
+	testParameters(validInputs, invalidInputs, FirewallRule{}, t)
+	fw := testFirewall(t)
+	statuses := map[int][][]string{
+		0: {
+			{"chain=input", "target=accept", "protocol=tcp", "port=22", "source=10.20.1.0/16"},
+			{"interface=lo", "target=ACCEPT"},
+			{"port=8050"},
+			{"table=filter", "chain=INPUT", "target=REJECT"},
+		},
+		1: {
+			{"port=22", "source=10.30.0.0/16"},
+			{"chain=FORWARD"},
+			{"protocol=udp"},
+			{"table=nat"},
+		},
+	}
+	testStatuses(t, statuses, FirewallRule{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(FirewallRule).ruleStatus(fw)
+		})
+}
+
+func TestFirewallPolicy(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"INPUT", "DROP"}, {"input", "accept", "filter"}}
+	invalidInputs := [][]string{{}, {"INPUT"}, {"INPUT", "REJECT"}, {"INPUT", "DROP", "filter", "x"}}
+	testParameters(validInputs, invalidInputs, FirewallPolicy{}, t)
+	fw := testFirewall(t)
+	statuses := map[int][][]string{
+		0: {{"INPUT", "DROP"}, {"forward", "drop"}, {"OUTPUT", "ACCEPT", "filter"}},
+		1: {{"OUTPUT", "DROP"}, {"INPUT", "ACCEPT"}, {"INPUT", "DROP", "nat"}, {"f2b-sshd", "DROP"}},
+	}
+	testStatuses(t, statuses, FirewallPolicy{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(FirewallPolicy).policyStatus(fw)
+		})
+}
+
+func TestFirewallPortOnlyFrom(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"tcp", "22", "10.20.0.0/16"},
+		{"UDP", "161", "10.0.0.1", "fd00:20::/64"},
+	}
+	invalidInputs := [][]string{
+		{}, {"tcp", "22"}, {"icmp", "22", "10.0.0.0/8"},
+		{"tcp", "ssh", "10.0.0.0/8"}, {"tcp", "22", "10.0.0.0/33"},
+	}
+	testParameters(validInputs, invalidInputs, FirewallPortOnlyFrom{}, t)
+	fw := testFirewall(t)
+	statuses := map[int][][]string{
+		0: {
+			{"tcp", "22", "10.20.0.0/16"},
+			{"tcp", "22", "10.0.0.0/8"},
+			{"tcp", "5432", "10.30.0.5", "10.40.0.0/16"},
+			// nothing accepts it at all
+			{"udp", "53", "10.0.0.0/8"},
+		},
+		1: {
+			{"tcp", "22", "10.20.1.0/24"},
+			{"tcp", "5432", "10.40.0.0/16"},
+			{"tcp", "443", "10.0.0.0/8"},
+			{"tcp", "22", "fd00:20::/64"},
+		},
+	}
+	testStatuses(t, statuses, FirewallPortOnlyFrom{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(FirewallPortOnlyFrom).portStatus(fw)
+		})
+}
+
+func TestFirewallNFTSets(t *testing.T) {
+	t.Parallel()
+	fw, err := netstatus.ParseNFTJSON([]byte(testNFTSets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[int][][]string{
+		0: {
+			{"port=22", "source=10.0.0.0/8"},
+			{"port=22", "source=192.0.2.7"},
+			{"port=23", "source=@trusted"},
+		},
+		1: {{"port=22", "source=192.0.2.0/24"}},
+	}
+	testStatuses(t, statuses, FirewallRule{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(FirewallRule).ruleStatus(fw)
+		})
+	statuses = map[int][][]string{
+		0: {{"tcp", "22", "10.0.0.0/8", "192.0.2.0/24"}},
+		1: {
+			// 192.0.2.7 is outside of 10.0.0.0/8
+			{"tcp", "22", "10.0.0.0/8"},
+			// the named set could contain anything
+			{"tcp", "23", "0.0.0.0/0"},
+		},
+	}
+	testStatuses(t, statuses, FirewallPortOnlyFrom{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(FirewallPortOnlyFrom).portStatus(fw)
+		})
+}
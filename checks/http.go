@@ -0,0 +1,416 @@
+package checks
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/fsstatus"
+)
+
+func init() {
+	chkutil.Register("HTTP", func() chkutil.Check {
+		return &HTTP{}
+	})
+}
+
+/*
+#### HTTP
+Description: Does a request to this URL get the expected response? Every
+parameter after the URL is an option, written as name=value:
+- method: Request method, GET by default
+- header: Request header, as "Name: value" (may be repeated). A Host header
+  replaces the URL's host, e.g. to check a virtual host.
+- body: Request body
+- basic-auth: Username and password, as "user:password"
+- bearer: Bearer token to send in the Authorization header
+- client-cert, client-key: PEM files of a client certificate and its key
+- ca-bundle: PEM file of CAs to verify the server's certificate against
+- insecure: If true, don't verify the server's certificate at all
+- redirects: Most redirects to follow, 10 by default. With 0, redirects
+  aren't followed, and their own status is checked.
+- status: Comma separated list of acceptable status codes, where x matches any
+  digit, e.g. "200,204" or "2xx". Defaults to "2xx,3xx".
+- expect-header: Response header that must match a regexp, as
+  "Name: regexp" (may be repeated)
+- body-matches: Regexp that the response body must match
+- json: Value that a JSON response must have at a path, as "path=value",
+  e.g. "status=ok" or "checks.0.healthy=true" (may be repeated). Strings are
+  compared without their quotes, and everything else as JSON.
+- max-time: Longest the whole request may take, e.g. 500ms. The request is
+  abandoned after this long, or after 30s by default.
+Parameters:
+- URL (URL string)
+- Options (name=value, optional, any number)
+Example parameters:
+- https://api.example.com/health, http://localhost:8500/v1/status/leader
+- method=POST, "header=Content-Type: application/json", "body={}"
+- status=200, "expect-header=Content-Type: ^application/json"
+- json=status=ok, max-time=500ms, redirects=0, insecure=true
+*/
+
+type HTTP struct {
+	url, method, body  string
+	headers            http.Header
+	username, password string
+	bearer             string
+	clientCert         string
+	clientKey          string
+	caBundle           string
+	insecure           bool
+	maxRedirects       int
+	statuses           []string
+	headerExpectations []headerExpectation
+	bodyRe             *regexp.Regexp
+	jsonExpectations   []jsonExpectation
+	maxTime            time.Duration
+}
+
+// headerExpectation is a response header that must match a regexp
+type headerExpectation struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// jsonExpectation is a value that must be at a path in a JSON response
+type jsonExpectation struct {
+	path  string
+	value string
+}
+
+// defaultHTTPTimeout is how long an HTTP check waits without max-time
+const defaultHTTPTimeout = 30 * time.Second
+
+// newHTTP returns an HTTP check with the default options
+func newHTTP(urlstr string) HTTP {
+	return HTTP{
+		url:          urlstr,
+		method:       "GET",
+		headers:      make(http.Header),
+		maxRedirects: 10,
+		statuses:     []string{"2xx", "3xx"},
+	}
+}
+
+// splitHeader splits a "Name: value" pair
+func splitHeader(str string) (name, value string, err error) {
+	i := strings.Index(str, ":")
+	if i < 1 {
+		return "", "", errors.New("not a header")
+	}
+	return strings.TrimSpace(str[:i]), strings.TrimSpace(str[i+1:]), nil
+}
+
+// validStatus is a status code, or a pattern like 2xx
+var validStatus = regexp.MustCompile(`^[1-5x][0-9x]{2}$`)
+
+// parseOption parses one name=value option into chk
+func (chk *HTTP) parseOption(option string) error {
+	i := strings.Index(option, "=")
+	if i < 1 {
+		return errutil.ParameterTypeError{option, "name=value"}
+	}
+	name, value := option[:i], option[i+1:]
+	invalid := errutil.ParameterTypeError{option, name + " option"}
+	switch name {
+	case "method":
+		chk.method = strings.ToUpper(value)
+	case "header":
+		headerName, headerValue, err := splitHeader(value)
+		if err != nil {
+			return invalid
+		}
+		chk.headers.Add(headerName, headerValue)
+	case "body":
+		chk.body = value
+	case "basic-auth":
+		i := strings.Index(value, ":")
+		if i < 0 {
+			return invalid
+		}
+		chk.username, chk.password = value[:i], value[i+1:]
+	case "bearer":
+		chk.bearer = value
+	case "client-cert":
+		chk.clientCert = value
+	case "client-key":
+		chk.clientKey = value
+	case "ca-bundle":
+		chk.caBundle = value
+	case "insecure":
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return invalid
+		}
+		chk.insecure = insecure
+	case "redirects":
+		redirects, err := strconv.Atoi(value)
+		if err != nil || redirects < 0 {
+			return invalid
+		}
+		chk.maxRedirects = redirects
+	case "status":
+		chk.statuses = nil
+		for _, status := range strings.Split(value, ",") {
+			status = strings.ToLower(strings.TrimSpace(status))
+			if !validStatus.MatchString(status) {
+				return invalid
+			}
+			chk.statuses = append(chk.statuses, status)
+		}
+	case "expect-header":
+		headerName, pattern, err := splitHeader(value)
+		if err != nil {
+			return invalid
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return invalid
+		}
+		chk.headerExpectations = append(chk.headerExpectations,
+			headerExpectation{headerName, re})
+	case "body-matches":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return invalid
+		}
+		chk.bodyRe = re
+	case "json":
+		i := strings.Index(value, "=")
+		if i < 1 {
+			return invalid
+		}
+		chk.jsonExpectations = append(chk.jsonExpectations,
+			jsonExpectation{value[:i], value[i+1:]})
+	case "max-time":
+		maxTime, err := time.ParseDuration(value)
+		if err != nil || maxTime <= 0 {
+			return invalid
+		}
+		chk.maxTime = maxTime
+	default:
+		return errutil.ParameterTypeError{option, "HTTP option"}
+	}
+	return nil
+}
+
+func (chk HTTP) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	if u, err := url.Parse(params[0]); err != nil || u.Scheme == "" || u.Host == "" {
+		return chk, errutil.ParameterTypeError{params[0], "URL"}
+	}
+	chk = newHTTP(params[0])
+	for _, option := range params[1:] {
+		if err := chk.parseOption(option); err != nil {
+			return chk, err
+		}
+	}
+	if (chk.clientCert == "") != (chk.clientKey == "") {
+		return chk, errutil.ParameterTypeError{
+			strings.Join(params[1:], " "), "client-cert with client-key",
+		}
+	}
+	return chk, nil
+}
+
+// client builds an HTTP client with the check's TLS and redirect options
+func (chk HTTP) client() (*http.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: chk.insecure}
+	if chk.caBundle != "" {
+		pool, err := fsstatus.ReadCertPool(chkutil.HostPath(chk.caBundle))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if chk.clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(chkutil.HostPath(chk.clientCert),
+			chkutil.HostPath(chk.clientKey))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	timeout := defaultHTTPTimeout
+	if chk.maxTime > 0 {
+		timeout = chk.maxTime
+	}
+	maxRedirects := chk.maxRedirects
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if maxRedirects == 0 {
+				return http.ErrUseLastResponse
+			} else if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// request builds the check's request
+func (chk HTTP) request() (*http.Request, error) {
+	req, err := http.NewRequest(chk.method, chk.url, strings.NewReader(chk.body))
+	if err != nil {
+		return req, err
+	}
+	for name, values := range chk.headers {
+		req.Header[name] = values
+	}
+	// the client ignores a Host header, and sends req.Host instead
+	if host := chk.headers.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+	if chk.username != "" || chk.password != "" {
+		req.SetBasicAuth(chk.username, chk.password)
+	}
+	if chk.bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+chk.bearer)
+	}
+	return req, nil
+}
+
+// statusMatches reports whether code matches a pattern like 200 or 2xx
+func statusMatches(code int, pattern string) bool {
+	str := strconv.Itoa(code)
+	if len(str) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != 'x' && pattern[i] != str[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPath finds the value at a dotted path in a decoded JSON document, where
+// numeric elements index into arrays, e.g. "checks.0.name"
+func jsonPath(doc interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return doc, true
+	}
+	for _, elem := range strings.Split(path, ".") {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[elem]
+			if !ok {
+				return nil, false
+			}
+			doc = value
+		case []interface{}:
+			i, err := strconv.Atoi(elem)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			doc = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonString formats a JSON value for comparison: strings without quotes,
+// everything else as JSON
+func jsonString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// checkResponse checks a response against the expectations
+func (chk HTTP) checkResponse(resp *http.Response, body []byte, elapsed time.Duration) (int, string, error) {
+	statusOK := false
+	for _, pattern := range chk.statuses {
+		if statusMatches(resp.StatusCode, pattern) {
+			statusOK = true
+		}
+	}
+	if !statusOK {
+		msg := "Unexpected status code from " + chk.url
+		return errutil.GenericError(msg, strings.Join(chk.statuses, ","),
+			[]string{resp.Status})
+	}
+	if chk.maxTime > 0 && elapsed > chk.maxTime {
+		msg := "Response from " + chk.url + " was too slow"
+		return errutil.GenericError(msg, chk.maxTime, []string{elapsed.String()})
+	}
+	for _, expected := range chk.headerExpectations {
+		values := resp.Header[http.CanonicalHeaderKey(expected.name)]
+		matched := false
+		for _, value := range values {
+			if expected.re.MatchString(value) {
+				matched = true
+			}
+		}
+		if !matched {
+			msg := "Response header " + expected.name + " didn't match"
+			return errutil.GenericError(msg, expected.re.String(), values)
+		}
+	}
+	if chk.bodyRe != nil && !chk.bodyRe.Match(body) {
+		msg := "Response didn't match regexp"
+		return errutil.GenericError(msg, chk.bodyRe.String(), []string{string(body)})
+	}
+	if len(chk.jsonExpectations) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return 1, "Response wasn't JSON: " + err.Error(), nil
+		}
+		for _, expected := range chk.jsonExpectations {
+			value, ok := jsonPath(doc, expected.path)
+			if !ok {
+				return 1, "Response didn't have JSON path: " + expected.path, nil
+			} else if actual := jsonString(value); actual != expected.value {
+				msg := "Response had unexpected value at JSON path " + expected.path
+				return errutil.GenericError(msg, expected.value, []string{actual})
+			}
+		}
+	}
+	return errutil.Success()
+}
+
+func (chk HTTP) Status() (int, string, error) {
+	client, err := chk.client()
+	if err != nil {
+		return 1, "", err
+	}
+	req, err := chk.request()
+	if err != nil {
+		return 1, "", err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 1, "Request failed: " + err.Error(), nil
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	elapsed := time.Since(start)
+	if err != nil {
+		return 1, "Couldn't read response from " + chk.url + ": " + err.Error(), nil
+	}
+	return chk.checkResponse(resp, body, elapsed)
+}
@@ -0,0 +1,152 @@
+package checks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// testHTTPHandler serves the paths that TestHTTP makes requests to
+func testHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Version", "1.2.3")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "ok",
+			"count":  3,
+			"checks": []map[string]interface{}{{"name": "db", "healthy": true}},
+		})
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.Header.Get("X-Test") + " " + string(body)))
+	})
+	mux.HandleFunc("/host", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	mux.HandleFunc("/basic", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.HandleFunc("/bearer", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	mux.Handle("/redirect", http.RedirectHandler("/health", http.StatusFound))
+	mux.Handle("/missing", http.NotFoundHandler())
+	return mux
+}
+
+func TestHTTP(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(testHTTPHandler())
+	defer server.Close()
+	url := server.URL
+
+	validInputs := [][]string{
+		{url},
+		{url, "method=post", "header=X-Test: yes", "body={}"},
+		{url, "status=200,2xx", "expect-header=Content-Type: json$"},
+		{url, "json=checks.0.healthy=true", "max-time=1s", "redirects=0"},
+		{url, "basic-auth=user:pass", "bearer=abc", "insecure=true"},
+		{url, "client-cert=cert.pem", "client-key=key.pem", "ca-bundle=ca.pem"},
+	}
+	invalidInputs := [][]string{
+		{}, {"not a url"}, {"/health"},
+		{url, "status=600"}, {url, "status=ok"}, {url, "method"},
+		{url, "header=nocolon"}, {url, "expect-header=X: (unclosed"},
+		{url, "body-matches=(unclosed"}, {url, "json=noequals"},
+		{url, "max-time=soon"}, {url, "redirects=-1"}, {url, "insecure=maybe"},
+		{url, "client-cert=cert.pem"}, {url, "frobnicate=true"},
+	}
+	testParameters(validInputs, invalidInputs, HTTP{}, t)
+
+	goodEggs := [][]string{
+		{url + "/health"},
+		{url + "/health", "status=200", "expect-header=X-Version: ^1\\."},
+		{url + "/health", "json=status=ok", "json=count=3", "json=checks.0.name=db"},
+		{url + "/health", "json=$.checks.0.healthy=true", "max-time=1s"},
+		{url + "/echo", "method=PUT", "header=X-Test: yes", "body=hello",
+			"body-matches=^PUT yes hello$"},
+		{url + "/host", "header=Host: www.example.com", "body-matches=^www\\.example\\.com$"},
+		{url + "/basic", "basic-auth=admin:s3cret", "status=200"},
+		{url + "/bearer", "bearer=t0ken", "status=200"},
+		{url + "/redirect", "json=status=ok"},
+		{url + "/redirect", "redirects=0", "status=302"},
+		{url + "/missing", "status=404"},
+	}
+	badEggs := [][]string{
+		{url + "/missing"},
+		{url + "/health", "status=204"},
+		{url + "/health", "expect-header=X-Version: ^2\\."},
+		{url + "/health", "expect-header=X-Missing: .*"},
+		{url + "/health", "json=status=failing"},
+		{url + "/health", "json=checks.1.name=db"},
+		{url + "/echo", "json=status=ok"},
+		{url + "/echo", "body-matches=^POST"},
+		{url + "/host", "body-matches=example"},
+		{url + "/slow", "max-time=50ms"},
+		{url + "/basic", "basic-auth=admin:wrong"},
+		{url + "/bearer"},
+		{url + "/redirect", "redirects=0", "status=2xx"},
+		{"http://127.0.0.1:0/"},
+	}
+	testCheck(goodEggs, badEggs, HTTP{}, t)
+}
+
+func TestHTTPTLS(t *testing.T) {
+	t.Parallel()
+	certs := writeTestCerts(t)
+	defer os.RemoveAll(certs.dir)
+	caPEM, err := ioutil.ReadFile(certs.caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(caPEM)
+	server := httptest.NewUnstartedServer(testHTTPHandler())
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{certs.keyPair},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	// handshake failures are expected, don't log them
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	url := server.URL + "/health"
+
+	clientCert := []string{"client-cert=" + certs.certFile, "client-key=" + certs.keyFile}
+	goodEggs := [][]string{
+		append([]string{url, "ca-bundle=" + certs.caFile}, clientCert...),
+		append([]string{url, "insecure=true"}, clientCert...),
+	}
+	badEggs := [][]string{
+		append([]string{url}, clientCert...),
+		{url, "ca-bundle=" + certs.caFile},
+	}
+	testCheck(goodEggs, badEggs, HTTP{}, t)
+
+	// ResponseMatches checks certificates, ResponseMatchesInsecure doesn't
+	insecure := httptest.NewUnstartedServer(testHTTPHandler())
+	insecure.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	insecure.StartTLS()
+	defer insecure.Close()
+	testCheck([][]string{}, [][]string{{insecure.URL + "/health", "ok"}},
+		ResponseMatches{}, t)
+	testCheck([][]string{{insecure.URL + "/missing", "404"}},
+		[][]string{{insecure.URL + "/health", "failing"}}, ResponseMatchesInsecure{}, t)
+}
@@ -0,0 +1,366 @@
+package checks
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+func init() {
+	chkutil.Register("InterfaceMTU", func() chkutil.Check {
+		return &InterfaceMTU{}
+	})
+	chkutil.Register("InterfaceSpeed", func() chkutil.Check {
+		return &InterfaceSpeed{}
+	})
+	chkutil.Register("InterfaceDuplex", func() chkutil.Check {
+		return &InterfaceDuplex{}
+	})
+	chkutil.Register("InterfaceCarrier", func() chkutil.Check {
+		return &InterfaceCarrier{}
+	})
+	chkutil.Register("InterfaceCounterRate", func() chkutil.Check {
+		return &InterfaceCounterRate{}
+	})
+	chkutil.Register("BondSlavesUp", func() chkutil.Check {
+		return &BondSlavesUp{}
+	})
+}
+
+/*
+#### InterfaceMTU
+Description: Does this interface have this MTU?
+Parameters:
+- Name (string): Name of the interface
+- MTU (positive int): Expected MTU, in bytes
+Example parameters:
+- eth0, bond0, docker0
+- 1500, 9000
+Dependencies:
+- /sys/class/net
+*/
+
+type InterfaceMTU struct {
+	name string
+	mtu  int
+}
+
+func (chk InterfaceMTU) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	mtu, err := strconv.Atoi(params[1])
+	if err != nil || mtu <= 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.name = params[0]
+	chk.mtu = mtu
+	return chk, nil
+}
+
+func (chk InterfaceMTU) Status() (int, string, error) {
+	iface, err := netstatus.ReadInterface(chk.name)
+	if os.IsNotExist(err) {
+		return 1, "No such interface: " + chk.name, nil
+	} else if err != nil {
+		return 1, "", err
+	} else if iface.MTU == chk.mtu {
+		return errutil.Success()
+	}
+	msg := "Interface " + chk.name + " had unexpected MTU"
+	return errutil.GenericError(msg, chk.mtu, []int{iface.MTU})
+}
+
+/*
+#### InterfaceSpeed
+Description: Has this interface negotiated a link speed of at least this many
+Mb/s? The check fails if the speed is unknown, e.g. because the link is down.
+Parameters:
+- Name (string): Name of the interface
+- Speed (positive int): Slowest speed allowed, in Mb/s
+Example parameters:
+- eth0, enp3s0
+- 1000, 10000
+Dependencies:
+- /sys/class/net
+*/
+
+type InterfaceSpeed struct {
+	name  string
+	speed int
+}
+
+func (chk InterfaceSpeed) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	speed, err := strconv.Atoi(params[1])
+	if err != nil || speed <= 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.name = params[0]
+	chk.speed = speed
+	return chk, nil
+}
+
+func (chk InterfaceSpeed) Status() (int, string, error) {
+	iface, err := netstatus.ReadInterface(chk.name)
+	if os.IsNotExist(err) {
+		return 1, "No such interface: " + chk.name, nil
+	} else if err != nil {
+		return 1, "", err
+	} else if iface.Speed < 0 {
+		msg := "Speed of interface " + chk.name + " is unknown, is its link down?"
+		return 1, msg, nil
+	} else if iface.Speed >= chk.speed {
+		return errutil.Success()
+	}
+	msg := "Interface " + chk.name + " negotiated a slow link"
+	return errutil.GenericError(msg, fmt.Sprintf("at least %d Mb/s", chk.speed),
+		[]string{fmt.Sprintf("%d Mb/s", iface.Speed)})
+}
+
+/*
+#### InterfaceDuplex
+Description: Has this interface negotiated this duplex mode? A half duplex link
+is usually a sign of a failed autonegotiation.
+Parameters:
+- Name (string): Name of the interface
+- Duplex (string): full | half
+Example parameters:
+- eth0, enp3s0
+- full
+Dependencies:
+- /sys/class/net
+*/
+
+type InterfaceDuplex struct{ name, duplex string }
+
+func (chk InterfaceDuplex) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	duplex := strings.ToLower(params[1])
+	if duplex != "full" && duplex != "half" {
+		return chk, errutil.ParameterTypeError{params[1], "full | half"}
+	}
+	chk.name = params[0]
+	chk.duplex = duplex
+	return chk, nil
+}
+
+func (chk InterfaceDuplex) Status() (int, string, error) {
+	iface, err := netstatus.ReadInterface(chk.name)
+	if os.IsNotExist(err) {
+		return 1, "No such interface: " + chk.name, nil
+	} else if err != nil {
+		return 1, "", err
+	} else if iface.Duplex == chk.duplex {
+		return errutil.Success()
+	}
+	msg := "Interface " + chk.name + " had unexpected duplex"
+	return errutil.GenericError(msg, chk.duplex, []string{iface.Duplex})
+}
+
+/*
+#### InterfaceCarrier
+Description: Does this interface have a carrier, i.e. is its physical link up?
+Unlike Up, which checks that the interface has been enabled, this fails if the
+cable is unplugged or the switch port is down.
+Parameters:
+- Name (string): Name of the interface
+Example parameters:
+- eth0, enp3s0
+Dependencies:
+- /sys/class/net
+*/
+
+type InterfaceCarrier struct{ name string }
+
+func (chk InterfaceCarrier) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.name = params[0]
+	return chk, nil
+}
+
+func (chk InterfaceCarrier) Status() (int, string, error) {
+	iface, err := netstatus.ReadInterface(chk.name)
+	if os.IsNotExist(err) {
+		return 1, "No such interface: " + chk.name, nil
+	} else if err != nil {
+		return 1, "", err
+	} else if iface.Carrier {
+		return errutil.Success()
+	}
+	msg := "Interface " + chk.name + " had no carrier"
+	return errutil.GenericError(msg, "up", []string{iface.OperState})
+}
+
+/*
+#### InterfaceCounterRate
+Description: Has this interface counter increased by no more than this much
+per this period, since the last run? Distributive remembers the counter's
+value between runs (see --state-dir), so the first run only records it. If the
+counter has gone down, e.g. because the driver was reloaded, the check passes
+and starts again from the new value.
+Parameters:
+- Name (string): Name of the interface
+- Counter (string): Counter in /sys/class/net/NAME/statistics, e.g. rx_errors,
+  tx_dropped or rx_crc_errors, or "errors" or "drops" for the sum of the rx
+  and tx counters
+- Maximum (int): Largest increase allowed per period
+- Period (time.Duration): Period the maximum applies to
+Example parameters:
+- eth0, bond0
+- errors, drops, rx_crc_errors
+- 0, 10, 100
+- 1m, 1h
+Dependencies:
+- /sys/class/net
+*/
+
+type InterfaceCounterRate struct {
+	name, counter string
+	max           uint64
+	period        time.Duration
+}
+
+// interfaceCounterState is a counter's value the last time it was read
+type interfaceCounterState struct {
+	Value uint64
+	Time  time.Time
+}
+
+func (chk InterfaceCounterRate) New(params []string) (chkutil.Check, error) {
+	if len(params) != 4 {
+		return chk, errutil.ParameterLengthError{4, params}
+	}
+	max, err := strconv.ParseUint(params[2], 10, 64)
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[2], "positive int"}
+	}
+	period, err := time.ParseDuration(params[3])
+	if err != nil || period <= 0 {
+		return chk, errutil.ParameterTypeError{params[3], "time.Duration"}
+	}
+	if strings.Contains(params[1], "/") {
+		return chk, errutil.ParameterTypeError{params[1], "counter name"}
+	}
+	chk.name = params[0]
+	chk.counter = params[1]
+	chk.max = max
+	chk.period = period
+	return chk, nil
+}
+
+// rateStatus compares the increase in the counter since last with the
+// maximum
+func (chk InterfaceCounterRate) rateStatus(last, now interfaceCounterState) (int, string, error) {
+	elapsed := now.Time.Sub(last.Time)
+	if now.Value <= last.Value || elapsed <= 0 {
+		return errutil.Success()
+	}
+	increase := now.Value - last.Value
+	rate := float64(increase) * float64(chk.period) / float64(elapsed)
+	if rate <= float64(chk.max) {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("%s of interface %s increased by %d in %v",
+		chk.counter, chk.name, increase, elapsed)
+	specified := fmt.Sprintf("at most %d per %v", chk.max, chk.period)
+	return errutil.GenericError(msg, specified,
+		[]string{fmt.Sprintf("%.1f per %v", rate, chk.period)})
+}
+
+func (chk InterfaceCounterRate) Status() (int, string, error) {
+	value, err := netstatus.InterfaceCounter(chk.name, chk.counter)
+	if err != nil {
+		return 1, "", err
+	}
+	var last interfaceCounterState
+	key := chk.name + " " + chk.counter
+	found, err := chkutil.LoadState("InterfaceCounterRate", key, &last)
+	if err != nil {
+		return 1, "", err
+	}
+	now := interfaceCounterState{value, time.Now()}
+	if err := chkutil.SaveState("InterfaceCounterRate", key, now); err != nil {
+		return 1, "", err
+	} else if !found {
+		return errutil.Success()
+	}
+	return chk.rateStatus(last, now)
+}
+
+/*
+#### BondSlavesUp
+Description: Is this bonded interface up, with at least this many of its slaves
+up? A bond keeps working when a slave fails, so a dead slave can otherwise go
+unnoticed until the next one does.
+Parameters:
+- Name (string): Name of the bond
+- Minimum (positive int): Fewest slaves that must be up
+Example parameters:
+- bond0
+- 1, 2
+Dependencies:
+- /proc/net/bonding
+*/
+
+type BondSlavesUp struct {
+	name string
+	min  int
+}
+
+func (chk BondSlavesUp) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	min, err := strconv.Atoi(params[1])
+	if err != nil || min < 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.name = params[0]
+	chk.min = min
+	return chk, nil
+}
+
+// bondStatus checks that the bond and enough of its slaves are up
+func (chk BondSlavesUp) bondStatus(bond netstatus.Bond) (int, string, error) {
+	if bond.MIIStatus != "up" {
+		msg := "Bond " + chk.name + " was down"
+		return errutil.GenericError(msg, "up", []string{bond.MIIStatus})
+	}
+	up := 0
+	var slaves []string
+	for _, slave := range bond.Slaves {
+		if slave.MIIStatus == "up" {
+			up++
+		}
+		slaves = append(slaves, fmt.Sprintf("%s: %s (%d link failures)",
+			slave.Name, slave.MIIStatus, slave.LinkFailures))
+	}
+	if up >= chk.min {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("Only %d slaves of bond %s were up", up, chk.name)
+	return errutil.GenericError(msg, "at least "+strconv.Itoa(chk.min), slaves)
+}
+
+func (chk BondSlavesUp) Status() (int, string, error) {
+	bond, err := netstatus.ReadBond(chk.name)
+	if os.IsNotExist(err) {
+		return 1, "No such bond: " + chk.name, nil
+	} else if err != nil {
+		return 1, "", err
+	}
+	return chk.bondStatus(bond)
+}
@@ -0,0 +1,101 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+func TestInterfaceMTU(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"lo", "65536"}, {"eth0", "1500"}}
+	invalidInputs := [][]string{{}, {"lo"}, {"lo", "big"}, {"lo", "0"}}
+	goodEggs := [][]string{{"lo", "65536"}}
+	badEggs := [][]string{{"lo", "1500"}}
+	testParameters(validInputs, invalidInputs, InterfaceMTU{}, t)
+	testCheck(goodEggs, badEggs, InterfaceMTU{}, t)
+}
+
+func TestInterfaceLink(t *testing.T) {
+	t.Parallel()
+	// loopback has a carrier, but no speed or duplex
+	testParameters([][]string{{"eth0", "1000"}}, [][]string{{"eth0"}, {"eth0", "-1"}},
+		InterfaceSpeed{}, t)
+	testCheck([][]string{}, [][]string{{"lo", "1"}}, InterfaceSpeed{}, t)
+	testParameters([][]string{{"eth0", "full"}, {"eth0", "Half"}},
+		[][]string{{"eth0"}, {"eth0", "quarter"}}, InterfaceDuplex{}, t)
+	testCheck([][]string{}, [][]string{{"lo", "full"}}, InterfaceDuplex{}, t)
+	testParameters(names, notLengthOne, InterfaceCarrier{}, t)
+	testCheck([][]string{{"lo"}}, [][]string{}, InterfaceCarrier{}, t)
+}
+
+func TestInterfaceCounterRate(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"eth0", "errors", "0", "1m"},
+		{"bond0", "rx_crc_errors", "100", "1h"},
+	}
+	invalidInputs := [][]string{
+		{}, {"eth0", "errors", "0"},
+		{"eth0", "errors", "-1", "1m"},
+		{"eth0", "errors", "0", "0s"},
+		{"eth0", "../../mtu", "0", "1m"},
+	}
+	testParameters(validInputs, invalidInputs, InterfaceCounterRate{}, t)
+
+	chk, err := InterfaceCounterRate{}.New([]string{"eth0", "errors", "10", "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	last := interfaceCounterState{100, start}
+	rates := []struct {
+		now  interfaceCounterState
+		code int
+	}{
+		{interfaceCounterState{100, start.Add(time.Minute)}, 0},
+		{interfaceCounterState{110, start.Add(time.Minute)}, 0},
+		{interfaceCounterState{111, start.Add(time.Minute)}, 1},
+		{interfaceCounterState{150, start.Add(5 * time.Minute)}, 0},
+		{interfaceCounterState{160, start.Add(5 * time.Minute)}, 1},
+		// the counter was reset
+		{interfaceCounterState{5, start.Add(time.Minute)}, 0},
+	}
+	for _, rate := range rates {
+		code, msg, _ := chk.(InterfaceCounterRate).rateStatus(last, rate.now)
+		if code != rate.code {
+			t.Errorf("Unexpected status for %+v: %d, %s", rate.now, code, msg)
+		}
+	}
+}
+
+func TestBondSlavesUp(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"bond0", "1"}, {"bond1", "2"}}
+	invalidInputs := [][]string{{}, {"bond0"}, {"bond0", "-1"}, {"bond0", "two"}}
+	testParameters(validInputs, invalidInputs, BondSlavesUp{}, t)
+	testCheck([][]string{}, [][]string{{"nonexistentbond", "1"}}, BondSlavesUp{}, t)
+
+	bond := netstatus.Bond{
+		MIIStatus: "up",
+		Slaves: []netstatus.BondSlave{
+			{Name: "eth0", MIIStatus: "down", LinkFailures: 2},
+			{Name: "eth1", MIIStatus: "up"},
+		},
+	}
+	for min, expected := range []int{0, 0, 1} {
+		chk := BondSlavesUp{name: "bond0", min: min}
+		code, msg, _ := chk.bondStatus(bond)
+		if code != expected {
+			t.Errorf("Unexpected status with minimum %d: %d, %s", min, code, msg)
+		} else if code != 0 && !strings.Contains(msg, "eth0: down (2 link failures)") {
+			t.Errorf("Message didn't list down slave: %s", msg)
+		}
+	}
+	bond.MIIStatus = "down"
+	if code, _, _ := (BondSlavesUp{"bond0", 0}).bondStatus(bond); code != 1 {
+		t.Error("Bond that was down passed")
+	}
+}
@@ -0,0 +1,316 @@
+package checks
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/cpustatus"
+	"github.com/CiscoCloud/distributive/errutil"
+)
+
+func init() {
+	chkutil.Register("LoadAverage", func() chkutil.Check {
+		return &LoadAverage{}
+	})
+	chkutil.Register("PerCPUUsage", func() chkutil.Check {
+		return &PerCPUUsage{}
+	})
+	chkutil.Register("CPUIOWait", func() chkutil.Check {
+		return &CPUIOWait{}
+	})
+	chkutil.Register("CPUSteal", func() chkutil.Check {
+		return &CPUSteal{}
+	})
+	chkutil.Register("Pressure", func() chkutil.Check {
+		return &Pressure{}
+	})
+}
+
+// parsePercent parses a percentage, with or without a % sign
+func parsePercent(param string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(param, "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, errutil.ParameterTypeError{param, "percentage"}
+	}
+	return percent, nil
+}
+
+/*
+#### LoadAverage
+Description: Is the load average, divided by the number of CPUs, at most this
+maximum? A load of 1 per CPU means that there was, on average, one runnable
+process for each CPU.
+Parameters:
+- Period (int): Minutes the load is averaged over: 1 | 5 | 15
+- Maximum (float64): Largest load allowed per CPU
+Example parameters:
+- 1, 5, 15
+- 0.7, 1, 2.5
+Dependencies:
+- /proc/loadavg, /proc/stat
+*/
+
+type LoadAverage struct {
+	period int
+	max    float64
+}
+
+func (chk LoadAverage) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	period, err := strconv.Atoi(params[0])
+	if err != nil || (period != 1 && period != 5 && period != 15) {
+		return chk, errutil.ParameterTypeError{params[0], "1 | 5 | 15"}
+	}
+	max, err := strconv.ParseFloat(params[1], 64)
+	if err != nil || max <= 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive float64"}
+	}
+	chk.period = period
+	chk.max = max
+	return chk, nil
+}
+
+// loadStatus compares the load per CPU with the maximum
+func (chk LoadAverage) loadStatus(load cpustatus.LoadAvg, cpus int) (int, string, error) {
+	loads := map[int]float64{1: load.Load1, 5: load.Load5, 15: load.Load15}
+	perCPU := loads[chk.period] / float64(cpus)
+	if perCPU <= chk.max {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("%d minute load average per CPU above defined maximum", chk.period)
+	return errutil.GenericError(msg, chk.max, []string{fmt.Sprintf("%.2f (%.2f on %d CPUs)",
+		perCPU, loads[chk.period], cpus)})
+}
+
+func (chk LoadAverage) Status() (int, string, error) {
+	load, err := cpustatus.ReadLoadAvg()
+	if err != nil {
+		return 1, "", err
+	}
+	cpus, err := cpustatus.ReadStat()
+	if err != nil {
+		return 1, "", err
+	}
+	// the first line is all of the CPUs together
+	return chk.loadStatus(load, len(cpus)-1)
+}
+
+// cpuTimeCheck is a check that a share of CPU time is at most a percentage,
+// over a window
+type cpuTimeCheck struct {
+	max    float64
+	window time.Duration
+}
+
+// parse parses the parameters, Percent and an optional Window
+func (chk *cpuTimeCheck) parse(params []string) (err error) {
+	if len(params) != 1 && len(params) != 2 {
+		return errutil.ParameterLengthError{1, params}
+	}
+	if chk.max, err = parsePercent(params[0]); err != nil {
+		return err
+	}
+	chk.window, err = parseCPUWindow(params, 1)
+	return err
+}
+
+// usageStatus checks that the share of time that percent picks out of each
+// usage is at most the maximum
+func (chk cpuTimeCheck) usageStatus(usages []cpustatus.Usage, name string,
+	percent func(cpustatus.Usage) float64) (int, string, error) {
+	var exceeded []string
+	for _, usage := range usages {
+		if percent(usage) > chk.max {
+			exceeded = append(exceeded, fmt.Sprintf("%s: %.1f%%", usage.Name, percent(usage)))
+		}
+	}
+	if len(exceeded) == 0 {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("%s above defined maximum over %v", name, chk.window)
+	return errutil.GenericError(msg, fmt.Sprintf("%v%%", chk.max), exceeded)
+}
+
+/*
+#### PerCPUUsage
+Description: Is the usage of every CPU at most this percentage over this window?
+Unlike CPUUsage, this catches a single-threaded process saturating one CPU of
+many. Time spent waiting for IO doesn't count as usage here, though it does
+for CPUUsage; use CPUIOWait to check it.
+Parameters:
+- Percent (float64 percentage): Maximum acceptable percentage used
+- Window (time.Duration, optional): How long to measure usage over, 3s by
+  default
+Example parameters:
+- 95%, 90%
+- 1s, 10s
+Dependencies:
+- /proc/stat
+*/
+
+type PerCPUUsage struct{ cpuTimeCheck }
+
+func (chk PerCPUUsage) New(params []string) (chkutil.Check, error) {
+	err := chk.parse(params)
+	return chk, err
+}
+
+func (chk PerCPUUsage) Status() (int, string, error) {
+	usages, err := cpustatus.Sample(chk.window)
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.usageStatus(usages[1:], "CPU usage",
+		func(usage cpustatus.Usage) float64 { return usage.Busy })
+}
+
+/*
+#### CPUIOWait
+Description: Is the share of CPU time spent idle waiting for IO at most this
+percentage over this window? High iowait means that processes are held up by
+slow disks or network filesystems.
+Parameters:
+- Percent (float64 percentage): Maximum acceptable percentage of iowait
+- Window (time.Duration, optional): How long to measure over, 3s by default
+Example parameters:
+- 10%, 25%
+- 1s, 10s
+Dependencies:
+- /proc/stat
+*/
+
+type CPUIOWait struct{ cpuTimeCheck }
+
+func (chk CPUIOWait) New(params []string) (chkutil.Check, error) {
+	err := chk.parse(params)
+	return chk, err
+}
+
+func (chk CPUIOWait) Status() (int, string, error) {
+	usages, err := cpustatus.Sample(chk.window)
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.usageStatus(usages[:1], "CPU iowait",
+		func(usage cpustatus.Usage) float64 { return usage.IOWait })
+}
+
+/*
+#### CPUSteal
+Description: Is the share of CPU time stolen by the hypervisor at most this
+percentage over this window? High steal means that a virtual machine's host is
+overcommitted.
+Parameters:
+- Percent (float64 percentage): Maximum acceptable percentage of steal
+- Window (time.Duration, optional): How long to measure over, 3s by default
+Example parameters:
+- 5%, 10%
+- 1s, 10s
+Dependencies:
+- /proc/stat
+*/
+
+type CPUSteal struct{ cpuTimeCheck }
+
+func (chk CPUSteal) New(params []string) (chkutil.Check, error) {
+	err := chk.parse(params)
+	return chk, err
+}
+
+func (chk CPUSteal) Status() (int, string, error) {
+	usages, err := cpustatus.Sample(chk.window)
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.usageStatus(usages[:1], "CPU steal",
+		func(usage cpustatus.Usage) float64 { return usage.Steal })
+}
+
+/*
+#### Pressure
+Description: Is the pressure on this resource at most this percentage? Pressure
+stall information is the share of time that tasks were stalled waiting for the
+resource, averaged over 10, 60 or 300 seconds: "some" when at least one task
+was, and "full" when all of them were. It's a better sign of a shortage than
+usage, which can be high while tasks are still getting what they need. Full
+cpu pressure is only reported by Linux 5.13 and later.
+Parameters:
+- Resource (string): cpu | memory | io
+- Kind (string): some | full
+- Average (string): avg10 | avg60 | avg300
+- Percent (float64 percentage): Maximum acceptable pressure
+Example parameters:
+- memory, io
+- some, full
+- avg10, avg60
+- 10%, 25%
+Dependencies:
+- /proc/pressure, on Linux 4.20 or later
+*/
+
+type Pressure struct {
+	resource, kind, average string
+	max                     float64
+}
+
+func (chk Pressure) New(params []string) (chkutil.Check, error) {
+	if len(params) != 4 {
+		return chk, errutil.ParameterLengthError{4, params}
+	}
+	resource := strings.ToLower(params[0])
+	if resource != "cpu" && resource != "memory" && resource != "io" {
+		return chk, errutil.ParameterTypeError{params[0], "cpu | memory | io"}
+	}
+	kind := strings.ToLower(params[1])
+	if kind != "some" && kind != "full" {
+		return chk, errutil.ParameterTypeError{params[1], "some | full"}
+	}
+	average := strings.ToLower(params[2])
+	if average != "avg10" && average != "avg60" && average != "avg300" {
+		return chk, errutil.ParameterTypeError{params[2], "avg10 | avg60 | avg300"}
+	}
+	max, err := parsePercent(params[3])
+	if err != nil {
+		return chk, err
+	}
+	chk.resource, chk.kind, chk.average, chk.max = resource, kind, average, max
+	return chk, nil
+}
+
+// pressureStatus compares the chosen average with the maximum
+func (chk Pressure) pressureStatus(pressure cpustatus.Pressure) (int, string, error) {
+	avgs := pressure.Some
+	if chk.kind == "full" && !pressure.HasFull {
+		return 1, "", fmt.Errorf("The kernel doesn't report full %s pressure, "+
+			"which needs Linux 5.13 or later for cpu", chk.resource)
+	} else if chk.kind == "full" {
+		avgs = pressure.Full
+	}
+	actual := map[string]float64{
+		"avg10": avgs.Avg10, "avg60": avgs.Avg60, "avg300": avgs.Avg300,
+	}[chk.average]
+	if actual <= chk.max {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("%s pressure (%s %s) above defined maximum", chk.resource,
+		chk.kind, chk.average)
+	return errutil.GenericError(msg, fmt.Sprintf("%v%%", chk.max),
+		[]string{fmt.Sprintf("%.2f%%", actual)})
+}
+
+func (chk Pressure) Status() (int, string, error) {
+	pressure, err := cpustatus.ReadPressure(chk.resource)
+	if os.IsNotExist(err) {
+		return 1, "", fmt.Errorf("Pressure stall information isn't available, " +
+			"it needs Linux 4.20 or later built with CONFIG_PSI")
+	} else if err != nil {
+		return 1, "", err
+	}
+	return chk.pressureStatus(pressure)
+}
@@ -0,0 +1,115 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CiscoCloud/distributive/cpustatus"
+)
+
+func TestLoadAverage(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"1", "0.7"}, {"15", "2"}}
+	invalidInputs := [][]string{{}, {"1"}, {"10", "1"}, {"5", "high"}, {"5", "0"}}
+	testParameters(validInputs, invalidInputs, LoadAverage{}, t)
+	testCheck([][]string{{"1", "1000"}}, [][]string{}, LoadAverage{}, t)
+
+	load := cpustatus.LoadAvg{Load1: 6, Load5: 3, Load15: 1}
+	statuses := []struct {
+		chk  LoadAverage
+		code int
+	}{
+		{LoadAverage{1, 1.6}, 0}, {LoadAverage{1, 1.5}, 0}, {LoadAverage{1, 1.4}, 1},
+		{LoadAverage{5, 1}, 0}, {LoadAverage{15, 0.3}, 0}, {LoadAverage{15, 0.25}, 0},
+		{LoadAverage{15, 0.2}, 1},
+	}
+	for _, status := range statuses {
+		code, msg, _ := status.chk.loadStatus(load, 4)
+		if code != status.code {
+			t.Errorf("Unexpected status for %+v: %d, %s", status.chk, code, msg)
+		} else if code != 0 && !strings.Contains(msg, "on 4 CPUs") {
+			t.Errorf("Message didn't give the number of CPUs: %s", msg)
+		}
+	}
+}
+
+func TestCPUTimeChecks(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"95%"}, {"10", "1s"}, {"2.5", "100ms"}}
+	invalidInputs := [][]string{{}, {"101"}, {"-1"}, {"10", "0s"}, {"10", "1s", "x"}}
+	testParameters(validInputs, invalidInputs, PerCPUUsage{}, t)
+	testParameters(validInputs, invalidInputs, CPUIOWait{}, t)
+	testParameters(validInputs, invalidInputs, CPUSteal{}, t)
+	// nothing can use more than 100%, but an idle CPU can use 0%
+	testCheck([][]string{{"100", "100ms"}}, [][]string{}, PerCPUUsage{}, t)
+	testCheck([][]string{{"100", "100ms"}}, [][]string{}, CPUIOWait{}, t)
+	testCheck([][]string{{"100", "100ms"}}, [][]string{}, CPUSteal{}, t)
+
+	usages := []cpustatus.Usage{
+		{Name: "cpu0", Busy: 20}, {Name: "cpu1", Busy: 99.5}, {Name: "cpu2", Busy: 40},
+	}
+	busy := func(usage cpustatus.Usage) float64 { return usage.Busy }
+	chk := cpuTimeCheck{max: 95}
+	if code, msg, _ := chk.usageStatus(usages, "CPU usage", busy); code != 1 ||
+		!strings.Contains(msg, "cpu1: 99.5%") || strings.Contains(msg, "cpu0") {
+		t.Errorf("Unexpected status: %d, %s", code, msg)
+	}
+	chk.max = 99.5
+	if code, msg, _ := chk.usageStatus(usages, "CPU usage", busy); code != 0 {
+		t.Errorf("Unexpected status: %d, %s", code, msg)
+	}
+}
+
+func TestPressure(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"memory", "some", "avg10", "10%"}, {"IO", "full", "avg300", "25"},
+	}
+	invalidInputs := [][]string{
+		{}, {"memory", "some", "avg10"}, {"disk", "some", "avg10", "10"},
+		{"io", "most", "avg10", "10"}, {"io", "some", "avg30", "10"},
+		{"io", "some", "avg10", "200"},
+	}
+	testParameters(validInputs, invalidInputs, Pressure{}, t)
+
+	pressure := cpustatus.Pressure{
+		Some: cpustatus.PressureAvgs{Avg10: 12.5, Avg60: 4, Avg300: 1},
+		Full: cpustatus.PressureAvgs{Avg10: 3, Avg60: 1, Avg300: 0.5}, HasFull: true,
+	}
+	statuses := []struct {
+		params []string
+		code   int
+	}{
+		{[]string{"memory", "some", "avg10", "15"}, 0},
+		{[]string{"memory", "some", "avg10", "10"}, 1},
+		{[]string{"memory", "some", "avg60", "5"}, 0},
+		{[]string{"memory", "full", "avg10", "5"}, 0},
+		{[]string{"memory", "full", "avg300", "0.5"}, 0},
+		{[]string{"memory", "full", "avg300", "0.4"}, 1},
+	}
+	for _, status := range statuses {
+		chk, err := Pressure{}.New(status.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testStatus(t, status.params, status.code, func() (int, string, error) {
+			return chk.(Pressure).pressureStatus(pressure)
+		})
+	}
+	// cpu pressure before Linux 5.13
+	pressure = cpustatus.Pressure{Some: pressure.Some}
+	chk, err := Pressure{}.New([]string{"cpu", "full", "avg10", "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := chk.(Pressure).pressureStatus(pressure); err == nil {
+		t.Error("Expected an error for full pressure that wasn't reported")
+	}
+	chk, err = Pressure{}.New([]string{"cpu", "some", "avg10", "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStatus(t, []string{"cpu", "some", "avg10", "100"}, 0, func() (int, string, error) {
+		return chk.(Pressure).pressureStatus(pressure)
+	})
+}
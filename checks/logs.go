@@ -0,0 +1,281 @@
+package checks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/systemdstatus"
+	"github.com/CiscoCloud/distributive/tabular"
+)
+
+func init() {
+	chkutil.Register("JournalMatches", func() chkutil.Check {
+		return &JournalMatches{}
+	})
+	chkutil.Register("LogFileMatches", func() chkutil.Check {
+		return &LogFileMatches{}
+	})
+}
+
+// matchingLines returns the lines that match re
+func matchingLines(re *regexp.Regexp, lines []string) (matched []string) {
+	for _, line := range lines {
+		if re.MatchString(line) {
+			matched = append(matched, line)
+		}
+	}
+	return matched
+}
+
+/*
+#### JournalMatches
+Description: Have no more than this many messages matching this regexp been
+logged to the journal in this window of time? Messages are filtered by unit and
+priority first, e.g. only messages from the kernel with priority err or worse.
+Parameters:
+  - Unit (string): Unit whose messages to search, or "*" for all units
+  - Priority (string): Least severe priority to search: emerg | alert | crit |
+    err | warning | notice | info | debug, or the equivalent syslog level, 0-7
+  - Regexp (regexp): Regexp to match messages with
+  - Window (time.Duration): How far back in the journal to search
+  - Maximum (int): Most matching messages allowed
+Example parameters:
+  - "*", nginx.service, systemd-journald
+  - err, warning, 3
+  - "Out of memory: Kill(ed)? process", "I/O error", "^panic:"
+  - 10m, 1h, 24h
+  - 0, 5
+Dependencies:
+  - journalctl
+*/
+
+type JournalMatches struct {
+	unit, priority string
+	re             *regexp.Regexp
+	window         time.Duration
+	max            int
+}
+
+func (chk JournalMatches) New(params []string) (chkutil.Check, error) {
+	if len(params) != 5 {
+		return chk, errutil.ParameterLengthError{5, params}
+	}
+	priority := strings.ToLower(params[1])
+	if level, err := strconv.Atoi(priority); err == nil {
+		if level < 0 || level >= len(systemdstatus.Priorities) {
+			return chk, errutil.ParameterTypeError{params[1], "priority"}
+		}
+		priority = systemdstatus.Priorities[level]
+	} else if !tabular.StrIn(priority, systemdstatus.Priorities) {
+		return chk, errutil.ParameterTypeError{params[1], "priority"}
+	}
+	re, err := regexp.Compile(params[2])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[2], "regexp"}
+	}
+	window, err := time.ParseDuration(params[3])
+	if err != nil || window <= 0 {
+		return chk, errutil.ParameterTypeError{params[3], "time.Duration"}
+	}
+	max, err := strconv.Atoi(params[4])
+	if err != nil || max < 0 {
+		return chk, errutil.ParameterTypeError{params[4], "positive int"}
+	}
+	chk.unit = params[0]
+	if chk.unit == "*" {
+		chk.unit = ""
+	}
+	chk.priority = priority
+	chk.re = re
+	chk.window = window
+	chk.max = max
+	return chk, nil
+}
+
+func (chk JournalMatches) Status() (int, string, error) {
+	if err := chkutil.RequireHost("JournalMatches"); err != nil {
+		return 1, "", err
+	}
+	since := time.Now().Add(-chk.window)
+	msgs, err := systemdstatus.JournalMessages(chk.unit, chk.priority, since)
+	if err != nil {
+		return 1, "", err
+	}
+	matched := matchingLines(chk.re, msgs)
+	if len(matched) <= chk.max {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("%d journal messages matched %s in the last %v",
+		len(matched), chk.re.String(), chk.window)
+	return errutil.GenericError(msg, "at most "+strconv.Itoa(chk.max), matched)
+}
+
+/*
+#### LogFileMatches
+Description: Have between this many and this many of the lines appended to this
+log file since the last run matched this regexp? Distributive remembers how far
+it has read into the file (see --state-dir), so each line is only counted once.
+Checks of the same file with different parameters each keep their own place.
+On the first run, the whole file is read. If the file has been rotated, the
+rest of the old file is read from Path.1 if it's there. If it's been
+truncated, it's read from the start.
+Parameters:
+  - Path (filepath): Path to the log file
+  - Regexp (regexp): Regexp to match lines with
+  - Minimum (int): Fewest matching lines allowed, e.g. for a heartbeat
+  - Maximum (int): Most matching lines allowed, or -1 for no limit
+Example parameters:
+  - /var/log/syslog, /var/log/myapp/error.log
+  - "I/O error", "^panic:", "heartbeat ok"
+  - 0, 1
+  - 0, 10, -1
+*/
+
+type LogFileMatches struct {
+	path     string
+	re       *regexp.Regexp
+	min, max int
+	// key identifies the check's state, so that checks of the same file with
+	// different parameters each keep their own place in it
+	key string
+}
+
+// logFileState is how far LogFileMatches has read into a log file
+type logFileState struct {
+	Inode  uint64
+	Offset int64
+}
+
+func (chk LogFileMatches) New(params []string) (chkutil.Check, error) {
+	if len(params) != 4 {
+		return chk, errutil.ParameterLengthError{4, params}
+	}
+	re, err := regexp.Compile(params[1])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[1], "regexp"}
+	}
+	min, err := strconv.Atoi(params[2])
+	if err != nil || min < 0 {
+		return chk, errutil.ParameterTypeError{params[2], "positive int"}
+	}
+	max, err := strconv.Atoi(params[3])
+	if err != nil || max < -1 || (max != -1 && max < min) {
+		return chk, errutil.ParameterTypeError{params[3], "int >= minimum, or -1"}
+	}
+	chk.path = params[0]
+	chk.re = re
+	chk.min = min
+	chk.max = max
+	chk.key = strings.Join(params, "\x00")
+	return chk, nil
+}
+
+// inodeAndSize returns the inode number and size of an open file
+func inodeAndSize(f *os.File) (uint64, int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, fmt.Errorf("Couldn't get inode of %s", f.Name())
+	}
+	return uint64(stat.Ino), info.Size(), nil
+}
+
+// readLinesFrom reads the lines in f after offset. Unless partial is true, a
+// line without a trailing newline is left for the next read, since it may
+// still be being written. It returns how many bytes were consumed.
+func readLinesFrom(f *os.File, offset int64, partial bool) ([]string, int64, error) {
+	if _, err := f.Seek(offset, os.SEEK_SET); err != nil {
+		return nil, 0, err
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !partial {
+		data = data[:bytes.LastIndex(data, []byte("\n"))+1]
+	}
+	consumed := int64(len(data))
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, consumed, nil
+}
+
+// readNewLines returns the complete lines appended to the log at path since
+// the last read, as recorded in state, and the state after this read
+func readNewLines(path string, state logFileState) ([]string, logFileState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, state, err
+	}
+	defer f.Close()
+	ino, size, err := inodeAndSize(f)
+	if err != nil {
+		return nil, state, err
+	}
+	var lines []string
+	offset := state.Offset
+	if state.Inode != 0 && state.Inode != ino {
+		// rotated: finish reading the old file, if it's where logrotate puts it
+		if old, err := os.Open(path + ".1"); err == nil {
+			oldIno, _, err := inodeAndSize(old)
+			if err == nil && oldIno == state.Inode {
+				lines, _, err = readLinesFrom(old, state.Offset, true)
+			}
+			old.Close()
+			if err != nil {
+				return nil, state, err
+			}
+		}
+		offset = 0
+	} else if size < offset {
+		// truncated, e.g. by logrotate's copytruncate
+		offset = 0
+	}
+	newLines, consumed, err := readLinesFrom(f, offset, false)
+	if err != nil {
+		return nil, state, err
+	}
+	return append(lines, newLines...), logFileState{ino, offset + consumed}, nil
+}
+
+func (chk LogFileMatches) Status() (int, string, error) {
+	var state logFileState
+	if _, err := chkutil.LoadState("LogFileMatches", chk.key, &state); err != nil {
+		return 1, "", err
+	}
+	lines, state, err := readNewLines(chkutil.HostPath(chk.path), state)
+	if err != nil {
+		return 1, "", err
+	}
+	if err := chkutil.SaveState("LogFileMatches", chk.key, state); err != nil {
+		return 1, "", err
+	}
+	matched := matchingLines(chk.re, lines)
+	if chk.max != -1 && len(matched) > chk.max {
+		msg := fmt.Sprintf("%d new lines in %s matched %s",
+			len(matched), chk.path, chk.re.String())
+		return errutil.GenericError(msg, "at most "+strconv.Itoa(chk.max), matched)
+	} else if len(matched) < chk.min {
+		msg := fmt.Sprintf("Too few new lines in %s matched %s",
+			chk.path, chk.re.String())
+		actual := []string{strconv.Itoa(len(matched))}
+		return errutil.GenericError(msg, "at least "+strconv.Itoa(chk.min), actual)
+	}
+	return errutil.Success()
+}
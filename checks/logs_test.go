@@ -0,0 +1,95 @@
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+func TestJournalMatches(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"*", "err", "Out of memory: Kill(ed)? process", "10m", "0"},
+		{"nginx.service", "3", "^panic:", "1h", "5"},
+	}
+	invalidInputs := append(notLengthOne,
+		[]string{"*", "loud", "panic", "10m", "0"},
+		[]string{"*", "8", "panic", "10m", "0"},
+		[]string{"*", "err", "(panic", "10m", "0"},
+		[]string{"*", "err", "panic", "10", "0"},
+		[]string{"*", "err", "panic", "10m", "-1"},
+	)
+	testParameters(validInputs, invalidInputs, JournalMatches{}, t)
+}
+
+func TestLogFileMatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distributive-logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	chkutil.SetStateDir(filepath.Join(dir, "state"))
+	defer chkutil.SetStateDir("")
+	path := filepath.Join(dir, "app.log")
+
+	validInputs := [][]string{
+		{path, "ERROR", "0", "0"}, {path, "heartbeat", "1", "-1"},
+	}
+	invalidInputs := append(notLengthOne,
+		[]string{path, "(ERROR", "0", "0"},
+		[]string{path, "ERROR", "-1", "0"},
+		[]string{path, "ERROR", "2", "1"},
+		[]string{path, "ERROR", "0", "-2"},
+	)
+	testParameters(validInputs, invalidInputs, LogFileMatches{}, t)
+
+	chk, err := LogFileMatches{}.New([]string{path, "ERROR", "0", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, data string, flag int) {
+		f, err := os.OpenFile(name, flag|os.O_WRONLY|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectCode := func(step string, expected int) {
+		code, msg, err := chk.Status()
+		if err != nil {
+			t.Fatalf("%s: LogFileMatches failed unexpectedly: %v", step, err)
+		} else if code != expected {
+			t.Errorf("%s: expected exit code %v, got %v: %v", step, expected, code, msg)
+		}
+	}
+	write(path, "start\nERROR one\nERROR two\n", os.O_TRUNC)
+	expectCode("first run", 1)
+	expectCode("no new lines", 0)
+	write(path, "ERROR three\nERROR fo", os.O_APPEND)
+	expectCode("partial line", 0)
+	write(path, "ur\n", os.O_APPEND)
+	expectCode("completed line", 0)
+	write(path, "ERROR five\n", os.O_APPEND)
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(path, "ERROR six\n", os.O_TRUNC)
+	expectCode("rotation", 1)
+	write(path, "fine\n", os.O_TRUNC)
+	expectCode("truncation", 0)
+
+	// a check with other limits keeps its own place in the file
+	write(path, "ERROR seven\n", os.O_APPEND)
+	expectCode("before another check", 0)
+	chk, err = LogFileMatches{}.New([]string{path, "ERROR", "1", "-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectCode("another check's first run", 0)
+}
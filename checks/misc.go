@@ -3,6 +3,8 @@ package checks
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -154,6 +156,9 @@ func (chk Running) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk Running) Status() (int, string, error) {
+	if err := chkutil.RequireHost("Running"); err != nil {
+		return 1, "", err
+	}
 	processes, err := ps.Processes()
 	if err != nil {
 		return 1, "", err
@@ -219,6 +224,9 @@ func parseSensorsOutput(out string) (Temps []int) {
 }
 
 func (chk Temp) Status() (int, string, error) {
+	if err := chkutil.RequireHost("Temp"); err != nil {
+		return 1, "", err
+	}
 	cmd := exec.Command("sensors")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -243,7 +251,7 @@ Parameters:
 Example parameters:
 - hid, drm, rfkill
 Depedencies:
-- `/sbin/lsmod`
+- /proc/modules
 */
 
 type Module struct{ name string }
@@ -258,13 +266,22 @@ func (chk Module) New(params []string) (chkutil.Check, error) {
 
 func (chk Module) Status() (int, string, error) {
 	// kernelModules returns a list of all Modules that are currently loaded
-	// TODO just read from /proc/modules
-	kernelModules := func() (Modules []string) {
-		cmd := exec.Command("/sbin/lsmod")
-		return chkutil.CommandColumnNoHeader(0, cmd)
+	kernelModules := func() (modules []string, err error) {
+		data, err := ioutil.ReadFile(chkutil.ProcPath("modules"))
+		if err != nil {
+			return modules, err
+		}
+		for _, line := range tabular.Lines(string(data)) {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				modules = append(modules, fields[0])
+			}
+		}
+		return modules, nil
 	}
-	modules := kernelModules()
-	if tabular.StrIn(chk.name, modules) {
+	modules, err := kernelModules()
+	if err != nil {
+		return 1, "", err
+	} else if tabular.StrIn(chk.name, modules) {
 		return errutil.Success()
 	}
 	return errutil.GenericError("Module is not loaded", chk.name, modules)
@@ -278,7 +295,7 @@ Parameters:
 Example parameters:
 - "net.ipv6.route.gc_interval", "fs.file-max"
 Depedencies:
-- `/sbin/sysctl`
+- /proc/sys
 */
 
 type KernelParameter struct{ name string }
@@ -292,19 +309,22 @@ func (chk KernelParameter) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk KernelParameter) Status() (int, string, error) {
-	// parameterValue returns the value of a kernel parameter
-	parameterSet := func(name string) bool {
-		cmd := exec.Command("/sbin/sysctl", "-q", "-n", name)
-		out, err := cmd.CombinedOutput()
-		// failed on incorrect module name
-		if err != nil && strings.Contains(err.Error(), "255") {
-			return false
-		} else if err != nil {
-			errutil.ExecError(cmd, string(out), err)
+	// parameterSet asks whether a kernel parameter exists under /proc/sys,
+	// where net.ipv4.ip_forward is at net/ipv4/ip_forward
+	parameterSet := func(name string) (bool, error) {
+		if !strings.Contains(name, "/") {
+			name = strings.Replace(name, ".", "/", -1)
+		}
+		_, err := os.Stat(chkutil.ProcPath("sys", name))
+		if os.IsNotExist(err) {
+			return false, nil
 		}
-		return true
+		return err == nil, err
 	}
-	if parameterSet(chk.name) {
+	set, err := parameterSet(chk.name)
+	if err != nil {
+		return 1, "", err
+	} else if set {
 		return errutil.Success()
 	}
 	return 1, "Kernel parameter not set: " + chk.name, nil
@@ -334,6 +354,9 @@ func (chk PHPConfig) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk PHPConfig) Status() (int, string, error) {
+	if err := chkutil.RequireHost("PHPConfig"); err != nil {
+		return 1, "", err
+	}
 	// getPHPVariable returns the value of a PHP configuration value as a string
 	// or just "" if it doesn't exist
 	getPHPVariable := func(name string) (val string) {
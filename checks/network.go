@@ -1,16 +1,19 @@
 package checks
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net"
-	"os/exec"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/CiscoCloud/distributive/chkutil"
 	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/fsstatus"
 	"github.com/CiscoCloud/distributive/netstatus"
+	"github.com/CiscoCloud/distributive/procstatus"
 	"github.com/CiscoCloud/distributive/tabular"
 	log "github.com/Sirupsen/logrus"
 )
@@ -33,8 +36,8 @@ Parameters:
 Example parameters:
 - 80, 8080, 8500, 5050
 Dependencies:
-- /proc/net/tcp
-- /proc/net/udp
+- /proc/net/tcp, /proc/net/tcp6
+- /proc/net/udp, /proc/net/udp6
 */
 
 type Port struct{ port uint16 }
@@ -50,6 +53,12 @@ func init() {
 	chkutil.Register("PortUDP", func() chkutil.Check {
 		return &PortUDP{}
 	})
+	chkutil.Register("PortOwner", func() chkutil.Check {
+		return &PortOwner{}
+	})
+	chkutil.Register("ListeningAllowlist", func() chkutil.Check {
+		return &ListeningAllowlist{}
+	})
 	chkutil.Register("Up", func() chkutil.Check {
 		return &Up{}
 	})
@@ -95,6 +104,15 @@ func init() {
 	chkutil.Register("UDP", func() chkutil.Check {
 		return &UDP{}
 	})
+	chkutil.Register("TCPLatency", func() chkutil.Check {
+		return &TCPLatency{}
+	})
+	chkutil.Register("UDPLatency", func() chkutil.Check {
+		return &UDPLatency{}
+	})
+	chkutil.Register("TLSCertExpiry", func() chkutil.Check {
+		return &TLSCertExpiry{}
+	})
 }
 
 func (chk Port) New(params []string) (chkutil.Check, error) {
@@ -109,10 +127,15 @@ func (chk Port) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk Port) Status() (int, string, error) {
-	if netstatus.PortOpen("tcp", chk.port) || netstatus.PortOpen("udp", chk.port) {
-		return errutil.Success()
+	for _, protocol := range []string{"tcp", "udp"} {
+		open, err := netstatus.PortListening(protocol, chk.port)
+		if err != nil {
+			return 1, "", err
+		} else if open {
+			return errutil.Success()
+		}
 	}
-	return 1, fmt.Sprintf("Port not open: %s", chk.port), nil
+	return 1, fmt.Sprintf("Port not open: %d", chk.port), nil
 }
 
 /*
@@ -123,7 +146,7 @@ Parameters:
 Example parameters:
 - 80, 8080, 8500, 5050
 Dependencies:
-- /proc/net/tcp
+- /proc/net/tcp, /proc/net/tcp6
 */
 
 type PortTCP struct{ port uint16 }
@@ -140,10 +163,13 @@ func (chk PortTCP) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk PortTCP) Status() (int, string, error) {
-	if netstatus.PortOpen("tcp", chk.port) {
+	open, err := netstatus.PortListening("tcp", chk.port)
+	if err != nil {
+		return 1, "", err
+	} else if open {
 		return errutil.Success()
 	}
-	return 1, fmt.Sprintf("Port not open: %s", chk.port), nil
+	return 1, fmt.Sprintf("Port not open: %d", chk.port), nil
 }
 
 /*
@@ -154,7 +180,7 @@ Parameters:
 Example parameters:
 - 80, 8080, 8500, 5050
 Dependencies:
-- /proc/net/udp
+- /proc/net/udp, /proc/net/udp6
 */
 
 type PortUDP struct{ port uint16 }
@@ -171,10 +197,381 @@ func (chk PortUDP) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk PortUDP) Status() (int, string, error) {
-	if netstatus.PortOpen("udp", chk.port) {
+	open, err := netstatus.PortListening("udp", chk.port)
+	if err != nil {
+		return 1, "", err
+	} else if open {
+		return errutil.Success()
+	}
+	return 1, fmt.Sprintf("Port not open: %d", chk.port), nil
+}
+
+// listener is a listening socket, and the processes that have it open
+type listener struct {
+	netstatus.Socket
+	procs []procstatus.Process
+}
+
+// listeners returns the sockets listening over protocol ("tcp" | "udp"), with
+// the processes that own them. Processes can only be found for sockets that
+// distributive has permission to see the file descriptors of, so usually only
+// as root.
+func listeners(protocol string) (ls []listener, err error) {
+	socks, err := netstatus.ListeningSockets(protocol)
+	if err != nil {
+		return ls, err
+	}
+	if socks, err = netstatus.WithOwners(socks); err != nil {
+		return ls, err
+	}
+	for _, sock := range socks {
+		l := listener{Socket: sock}
+		for _, pid := range sock.PIDs {
+			// the process may have exited since its sockets were read
+			if proc, err := procstatus.ReadProcess(pid); err == nil {
+				l.procs = append(l.procs, proc)
+			}
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
+
+// address is the address and port the socket is bound to, e.g. 127.0.0.1:5432
+func (l listener) address() string {
+	return net.JoinHostPort(l.LocalIP.String(), strconv.Itoa(int(l.LocalPort)))
+}
+
+// String describes the listener, like "tcp 127.0.0.1:5432 postgres[812]
+// (postgresql.service) user postgres"
+func (l listener) String() string {
+	str := strings.TrimSuffix(l.Protocol, "6") + " " + l.address()
+	for _, proc := range l.procs {
+		str += " " + proc.String()
+		if proc.Unit != "" {
+			str += " (" + proc.Unit + ")"
+		}
+	}
+	return str + " user " + userName(l.UID)
+}
+
+// ownerUnit adds a .service suffix to units given without a suffix
+func ownerUnit(unit string) string {
+	if !strings.Contains(unit, ".") {
+		return unit + ".service"
+	}
+	return unit
+}
+
+/*
+#### PortOwner
+Description: Is this port listened on by the expected process, and only on the
+expected addresses? Every socket listening on the port is checked, and every
+process that has one open must match. The check fails if nothing is listening.
+Every parameter after the port is an option, written as name=value:
+- exe: Name of the process' executable, e.g. postgres, or its full path
+- unit: systemd unit the process is in, e.g. postgresql.service
+- user: User that owns the socket, by name or UID
+- address: IP address that sockets may be bound to (may be repeated), e.g.
+  127.0.0.1 or ::1. A socket bound to 0.0.0.0 or :: only matches an address
+  option of 0.0.0.0 or ::.
+Parameters:
+- Protocol (string): tcp | udp
+- Number (uint16): Port number (decimal)
+- Options (name=value, at least one)
+Example parameters:
+- tcp, udp
+- 5432, 53
+- exe=postgres, unit=postgresql.service, user=postgres, address=127.0.0.1
+Dependencies:
+- /proc/net/{tcp,tcp6,udp,udp6}, and /proc/PID/fd to find the processes, which
+  usually requires root
+*/
+
+type PortOwner struct {
+	protocol        string
+	port            uint16
+	exe, unit, user string
+	addresses       []net.IP
+}
+
+func (chk PortOwner) New(params []string) (chkutil.Check, error) {
+	if len(params) < 3 {
+		return chk, errutil.ParameterLengthError{3, params}
+	}
+	chk.protocol = strings.ToLower(params[0])
+	if chk.protocol != "tcp" && chk.protocol != "udp" {
+		return chk, errutil.ParameterTypeError{params[0], "tcp | udp"}
+	}
+	port, err := parsePort(params[1])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[1], "uint16"}
+	}
+	chk.port = port
+	for _, option := range params[2:] {
+		i := strings.Index(option, "=")
+		if i < 1 {
+			return chk, errutil.ParameterTypeError{option, "name=value"}
+		}
+		name, value := option[:i], option[i+1:]
+		switch name {
+		case "exe":
+			chk.exe = value
+		case "unit":
+			chk.unit = ownerUnit(value)
+		case "user":
+			if !validUsername(value) {
+				return chk, errutil.ParameterTypeError{option, "username or UID"}
+			}
+			chk.user = value
+		case "address":
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return chk, errutil.ParameterTypeError{option, "IP address"}
+			}
+			chk.addresses = append(chk.addresses, ip)
+		default:
+			return chk, errutil.ParameterTypeError{option, "PortOwner option"}
+		}
+	}
+	return chk, nil
+}
+
+// ownerMatches reports whether a process matches the exe and unit options
+func (chk PortOwner) ownerMatches(proc procstatus.Process) bool {
+	if chk.exe != "" {
+		if strings.Contains(chk.exe, "/") && proc.Exe != chk.exe {
+			return false
+		} else if !strings.Contains(chk.exe, "/") && proc.Name() != chk.exe {
+			return false
+		}
+	}
+	return chk.unit == "" || proc.Unit == chk.unit
+}
+
+// addressAllowed reports whether a socket's bind address is one of the
+// address options, if there are any
+func (chk PortOwner) addressAllowed(ip net.IP) bool {
+	if len(chk.addresses) == 0 {
+		return true
+	}
+	for _, allowed := range chk.addresses {
+		if allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (chk PortOwner) Status() (int, string, error) {
+	uid := -1
+	if chk.user != "" {
+		var err error
+		if uid, err = userID(chk.user); err != nil {
+			return 1, "", err
+		}
+	}
+	ls, err := listeners(chk.protocol)
+	if err != nil {
+		return 1, "", err
+	}
+	var matched []listener
+	for _, l := range ls {
+		if l.LocalPort == chk.port {
+			matched = append(matched, l)
+		}
+	}
+	if len(matched) < 1 {
+		return 1, fmt.Sprintf("Port not open: %d/%s", chk.port, chk.protocol), nil
+	}
+	for _, l := range matched {
+		if !chk.addressAllowed(l.LocalIP) {
+			msg := fmt.Sprintf("Port %d/%s was bound to an unexpected address",
+				chk.port, chk.protocol)
+			return errutil.GenericError(msg, chk.addresses, []string{l.String()})
+		}
+		if uid >= 0 && l.UID != uid {
+			msg := fmt.Sprintf("Port %d/%s was owned by an unexpected user",
+				chk.port, chk.protocol)
+			return errutil.GenericError(msg, chk.user, []string{l.String()})
+		}
+		if chk.exe == "" && chk.unit == "" {
+			continue
+		} else if len(l.procs) < 1 {
+			err := fmt.Errorf("Couldn't find the process listening on %s, "+
+				"distributive may need to run as root", l.address())
+			return 1, "", err
+		}
+		for _, proc := range l.procs {
+			if !chk.ownerMatches(proc) {
+				msg := fmt.Sprintf("Port %d/%s was owned by an unexpected process",
+					chk.port, chk.protocol)
+				var specified []string
+				if chk.exe != "" {
+					specified = append(specified, "exe="+chk.exe)
+				}
+				if chk.unit != "" {
+					specified = append(specified, "unit="+chk.unit)
+				}
+				return errutil.GenericError(msg, strings.Join(specified, " "),
+					[]string{l.String()})
+			}
+		}
+	}
+	return errutil.Success()
+}
+
+// allowedListener is an entry in ListeningAllowlist
+type allowedListener struct {
+	protocol         string
+	address          net.IP // nil for any address
+	minPort, maxPort uint16
+	process          string // exe name or systemd unit, empty for any
+}
+
+// parseAllowedListener parses an entry like "tcp 127.0.0.1:5432 postgres"
+func parseAllowedListener(entry string) (allowed allowedListener, err error) {
+	invalid := errutil.ParameterTypeError{entry, "protocol [address:]port [process]"}
+	fields := strings.Fields(entry)
+	if len(fields) != 2 && len(fields) != 3 {
+		return allowed, invalid
+	}
+	allowed.protocol = strings.ToLower(fields[0])
+	if allowed.protocol != "tcp" && allowed.protocol != "udp" {
+		return allowed, invalid
+	}
+	address, ports := "*", fields[1]
+	if strings.Contains(fields[1], ":") {
+		if address, ports, err = net.SplitHostPort(fields[1]); err != nil {
+			return allowed, invalid
+		}
+	}
+	if address != "*" {
+		if allowed.address = net.ParseIP(address); allowed.address == nil {
+			return allowed, invalid
+		}
+	}
+	portRange := strings.SplitN(ports, "-", 2)
+	if allowed.minPort, err = parsePort(portRange[0]); err != nil {
+		return allowed, invalid
+	}
+	allowed.maxPort = allowed.minPort
+	if len(portRange) == 2 {
+		allowed.maxPort, err = parsePort(portRange[1])
+		if err != nil || allowed.maxPort < allowed.minPort {
+			return allowed, invalid
+		}
+	}
+	if len(fields) == 3 {
+		allowed.process = fields[2]
+	}
+	return allowed, nil
+}
+
+// matchesSocket reports whether the listener's protocol, address and port are
+// allowed, regardless of its process
+func (allowed allowedListener) matchesSocket(l listener) bool {
+	if strings.TrimSuffix(l.Protocol, "6") != allowed.protocol {
+		return false
+	} else if allowed.address != nil && !allowed.address.Equal(l.LocalIP) {
+		return false
+	}
+	return l.LocalPort >= allowed.minPort && l.LocalPort <= allowed.maxPort
+}
+
+// matchesProcess reports whether any of the listener's processes is allowed
+func (allowed allowedListener) matchesProcess(l listener) bool {
+	if allowed.process == "" {
+		return true
+	}
+	for _, proc := range l.procs {
+		if proc.Name() == allowed.process || proc.Unit == allowed.process {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+#### ListeningAllowlist
+Description: Is every TCP and UDP socket listening on this host in this
+allowlist? Each entry is "protocol [address:]port [process]". The address may
+be * for any address, or left out, and the port may be a range, like
+32768-60999. The process is the name of the executable or the systemd unit
+that owns the socket, and may be left out to allow any process. Note that
+unconnected UDP sockets are all treated as listening, including some clients'.
+The check fails with a list of the listeners that aren't allowed.
+Parameters:
+- Entries ([]string): Listeners that are allowed
+Example parameters:
+- "tcp 22 sshd", "tcp 127.0.0.1:5432 postgres", "udp 127.0.0.53:53
+  systemd-resolved.service", "tcp [::1]:25", "udp *:68 dhclient"
+Dependencies:
+- /proc/net/{tcp,tcp6,udp,udp6}, and /proc/PID/fd to find the processes, which
+  usually requires root
+*/
+
+type ListeningAllowlist struct{ allowed []allowedListener }
+
+func (chk ListeningAllowlist) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	for _, param := range params {
+		allowed, err := parseAllowedListener(param)
+		if err != nil {
+			return chk, err
+		}
+		chk.allowed = append(chk.allowed, allowed)
+	}
+	return chk, nil
+}
+
+// unexpected returns the listeners that no entry in the allowlist matches. It
+// fails if a listener's process needs checking, but can't be found.
+func (chk ListeningAllowlist) unexpected(ls []listener) (unexpected []string, err error) {
+	for _, l := range ls {
+		allowed, unknownProcess := false, false
+		for _, entry := range chk.allowed {
+			if !entry.matchesSocket(l) {
+				continue
+			} else if entry.matchesProcess(l) {
+				allowed = true
+				break
+			} else if len(l.procs) < 1 {
+				unknownProcess = true
+			}
+		}
+		if allowed {
+			continue
+		} else if unknownProcess {
+			return unexpected, fmt.Errorf("Couldn't find the process listening "+
+				"on %s, distributive may need to run as root", l.address())
+		}
+		if !tabular.StrIn(l.String(), unexpected) {
+			unexpected = append(unexpected, l.String())
+		}
+	}
+	return unexpected, nil
+}
+
+func (chk ListeningAllowlist) Status() (int, string, error) {
+	var ls []listener
+	for _, protocol := range []string{"tcp", "udp"} {
+		protocolListeners, err := listeners(protocol)
+		if err != nil {
+			return 1, "", err
+		}
+		ls = append(ls, protocolListeners...)
+	}
+	unexpected, err := chk.unexpected(ls)
+	if err != nil {
+		return 1, "", err
+	} else if len(unexpected) == 0 {
 		return errutil.Success()
 	}
-	return 1, fmt.Sprintf("Port not open: %s", chk.port), nil
+	msg := fmt.Sprintf("%d listeners weren't in the allowlist", len(unexpected))
+	return errutil.GenericError(msg, "none", unexpected)
 }
 
 /*
@@ -347,22 +744,14 @@ func (chk Gateway) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk Gateway) Status() (int, string, error) {
-	// getGatewayAddress filters all Gateway IPs for a non-zero value
-	getGatewayAddress := func() (addr string) {
-		ips := RoutingTableColumn("Gateway")
-		for _, ip := range ips {
-			if ip != "0.0.0.0" {
-				return ip
-			}
-		}
-		return "0.0.0.0"
-	}
-	GatewayIP := getGatewayAddress()
-	if chk.ip.String() == GatewayIP {
+	route, err := netstatus.DefaultRoute()
+	if err != nil {
+		return 1, "", err
+	} else if chk.ip.Equal(route.Gateway) {
 		return errutil.Success()
 	}
 	msg := "Gateway does not have address"
-	return errutil.GenericError(msg, chk.ip.String(), []string{GatewayIP})
+	return errutil.GenericError(msg, chk.ip.String(), []string{route.Gateway.String()})
 }
 
 /*
@@ -385,26 +774,14 @@ func (chk GatewayInterface) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk GatewayInterface) Status() (int, string, error) {
-	// getGatewayInterface returns the interface that the default Gateway is
-	// operating on
-	getGatewayInterface := func() (iface string) {
-		ips := RoutingTableColumn("Gateway")
-		names := RoutingTableColumn("Iface")
-		for i, ip := range ips {
-			if ip != "0.0.0.0" {
-				msg := "Fewer names in kernel routing table than IPs"
-				errutil.IndexError(msg, i, names)
-				return names[i] // interface name
-			}
-		}
-		return ""
-	}
-	iface := getGatewayInterface()
-	if chk.name == iface {
+	route, err := netstatus.DefaultRoute()
+	if err != nil {
+		return 1, "", err
+	} else if chk.name == route.Interface {
 		return errutil.Success()
 	}
 	msg := "Default Gateway does not operate on interface"
-	return errutil.GenericError(msg, chk.name, []string{iface})
+	return errutil.GenericError(msg, chk.name, []string{route.Interface})
 }
 
 /*
@@ -450,7 +827,7 @@ func (chk TCP) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk TCP) Status() (int, string, error) {
-	if _, err := net.Dial("tcp", chk.address); err == nil {
+	if netstatus.CanConnect(chk.address, "TCP", 0) {
 		return errutil.Success()
 	}
 	return 1, fmt.Sprintf("Couldn't connect to %s", chk.address), nil
@@ -472,15 +849,31 @@ func (chk UDP) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UDP) Status() (int, string, error) {
-	if _, err := net.Dial("udp", chk.address); err == nil {
+	if netstatus.CanConnect(chk.address, "UDP", 0) {
 		return errutil.Success()
 	}
 	return 1, fmt.Sprintf("Couldn't connect to %s", chk.address), nil
 }
 
+// connectTimeout runs a single timed connection attempt for TCPTimeout, and
+// logs how long it took
+func connectTimeout(protocol, address string, timeout time.Duration) (int, string, error) {
+	latency := netstatus.MeasureLatency(protocol, address, 1, timeout)
+	if latency.Failures > 0 {
+		msg := fmt.Sprintf("Couldn't connect to %s within %v", address, timeout)
+		return 1, msg, nil
+	}
+	log.WithFields(log.Fields{
+		"address": address,
+		"latency": latency.Max().String(),
+	}).Info("Connected to " + protocol + " address")
+	return errutil.Success()
+}
+
 /*
 #### TCPTimeout
-Description: Like TCP, but with a second parameter of a timeout
+Description: Like TCP, but with a second parameter of a timeout. How long the
+connection took is logged. See TCPLatency to set thresholds on it.
 Example parameters:
 - 5s, 7μs, 12m, 5h, 3d
 */
@@ -504,15 +897,14 @@ func (chk TCPTimeout) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk TCPTimeout) Status() (int, string, error) {
-	if _, err := net.DialTimeout("udp", chk.address, chk.timeout); err == nil {
-		return errutil.Success()
-	}
-	return 1, fmt.Sprintf("Couldn't connect to %s", chk.address), nil
+	return connectTimeout("TCP", chk.address, chk.timeout)
 }
 
 /*
 #### UDPTimeout
-Description: Like TCPTimeout, but with UDP
+Description: Like TCPTimeout, but with UDP. UDP has no handshake, so this
+only checks that a socket to the address can be opened in time, without
+waiting for a reply. See UDPLatency to probe the service.
 */
 
 type UDPTimeout struct {
@@ -534,32 +926,171 @@ func (chk UDPTimeout) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UDPTimeout) Status() (int, string, error) {
-	if _, err := net.DialTimeout("udp", chk.address, chk.timeout); err == nil {
-		return errutil.Success()
+	conn, err := net.DialTimeout("udp", chk.address, chk.timeout)
+	if err != nil {
+		msg := fmt.Sprintf("Couldn't connect to %s within %v", chk.address, chk.timeout)
+		return 1, msg, nil
 	}
-	return 1, fmt.Sprintf("Couldn't connect to %s", chk.address), nil
+	conn.Close()
+	return errutil.Success()
+}
+
+// defaultLatencySamples is how many times latency checks connect by default
+const defaultLatencySamples = 3
+
+// connectLatency holds the parameters shared by TCPLatency and UDPLatency
+type connectLatency struct {
+	address    string
+	warn, crit time.Duration
+	samples    int
+}
+
+// parse validates and stores the parameters of a latency check
+func (chk *connectLatency) parse(params []string) error {
+	if len(params) != 3 && len(params) != 4 {
+		return errutil.ParameterLengthError{3, params}
+	}
+	warn, err := time.ParseDuration(params[1])
+	if err != nil || warn <= 0 {
+		return errutil.ParameterTypeError{params[1], "time.Duration"}
+	}
+	crit, err := time.ParseDuration(params[2])
+	if err != nil || crit < warn {
+		return errutil.ParameterTypeError{params[2], "time.Duration >= warning"}
+	}
+	chk.samples = defaultLatencySamples
+	if len(params) == 4 {
+		chk.samples, err = strconv.Atoi(params[3])
+		if err != nil || chk.samples < 1 {
+			return errutil.ParameterTypeError{params[3], "positive int"}
+		}
+	}
+	chk.address = params[0]
+	chk.warn = warn
+	chk.crit = crit
+	return nil
+}
+
+// status connects to the address and compares the average latency with the
+// thresholds. Each attempt gives up after twice the critical threshold, so that
+// slow connections are still measured.
+func (chk connectLatency) status(protocol string) (int, string, error) {
+	latency := netstatus.MeasureLatency(protocol, chk.address, chk.samples, 2*chk.crit)
+	log.WithFields(log.Fields{
+		"address": chk.address,
+		"min":     latency.Min().String(),
+		"avg":     latency.Avg().String(),
+		"max":     latency.Max().String(),
+		"loss":    latency.Loss(),
+	}).Info("Measured " + protocol + " latency")
+	actual := []string{latency.String()}
+	avg := latency.Avg()
+	switch {
+	case latency.Failures == latency.Samples:
+		msg := fmt.Sprintf("Couldn't connect to %s", chk.address)
+		return errutil.GenericError(msg, "loss < 100%", actual)
+	case len(latency.Times) == 0:
+		// nothing replied to a UDP datagram, so the host may be down
+		msg := fmt.Sprintf("Couldn't measure latency to %s, no attempt got a reply",
+			chk.address)
+		return errutil.GenericError(msg, "at least one reply", actual)
+	case avg > chk.crit:
+		msg := fmt.Sprintf("%s latency to %s was above critical threshold",
+			protocol, chk.address)
+		return errutil.GenericError(msg, "avg <= "+chk.crit.String(), actual)
+	case avg > chk.warn:
+		msg := fmt.Sprintf("%s latency to %s was above warning threshold",
+			protocol, chk.address)
+		return errutil.Warning(msg, "avg <= "+chk.warn.String(), actual)
+	case latency.Failures > 0:
+		msg := fmt.Sprintf("Some connections to %s failed", chk.address)
+		return errutil.Warning(msg, "loss = 0%", actual)
+	}
+	return errutil.Success()
+}
+
+/*
+#### TCPLatency
+Description: Does it take less than these thresholds to connect to this address
+over TCP, on average? The address is connected to several times, and the
+min/avg/max latency and loss are logged. If the average is above the warning
+threshold, or some (but not all) attempts fail, the check returns a warning
+(exit code 2). It fails if the average is above the critical threshold, or every
+attempt fails.
+Parameters:
+- Address (host:port): Address to connect to
+- Warning (time.Duration): Average latency above which to warn
+- Critical (time.Duration): Average latency above which to fail. Each attempt
+  gives up after twice this long.
+- Samples (positive int, optional): How many times to connect, 3 by default
+Example parameters:
+- db.example.com:5432, 10.0.0.3:6379
+- 50ms, 200ms
+- 500ms, 1s
+- 5, 10
+*/
+
+type TCPLatency struct{ connectLatency }
+
+func (chk TCPLatency) New(params []string) (chkutil.Check, error) {
+	err := chk.parse(params)
+	return chk, err
+}
+
+func (chk TCPLatency) Status() (int, string, error) {
+	return chk.status("TCP")
+}
+
+/*
+#### UDPLatency
+Description: Like TCPLatency, but over UDP. An empty datagram is sent, and the
+time it takes to get a reply is measured. Many services don't reply to it;
+attempts that get no reply aren't timed, but aren't counted as lost either.
+Only attempts the host refuses are. The check fails if no attempt gets a reply,
+since the latency can't be measured.
+*/
+
+type UDPLatency struct{ connectLatency }
+
+func (chk UDPLatency) New(params []string) (chkutil.Check, error) {
+	err := chk.parse(params)
+	return chk, err
+}
+
+func (chk UDPLatency) Status() (int, string, error) {
+	return chk.status("UDP")
 }
 
-// returns a column of the routing table as a slice of strings
-// TODO read from /proc/net/route instead
-func RoutingTableColumn(name string) []string {
-	cmd := exec.Command("route", "-n")
-	out := chkutil.CommandOutput(cmd)
-	table := tabular.ProbabalisticSplit(out)
-	if len(table) < 1 {
-		log.WithFields(log.Fields{
-			"column": name,
-			"table":  "\n" + tabular.ToString(table),
-		}).Fatal("Routing table was not available or not properly parsed")
+// RoutingTableColumn returns a column of the kernel's IPv4 and IPv6 routing
+// tables: Destination | Gateway | Genmask | Iface
+func RoutingTableColumn(name string) (column []string, err error) {
+	routes, err := netstatus.Routes()
+	if err != nil {
+		return column, err
+	}
+	for _, route := range routes {
+		switch name {
+		case "Destination":
+			column = append(column, route.Destination.IP.String())
+		case "Gateway":
+			column = append(column, route.Gateway.String())
+		case "Genmask":
+			column = append(column, net.IP(route.Destination.Mask).String())
+		case "Iface":
+			column = append(column, route.Interface)
+		default:
+			return column, fmt.Errorf("Invalid routing table column: %v", name)
+		}
 	}
-	finalTable := table[1:] // has extra line before headers
-	return tabular.GetColumnByHeader(name, finalTable)
+	return column, nil
 }
 
 // RoutingTableMatch asks: Is this value in this column of the routing table?
 func RoutingTableMatch(col string, str string) (int, string, error) {
-	column := RoutingTableColumn(col)
-	if tabular.StrIn(str, column) {
+	column, err := RoutingTableColumn(col)
+	if err != nil {
+		return 1, "", err
+	} else if tabular.StrIn(str, column) {
 		return errutil.Success()
 	}
 	return errutil.GenericError("Not found in routing table", str, column)
@@ -573,7 +1104,7 @@ Parameters:
 Example parameters:
 - 192.168.0.21, 222.111.0.22
 Dependencies:
-- `route -n`
+- /proc/net/route, /proc/net/ipv6_route
 */
 
 type RoutingTableDestination struct{ ip net.IP }
@@ -590,7 +1121,7 @@ func (chk RoutingTableDestination) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk RoutingTableDestination) Status() (int, string, error) {
-	return RoutingTableMatch("Destination", chk.ip.To4().String())
+	return RoutingTableMatch("Destination", chk.ip.String())
 }
 
 /*
@@ -601,7 +1132,7 @@ Parameters:
 Example parameters:
 - lo, wlp1s0, docker0
 Dependencies:
-- `route -n`
+- /proc/net/route, /proc/net/ipv6_route
 */
 
 type RoutingTableInterface struct{ name string }
@@ -628,7 +1159,7 @@ Example parameters:
 */
 
 // routeTableGateway checks if an IP address is a Gateway's IP in the
-// kernel's IP routing table, as accessed by `route -n`.
+// kernel's IP routing table, as read from /proc/net/route.
 type RoutingTableGateway struct{ name string }
 
 func (chk RoutingTableGateway) New(params []string) (chkutil.Check, error) {
@@ -640,23 +1171,28 @@ func (chk RoutingTableGateway) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk RoutingTableGateway) Status() (int, string, error) {
+	// normalize the address, so it matches however it was written
+	if ip := net.ParseIP(chk.name); ip != nil {
+		return RoutingTableMatch("Gateway", ip.String())
+	}
 	return RoutingTableMatch("Gateway", chk.name)
 }
 
 // ResponseMatchesGeneral is an abstraction of ResponseMatches and
-// ResponseMatchesInsecure that simply varies in the security of the connection
+// ResponseMatchesInsecure that simply varies in the security of the connection.
+// It's an HTTP check that accepts any status code.
 func ResponseMatchesGeneral(urlstr string, re *regexp.Regexp, secure bool) (int, string, error) {
-	body := chkutil.URLToBytes(urlstr, secure)
-	if re.Match(body) {
-		return errutil.Success()
-	}
-	msg := "Response didn't match regexp"
-	return errutil.GenericError(msg, re.String(), []string{string(body)})
+	chk := newHTTP(urlstr)
+	chk.statuses = []string{"xxx"}
+	chk.bodyRe = re
+	chk.insecure = !secure
+	return chk.Status()
 }
 
 /*
 #### ResponseMatches
-Description: Does the response from this URL match this regexp?
+Description: Does the response from this URL match this regexp? This is the
+same as an HTTP check with the options status=xxx and body-matches=Regexp.
 Parameters:
 - URL (URL string)
 - Regexp (regexp)
@@ -690,7 +1226,8 @@ func (chk ResponseMatches) Status() (int, string, error) {
 
 /*
 #### ResponseMatchesInsecure
-Description: Like ResponseMatches, but without SSL certificate validation
+Description: Like ResponseMatches, but without SSL certificate validation, i.e.
+with the HTTP option insecure=true
 */
 
 type ResponseMatchesInsecure struct {
@@ -715,3 +1252,92 @@ func (chk ResponseMatchesInsecure) New(params []string) (chkutil.Check, error) {
 func (chk ResponseMatchesInsecure) Status() (int, string, error) {
 	return ResponseMatchesGeneral(chk.urlstr, chk.re, false)
 }
+
+// certExpiryStatus fails if any of certs expires within days. Intermediates
+// are included, since an expired intermediate breaks the chain just as well.
+func certExpiryStatus(certs []*x509.Certificate, days int) (int, string, error) {
+	soonest := certs[0]
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(soonest.NotAfter) {
+			soonest = cert
+		}
+	}
+	remaining := soonest.NotAfter.Sub(time.Now())
+	if remaining >= time.Duration(days)*24*time.Hour {
+		return errutil.Success()
+	}
+	msg := "Certificate expires too soon: " + soonest.Subject.CommonName
+	if remaining < 0 {
+		msg = "Certificate has expired: " + soonest.Subject.CommonName
+	}
+	actual := fmt.Sprintf("%d days (%s)", int(remaining.Hours()/24),
+		soonest.NotAfter.Format(time.RFC3339))
+	return errutil.GenericError(msg, fmt.Sprintf("at least %d days", days),
+		[]string{actual})
+}
+
+/*
+#### TLSCertExpiry
+Description: Does the TLS server at this address present a valid certificate
+chain that won't expire for at least this many days? The chain is verified
+against the system's root CAs, or a CA bundle.
+Parameters:
+- Address (host:port): Address of the TLS server
+- Days (positive int): Fewest days the certificates may have left
+- ServerName (string, optional): Name to request with SNI and verify the
+  certificate against, defaults to the host in the address
+- CA bundle (filepath, optional): PEM file of CAs to verify the chain against
+Example parameters:
+- example.com:443, 10.0.0.5:8443
+- 14, 30
+- example.com, internal.example.com
+- /etc/pki/internal-ca.pem
+*/
+
+type TLSCertExpiry struct {
+	address, serverName, caBundle string
+	days                          int
+}
+
+func (chk TLSCertExpiry) New(params []string) (chkutil.Check, error) {
+	if len(params) < 2 || len(params) > 4 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	host, _, err := net.SplitHostPort(params[0])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[0], "host:port"}
+	}
+	days, err := strconv.Atoi(params[1])
+	if err != nil || days < 0 {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.address = params[0]
+	chk.days = days
+	chk.serverName = host
+	if len(params) > 2 && params[2] != "" {
+		chk.serverName = params[2]
+	}
+	if len(params) > 3 {
+		chk.caBundle = params[3]
+	}
+	return chk, nil
+}
+
+func (chk TLSCertExpiry) Status() (int, string, error) {
+	var roots *x509.CertPool
+	if chk.caBundle != "" {
+		pool, err := fsstatus.ReadCertPool(chkutil.HostPath(chk.caBundle))
+		if err != nil {
+			return 1, "", err
+		}
+		roots = pool
+	}
+	certs, err := netstatus.PeerCertificates(chk.address, chk.serverName, 10*time.Second)
+	if err != nil {
+		return 1, "Couldn't get certificates from " + chk.address + ": " + err.Error(), nil
+	}
+	if err := netstatus.VerifyChain(certs, chk.serverName, roots); err != nil {
+		return 1, "Certificate didn't verify: " + err.Error(), nil
+	}
+	return certExpiryStatus(certs, chk.days)
+}
@@ -1,7 +1,27 @@
 package checks
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/CiscoCloud/distributive/netstatus"
+	"github.com/CiscoCloud/distributive/procstatus"
 )
 
 var validHosts = [][]string{{"eff.org"}, {"mozilla.org"}, {"golang.org"}}
@@ -51,6 +71,110 @@ func TestPortUDP(t *testing.T) {
 	testCheck([][]string{}, closedPorts, PortUDP{}, t)
 }
 
+func TestPortOwner(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	exe, err := os.Readlink("/proc/self/exe")
+	if err != nil {
+		t.Skip("Couldn't read this process' executable: " + err.Error())
+	}
+	uid := strconv.Itoa(os.Getuid())
+	_, closedPort, _ := net.SplitHostPort(closedAddress(t, "tcp"))
+	validInputs := [][]string{
+		{"tcp", "5432", "exe=postgres", "address=127.0.0.1", "address=::1"},
+		{"UDP", "53", "unit=systemd-resolved", "user=systemd-resolve"},
+	}
+	invalidInputs := [][]string{
+		{}, {"tcp", "5432"},
+		{"sctp", "5432", "exe=postgres"},
+		{"tcp", "port", "exe=postgres"},
+		{"tcp", "5432", "postgres"},
+		{"tcp", "5432", "address=localhost"},
+		{"tcp", "5432", "user=a:b"},
+		{"tcp", "5432", "pid=1"},
+	}
+	goodEggs := [][]string{
+		{"tcp", port, "exe=" + filepath.Base(exe)},
+		{"tcp", port, "exe=" + exe, "user=" + uid, "address=127.0.0.1"},
+		{"tcp", port, "address=::1", "address=127.0.0.1"},
+	}
+	badEggs := [][]string{
+		{"tcp", closedPort, "user=" + uid},
+		{"udp", port, "user=" + uid},
+		{"tcp", port, "exe=postgres"},
+		{"tcp", port, "exe=/usr/bin/" + filepath.Base(exe)},
+		{"tcp", port, "unit=nonexistent.service"},
+		{"tcp", port, "user=" + strconv.Itoa(os.Getuid()+1)},
+		{"tcp", port, "address=0.0.0.0"},
+	}
+	testParameters(validInputs, invalidInputs, PortOwner{}, t)
+	testCheck(goodEggs, badEggs, PortOwner{}, t)
+}
+
+func TestListeningAllowlist(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"tcp 22 sshd", "tcp 127.0.0.1:5432 postgres"},
+		{"UDP *:68 dhclient", "tcp [::1]:25", "udp 32768-60999"},
+	}
+	invalidInputs := [][]string{
+		{},
+		{"tcp"},
+		{"sctp 22"},
+		{"tcp 22 sshd extra"},
+		{"tcp localhost:22"},
+		{"tcp ::1:25"},
+		{"tcp 70000"},
+		{"udp 60999-32768"},
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	goodEggs := [][]string{{"tcp 0-65535", "udp 0-65535"}}
+	badEggs := [][]string{{"tcp 1", "udp 0-65535"}}
+	testParameters(validInputs, invalidInputs, ListeningAllowlist{}, t)
+	testCheck(goodEggs, badEggs, ListeningAllowlist{}, t)
+
+	sock := func(protocol, ip string, port uint16) netstatus.Socket {
+		return netstatus.Socket{Protocol: protocol, LocalIP: net.ParseIP(ip), LocalPort: port}
+	}
+	sshd := procstatus.Process{PID: 800, Exe: "/usr/sbin/sshd", Unit: "ssh.service"}
+	ls := []listener{
+		{sock("tcp", "0.0.0.0", 22), []procstatus.Process{sshd}},
+		{sock("tcp6", "::", 22), []procstatus.Process{sshd}},
+		{sock("udp", "127.0.0.53", 53), nil},
+		{sock("udp", "0.0.0.0", 40000), nil},
+	}
+	chk, err := ListeningAllowlist{}.New([]string{
+		"tcp 22 ssh.service", "udp 127.0.0.53:53", "udp 32768-60999",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unexpected, err := chk.(ListeningAllowlist).unexpected(ls); err != nil || len(unexpected) > 0 {
+		t.Errorf("Unexpected listeners: %v, %v", unexpected, err)
+	}
+	chk, _ = ListeningAllowlist{}.New([]string{"tcp 0.0.0.0:22 sshd", "udp *:53"})
+	unexpected, err := chk.(ListeningAllowlist).unexpected(ls)
+	if err != nil || len(unexpected) != 2 ||
+		!strings.HasPrefix(unexpected[0], "tcp [::]:22 sshd[800] (ssh.service)") ||
+		!strings.HasPrefix(unexpected[1], "udp 0.0.0.0:40000") {
+		t.Errorf("Unexpected listeners: %v, %v", unexpected, err)
+	}
+	// the process can't be checked without knowing it
+	chk, _ = ListeningAllowlist{}.New([]string{"tcp 22", "udp 53 systemd-resolved"})
+	if _, err := chk.(ListeningAllowlist).unexpected(ls); err == nil {
+		t.Error("Expected an error for a listener with an unknown process")
+	}
+}
+
 func TestInterfaceExists(t *testing.T) {
 	t.Parallel()
 	validInputs := names
@@ -137,6 +261,124 @@ func TestUDPTimeout(t *testing.T) {
 	validInputs := appendParameter(names, "5s")
 	testParameters(validInputs, notLengthTwo, UDPTimeout{}, t)
 	testCheck(goodEggs, badEggs, UDPTimeout{}, t)
+
+	// a service that never replies passes without waiting for the timeout
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	chk, err := UDPTimeout{}.New([]string{silent.LocalAddr().String(), "5s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if code, msg, err := chk.Status(); code != 0 || err != nil {
+		t.Errorf("Unexpected status for a silent address: %d, %s, %v", code, msg, err)
+	} else if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("UDPTimeout waited %v for a reply", elapsed)
+	}
+}
+
+// closedAddress returns a local address that nothing is listening on
+func closedAddress(t *testing.T, network string) string {
+	var addr string
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr = conn.LocalAddr().String()
+		conn.Close()
+	} else {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr = ln.Addr().String()
+		ln.Close()
+	}
+	return addr
+}
+
+func TestTCPLatency(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	addr := ln.Addr().String()
+	validInputs := [][]string{
+		{addr, "50ms", "200ms"},
+		{addr, "1s", "1s", "5"},
+	}
+	invalidInputs := [][]string{
+		{}, {addr}, {addr, "50ms"},
+		{addr, "fifty", "200ms"},
+		{addr, "200ms", "50ms"},
+		{addr, "50ms", "200ms", "0"},
+		{addr, "50ms", "200ms", "5", "extra"},
+	}
+	goodEggs := [][]string{{addr, "5s", "10s", "5"}}
+	badEggs := [][]string{
+		{closedAddress(t, "tcp"), "5s", "10s"},
+		{addr, "1ns", "1ns"},
+	}
+	testParameters(validInputs, invalidInputs, TCPLatency{}, t)
+	testCheck(goodEggs, badEggs, TCPLatency{}, t)
+
+	// above the warning threshold, but not the critical one
+	chk, err := TCPLatency{}.New([]string{addr, "1ns", "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, msg, _ := chk.Status(); code != 2 || !strings.Contains(msg, "warning") {
+		t.Errorf("Expected a warning, got %d: %s", code, msg)
+	}
+}
+
+func TestUDPLatency(t *testing.T) {
+	t.Parallel()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(append([]byte("pong"), buf[:n]...), from)
+		}
+	}()
+	addr := conn.LocalAddr().String()
+	// a socket that never replies, like a blackholed host
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	goodEggs := [][]string{{addr, "5s", "10s"}}
+	badEggs := [][]string{
+		{closedAddress(t, "udp"), "5s", "10s"},
+		{addr, "1ns", "1ns"},
+		{silent.LocalAddr().String(), "10ms", "50ms", "2"},
+	}
+	testParameters(goodEggs, [][]string{{addr}}, UDPLatency{}, t)
+	testCheck(goodEggs, badEggs, UDPLatency{}, t)
 }
 
 func TestRoutingTableDestination(t *testing.T) {
@@ -186,3 +428,124 @@ func TestReponseMatchesInsecure(t *testing.T) {
 		testCheck(goodEggs, badEggs, ResponseMatchesInsecure{}, t)
 	}
 }
+
+// testCerts are a CA and a certificate it issued, written to a directory
+type testCerts struct {
+	dir                                string
+	caFile, certFile, derFile, keyFile string
+	otherKeyFile                       string
+	keyPair                            tls.Certificate
+}
+
+// writeTestCerts generates a CA, and a certificate valid for 30 days for
+// localhost, example.test and 127.0.0.1, and writes them to a directory
+func writeTestCerts(t *testing.T) testCerts {
+	dir, err := ioutil.TempDir("", "distributive-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs := testCerts{
+		dir:          dir,
+		caFile:       filepath.Join(dir, "ca.pem"),
+		certFile:     filepath.Join(dir, "cert.pem"),
+		derFile:      filepath.Join(dir, "cert.der"),
+		keyFile:      filepath.Join(dir, "key.pem"),
+		otherKeyFile: filepath.Join(dir, "other-key.pem"),
+	}
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	writePEM := func(path, blockType string, der []byte) {
+		data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	caKey, key := newKey(), newKey()
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Distributive Test CA", Organization: []string{"Distributive"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.test"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost", "example.test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, key.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKeyDER, err := x509.MarshalECPrivateKey(newKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(certs.caFile, "CERTIFICATE", caDER)
+	writePEM(certs.certFile, "CERTIFICATE", der)
+	writePEM(certs.keyFile, "PRIVATE KEY", keyDER)
+	writePEM(certs.otherKeyFile, "EC PRIVATE KEY", otherKeyDER)
+	if err := ioutil.WriteFile(certs.derFile, der, 0600); err != nil {
+		t.Fatal(err)
+	}
+	certs.keyPair = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return certs
+}
+
+func TestTLSCertExpiry(t *testing.T) {
+	t.Parallel()
+	certs := writeTestCerts(t)
+	defer os.RemoveAll(certs.dir)
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{certs.keyPair}}
+	server.StartTLS()
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "https://")
+
+	validInputs := [][]string{
+		{address, "10"},
+		{address, "10", "example.test"},
+		{address, "10", "", certs.caFile},
+	}
+	invalidInputs := append(notLengthTwo,
+		[]string{"localhost", "10"},
+		[]string{address, "ten"},
+		[]string{address, "-1"},
+		[]string{address, "10", "", certs.caFile, "extra"},
+	)
+	testParameters(validInputs, invalidInputs, TLSCertExpiry{}, t)
+	goodEggs := [][]string{
+		{address, "10", "example.test", certs.caFile},
+		{address, "29", "localhost", certs.caFile},
+		{address, "0", "", certs.caFile},
+	}
+	badEggs := [][]string{
+		{address, "31", "example.test", certs.caFile},
+		{address, "10", "wrong.test", certs.caFile},
+		// not signed by one of the system's roots
+		{address, "10", "example.test"},
+		{"127.0.0.1:0", "10", "example.test", certs.caFile},
+	}
+	testCheck(goodEggs, badEggs, TLSCertExpiry{}, t)
+}
@@ -44,7 +44,7 @@ func (chk PacmanIgnore) New(params []string) (chkutil.Check, error) {
 
 func (chk PacmanIgnore) Status() (int, string, error) {
 	path := "/etc/pacman.conf"
-	data := chkutil.FileToString(path)
+	data := chkutil.FileToString(chkutil.HostPath(path))
 	re := regexp.MustCompile(`[^#]IgnorePkg\s+=\s+.+`)
 	find := re.FindString(data)
 	var packages []string
@@ -82,6 +82,9 @@ func (chk Installed) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk Installed) Status() (int, string, error) {
+	if err := chkutil.RequireHost("Installed"); err != nil {
+		return 1, "", err
+	}
 	var pkg gosssystem.Package
 	switch gosssystem.DetectPackageManager() {
 	case "deb":
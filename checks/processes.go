@@ -0,0 +1,636 @@
+package checks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/procstatus"
+)
+
+func init() {
+	chkutil.Register("Process", func() chkutil.Check {
+		return &Process{}
+	})
+	chkutil.Register("PIDFile", func() chkutil.Check {
+		return &PIDFile{}
+	})
+	chkutil.Register("Zombies", func() chkutil.Check {
+		return &Zombies{}
+	})
+	chkutil.Register("UninterruptibleProcesses", func() chkutil.Check {
+		return &UninterruptibleProcesses{}
+	})
+	chkutil.Register("ProcessTableUsage", func() chkutil.Check {
+		return &ProcessTableUsage{}
+	})
+}
+
+// defaultCPUSample is how long Process measures CPU usage over
+const defaultCPUSample = time.Second
+
+// megabytes formats an amount of bytes in MB
+func megabytes(bytes uint64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
+
+// cpuPercents measures the CPU usage of each process over the sample period,
+// as a percentage of one CPU. Processes that exit during the sample are left
+// out.
+func cpuPercents(procs []procstatus.Process, sample time.Duration) map[int]float64 {
+	start := time.Now()
+	time.Sleep(sample)
+	percents := make(map[int]float64)
+	for _, proc := range procs {
+		now, err := procstatus.ReadProcess(proc.PID)
+		// the PID may have been reused by a new process
+		if err != nil || now.StartTicks != proc.StartTicks {
+			continue
+		}
+		seconds := float64(now.CPUTicks-proc.CPUTicks) / procstatus.ClockTicks
+		percents[proc.PID] = 100 * seconds / time.Since(start).Seconds()
+	}
+	return percents
+}
+
+/*
+#### Process
+Description: Are the right number of these processes running, and are they
+within these resource limits? Processes are read from /proc, and are selected
+by the exe, cmdline and user options, which they must all match. Every other
+option is a limit, which every selected process must be within. Options are
+written as name=value:
+- exe: Executable's name, or its full path if this contains a /
+- cmdline: Regexp that the command line, with its arguments separated by
+  spaces, must match
+- user: Username or UID that the process runs as
+- min, max: Range of how many processes may be selected, at least 1 by default
+- max-rss: Largest resident memory, e.g. 512MB
+- max-cpu: Highest CPU usage, as a percentage of one CPU, over the sample
+- cpu-sample: How long to measure CPU usage over, 1s by default
+- max-fds: Most open file descriptors
+- max-threads: Most threads
+- min-uptime, max-uptime: Range of how long the process may have been
+  running, e.g. 1m to catch a crash loop
+Reading other users' executables and file descriptors usually requires root.
+Parameters:
+- Options (name=value, at least one of exe, cmdline or user)
+Example parameters:
+- exe=nginx, min=2, max-rss=512MB, max-fds=10000
+- cmdline=^java .*kafka\.Kafka, user=kafka, max=1, max-threads=500
+- exe=/usr/sbin/sshd, min-uptime=1m, max-cpu=50, cpu-sample=5s
+Dependencies:
+- /proc
+*/
+
+type Process struct {
+	exe, user            string
+	cmdline              *regexp.Regexp
+	min, max             int
+	maxRSS               uint64
+	maxCPU               float64
+	cpuSample            time.Duration
+	maxFDs, maxThreads   int
+	minUptime, maxUptime time.Duration
+}
+
+// parseOption parses one name=value option into chk
+func (chk *Process) parseOption(option string) error {
+	i := strings.Index(option, "=")
+	if i < 1 {
+		return errutil.ParameterTypeError{option, "name=value"}
+	}
+	name, value := option[:i], option[i+1:]
+	invalid := errutil.ParameterTypeError{option, name + " option"}
+	// nonNegative parses an int option that can't be negative
+	nonNegative := func(n *int) error {
+		var err error
+		if *n, err = strconv.Atoi(value); err != nil || *n < 0 {
+			return invalid
+		}
+		return nil
+	}
+	// duration parses a duration option that must be positive
+	duration := func(d *time.Duration) error {
+		var err error
+		if *d, err = time.ParseDuration(value); err != nil || *d <= 0 {
+			return invalid
+		}
+		return nil
+	}
+	var err error
+	switch name {
+	case "exe":
+		chk.exe = value
+	case "cmdline":
+		if chk.cmdline, err = regexp.Compile(value); err != nil {
+			return invalid
+		}
+	case "user":
+		if !validUsername(value) {
+			return errutil.ParameterTypeError{option, "username or UID"}
+		}
+		chk.user = value
+	case "min":
+		return nonNegative(&chk.min)
+	case "max":
+		return nonNegative(&chk.max)
+	case "max-rss":
+		rss, err := parseUnitPropertyNumber(value)
+		if err != nil || rss <= 0 {
+			return invalid
+		}
+		chk.maxRSS = uint64(rss)
+	case "max-cpu":
+		if chk.maxCPU, err = strconv.ParseFloat(value, 64); err != nil || chk.maxCPU < 0 {
+			return invalid
+		}
+	case "cpu-sample":
+		return duration(&chk.cpuSample)
+	case "max-fds":
+		return nonNegative(&chk.maxFDs)
+	case "max-threads":
+		return nonNegative(&chk.maxThreads)
+	case "min-uptime":
+		return duration(&chk.minUptime)
+	case "max-uptime":
+		return duration(&chk.maxUptime)
+	default:
+		return errutil.ParameterTypeError{option, "Process option"}
+	}
+	return nil
+}
+
+func (chk Process) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.min, chk.max, chk.maxFDs, chk.maxThreads = 1, -1, -1, -1
+	chk.maxCPU = -1
+	chk.cpuSample = defaultCPUSample
+	for _, option := range params {
+		if err := chk.parseOption(option); err != nil {
+			return chk, err
+		}
+	}
+	if chk.exe == "" && chk.cmdline == nil && chk.user == "" {
+		return chk, errutil.ParameterTypeError{strings.Join(params, " "),
+			"options including exe, cmdline or user"}
+	} else if chk.max >= 0 && chk.max < chk.min {
+		return chk, errutil.ParameterTypeError{"max=" + strconv.Itoa(chk.max),
+			"max of at least min"}
+	}
+	return chk, nil
+}
+
+// selects reports whether proc matches the exe, cmdline and user options. uid
+// is the user option's UID, or -1 if it isn't set.
+func (chk Process) selects(proc procstatus.Process, uid int) bool {
+	if chk.exe != "" {
+		if strings.Contains(chk.exe, "/") && proc.Exe != chk.exe {
+			return false
+		} else if !strings.Contains(chk.exe, "/") && proc.Name() != chk.exe {
+			return false
+		}
+	}
+	if chk.cmdline != nil && !chk.cmdline.MatchString(strings.Join(proc.Cmdline, " ")) {
+		return false
+	}
+	return uid < 0 || proc.UID == uid
+}
+
+// selector describes the exe, cmdline and user options
+func (chk Process) selector() string {
+	var options []string
+	if chk.exe != "" {
+		options = append(options, "exe="+chk.exe)
+	}
+	if chk.cmdline != nil {
+		options = append(options, "cmdline="+chk.cmdline.String())
+	}
+	if chk.user != "" {
+		options = append(options, "user="+chk.user)
+	}
+	return strings.Join(options, " ")
+}
+
+// countStatus checks how many processes were selected
+func (chk Process) countStatus(selected []procstatus.Process) (int, string, error) {
+	n := len(selected)
+	if n >= chk.min && (chk.max < 0 || n <= chk.max) {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("Found %d processes matching %s", n, chk.selector())
+	specified := "at least " + strconv.Itoa(chk.min)
+	if n >= chk.min {
+		specified = "at most " + strconv.Itoa(chk.max)
+	}
+	return errutil.GenericError(msg, specified, selected)
+}
+
+// limitsExceeded describes the limits that proc is outside of, given its
+// uptime, open file descriptors (-1 if they weren't counted) and CPU usage (-1
+// if it wasn't measured)
+func (chk Process) limitsExceeded(proc procstatus.Process, uptime time.Duration,
+	fds int, cpu float64) (exceeded []string) {
+	if chk.maxRSS > 0 && proc.RSS > chk.maxRSS {
+		exceeded = append(exceeded, fmt.Sprintf("rss %s > %s",
+			megabytes(proc.RSS), megabytes(chk.maxRSS)))
+	}
+	if chk.maxThreads >= 0 && proc.Threads > chk.maxThreads {
+		exceeded = append(exceeded, fmt.Sprintf("threads %d > %d",
+			proc.Threads, chk.maxThreads))
+	}
+	if chk.maxFDs >= 0 && fds > chk.maxFDs {
+		exceeded = append(exceeded, fmt.Sprintf("fds %d > %d", fds, chk.maxFDs))
+	}
+	if chk.maxCPU >= 0 && cpu > chk.maxCPU {
+		exceeded = append(exceeded, fmt.Sprintf("cpu %.1f%% > %.1f%%", cpu, chk.maxCPU))
+	}
+	if chk.minUptime > 0 && uptime < chk.minUptime {
+		exceeded = append(exceeded, fmt.Sprintf("uptime %v < %v",
+			uptime.Truncate(time.Second), chk.minUptime))
+	}
+	if chk.maxUptime > 0 && uptime > chk.maxUptime {
+		exceeded = append(exceeded, fmt.Sprintf("uptime %v > %v",
+			uptime.Truncate(time.Second), chk.maxUptime))
+	}
+	return exceeded
+}
+
+func (chk Process) Status() (int, string, error) {
+	uid := -1
+	if chk.user != "" {
+		var err error
+		if uid, err = userID(chk.user); err != nil {
+			return 1, "", err
+		}
+	}
+	procs, err := procstatus.Processes()
+	if err != nil {
+		return 1, "", err
+	}
+	var selected []procstatus.Process
+	for _, proc := range procs {
+		// distributive's own process isn't one of an alternate proc's
+		self := !chkutil.AlternateProc() && proc.PID == os.Getpid()
+		if !self && chk.selects(proc, uid) {
+			selected = append(selected, proc)
+		}
+	}
+	if code, msg, err := chk.countStatus(selected); code != 0 || err != nil {
+		return code, msg, err
+	}
+	boot, err := procstatus.BootTime()
+	if err != nil {
+		return 1, "", err
+	}
+	var percents map[int]float64
+	if chk.maxCPU >= 0 {
+		percents = cpuPercents(selected, chk.cpuSample)
+	}
+	var offenders []string
+	for _, proc := range selected {
+		fds := -1
+		if chk.maxFDs >= 0 {
+			if fds, err = procstatus.CountFDs(proc.PID); os.IsPermission(err) {
+				return 1, "", fmt.Errorf("Couldn't count the file descriptors "+
+					"of %s, distributive may need to run as root", proc)
+			}
+		}
+		cpu, ok := percents[proc.PID]
+		if !ok {
+			cpu = -1
+		}
+		uptime := time.Since(proc.Started(boot))
+		exceeded := chk.limitsExceeded(proc, uptime, fds, cpu)
+		if len(exceeded) > 0 {
+			offenders = append(offenders, proc.String()+": "+strings.Join(exceeded, ", "))
+		}
+	}
+	if len(offenders) == 0 {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("%d processes matching %s exceeded their limits",
+		len(offenders), chk.selector())
+	return errutil.GenericError(msg, "within limits", offenders)
+}
+
+/*
+#### PIDFile
+Description: Is the process in this PID file running? Options, written as
+name=value, guard against the PID having been reused by another process:
+- exe: Executable's name, or its full path if this contains a /
+- cmdline: Regexp that the command line, with its arguments separated by
+  spaces, must match
+The check also fails if the file was last written before the process started,
+which means the daemon that wrote it has died and its PID has been reused.
+Parameters:
+- Path (filepath): Path to the PID file
+- Options (name=value, optional)
+Example parameters:
+- /var/run/nginx.pid, /run/sshd.pid
+- exe=nginx, cmdline=^/usr/sbin/sshd
+Dependencies:
+- /proc
+*/
+
+type PIDFile struct {
+	path    string
+	process Process
+}
+
+// pidFileSlack is how long before a process' start time its PID file may have
+// been written, since the start time is only known to within a second
+const pidFileSlack = time.Second
+
+func (chk PIDFile) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.path = params[0]
+	for _, option := range params[1:] {
+		if !strings.HasPrefix(option, "exe=") && !strings.HasPrefix(option, "cmdline=") {
+			return chk, errutil.ParameterTypeError{option, "PIDFile option"}
+		} else if err := chk.process.parseOption(option); err != nil {
+			return chk, err
+		}
+	}
+	return chk, nil
+}
+
+// readPIDFile reads the PID in a PID file, and when the file was last written
+func readPIDFile(path string) (pid int, modified time.Time, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return pid, modified, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return pid, modified, err
+	}
+	pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return pid, modified, fmt.Errorf("Invalid PID in %s: %q", path, data)
+	}
+	return pid, info.ModTime(), nil
+}
+
+// pidFileStatus checks the process in the PID file, given when the file was
+// written and when the host booted
+func (chk PIDFile) pidFileStatus(proc procstatus.Process, modified, boot time.Time) (int, string, error) {
+	if proc.State == "Z" {
+		return 1, fmt.Sprintf("Process in %s is a zombie: %s", chk.path, proc), nil
+	} else if !chk.process.selects(proc, -1) {
+		msg := "Process in " + chk.path + " wasn't the expected one"
+		actual := proc.String() + " " + strings.Join(proc.Cmdline, " ")
+		return errutil.GenericError(msg, chk.process.selector(), []string{actual})
+	}
+	started := proc.Started(boot)
+	if modified.Add(pidFileSlack).Before(started) {
+		msg := chk.path + " is stale, it was written before its process started"
+		return errutil.GenericError(msg, "written after "+started.Format(time.RFC3339),
+			[]string{"written " + modified.Format(time.RFC3339)})
+	}
+	return errutil.Success()
+}
+
+func (chk PIDFile) Status() (int, string, error) {
+	pid, modified, err := readPIDFile(chkutil.HostPath(chk.path))
+	if os.IsNotExist(err) {
+		return 1, "No such PID file: " + chk.path, nil
+	} else if err != nil {
+		return 1, "", err
+	}
+	proc, err := procstatus.ReadProcess(pid)
+	if os.IsNotExist(err) {
+		msg := fmt.Sprintf("Process in %s isn't running: %d", chk.path, pid)
+		return 1, msg, nil
+	} else if err != nil {
+		return 1, "", err
+	}
+	boot, err := procstatus.BootTime()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.pidFileStatus(proc, modified, boot)
+}
+
+/*
+#### Zombies
+Description: Are there no more than this many zombie processes? Zombies have
+exited, but their parents haven't collected their exit statuses, so the
+message names each zombie's parent.
+Parameters:
+- Maximum (int): Most zombies allowed
+Example parameters:
+- 0, 5
+Dependencies:
+- /proc
+*/
+
+type Zombies struct{ max int }
+
+func (chk Zombies) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	max, err := strconv.Atoi(params[0])
+	if err != nil || max < 0 {
+		return chk, errutil.ParameterTypeError{params[0], "positive int"}
+	}
+	chk.max = max
+	return chk, nil
+}
+
+// zombieStatus counts the zombies among procs
+func (chk Zombies) zombieStatus(procs []procstatus.Process) (int, string, error) {
+	names := make(map[int]string)
+	for _, proc := range procs {
+		names[proc.PID] = proc.String()
+	}
+	var zombies []string
+	for _, proc := range procs {
+		if proc.State == "Z" {
+			parent, ok := names[proc.PPID]
+			if !ok {
+				parent = strconv.Itoa(proc.PPID)
+			}
+			zombies = append(zombies, proc.String()+" (parent "+parent+")")
+		}
+	}
+	if len(zombies) <= chk.max {
+		return errutil.Success()
+	}
+	msg := fmt.Sprintf("Found %d zombie processes", len(zombies))
+	return errutil.GenericError(msg, "at most "+strconv.Itoa(chk.max), zombies)
+}
+
+func (chk Zombies) Status() (int, string, error) {
+	procs, err := procstatus.Processes()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.zombieStatus(procs)
+}
+
+/*
+#### UninterruptibleProcesses
+Description: Has no process been in uninterruptible sleep (D state) for longer
+than this? Processes are often briefly in D state while waiting for disk or
+network I/O, but one that stays there is usually stuck on a failed disk or an
+unreachable NFS server. Distributive remembers which processes were in D state
+between runs (see --state-dir), so a process fails the check once it's been
+seen in D state on consecutive runs for longer than this.
+Parameters:
+- Duration (time.Duration): Longest a process may stay in D state
+Example parameters:
+- 1m, 5m
+Dependencies:
+- /proc
+*/
+
+type UninterruptibleProcesses struct{ duration time.Duration }
+
+// uninterruptibleState is when a process was first seen in D state, on the
+// runs since which it's been in D state every time
+type uninterruptibleState struct {
+	// StartTicks identifies the process, in case its PID is reused
+	StartTicks uint64
+	Since      time.Time
+}
+
+func (chk UninterruptibleProcesses) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	duration, err := time.ParseDuration(params[0])
+	if err != nil || duration <= 0 {
+		return chk, errutil.ParameterTypeError{params[0], "time.Duration"}
+	}
+	chk.duration = duration
+	return chk, nil
+}
+
+// uninterruptibleStatus finds the processes that have been in D state for too
+// long, given those that were in D state on the last run. It returns the
+// processes in D state now, to compare against on the next run.
+func (chk UninterruptibleProcesses) uninterruptibleStatus(last map[int]uninterruptibleState,
+	procs []procstatus.Process, now time.Time) (map[int]uninterruptibleState, int, string, error) {
+	next := make(map[int]uninterruptibleState)
+	var stuck []string
+	for _, proc := range procs {
+		if proc.State != "D" {
+			continue
+		}
+		state, ok := last[proc.PID]
+		if !ok || state.StartTicks != proc.StartTicks {
+			state = uninterruptibleState{proc.StartTicks, now}
+		}
+		next[proc.PID] = state
+		if elapsed := now.Sub(state.Since); elapsed > chk.duration {
+			stuck = append(stuck, fmt.Sprintf("%s for %v", proc, elapsed.Truncate(time.Second)))
+		}
+	}
+	if len(stuck) == 0 {
+		return next, 0, "", nil
+	}
+	msg := fmt.Sprintf("%d processes were stuck in uninterruptible sleep", len(stuck))
+	code, msg, err := errutil.GenericError(msg, "at most "+chk.duration.String(), stuck)
+	return next, code, msg, err
+}
+
+func (chk UninterruptibleProcesses) Status() (int, string, error) {
+	procs, err := procstatus.Processes()
+	if err != nil {
+		return 1, "", err
+	}
+	last := make(map[int]uninterruptibleState)
+	if _, err := chkutil.LoadState("UninterruptibleProcesses", "", &last); err != nil {
+		return 1, "", err
+	}
+	next, code, msg, err := chk.uninterruptibleStatus(last, procs, time.Now())
+	if err := chkutil.SaveState("UninterruptibleProcesses", "", next); err != nil {
+		return 1, "", err
+	}
+	return code, msg, err
+}
+
+/*
+#### ProcessTableUsage
+Description: Are the numbers of PIDs and threads in use less than this
+percentage of the kernel's limits, kernel.pid_max and kernel.threads-max? Every
+thread has its own PID, so a few processes with many threads can use up the
+PIDs. When either runs out, nothing on the host can fork.
+Parameters:
+- Percent (float64): Largest percentage of each limit allowed
+Example parameters:
+- 50, 80
+Dependencies:
+- /proc
+*/
+
+type ProcessTableUsage struct{ percent float64 }
+
+func (chk ProcessTableUsage) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	percent, err := strconv.ParseFloat(params[0], 64)
+	if err != nil || percent <= 0 || percent > 100 {
+		return chk, errutil.ParameterTypeError{params[0], "percentage"}
+	}
+	chk.percent = percent
+	return chk, nil
+}
+
+// usageStatus compares the threads in procs with the limits
+func (chk ProcessTableUsage) usageStatus(procs []procstatus.Process, pidMax,
+	threadsMax int) (int, string, error) {
+	threads := 0
+	for _, proc := range procs {
+		threads += proc.Threads
+	}
+	usages := []struct {
+		name      string
+		used, max int
+	}{
+		{"PIDs", threads, pidMax},
+		{"threads", threads, threadsMax},
+	}
+	var exceeded []string
+	for _, usage := range usages {
+		percent := 100 * float64(usage.used) / float64(usage.max)
+		if percent > chk.percent {
+			exceeded = append(exceeded, fmt.Sprintf("%d %s of %d (%.1f%%)",
+				usage.used, usage.name, usage.max, percent))
+		}
+	}
+	if len(exceeded) == 0 {
+		return errutil.Success()
+	}
+	return errutil.GenericError("Process table was nearly full",
+		fmt.Sprintf("at most %v%%", chk.percent), exceeded)
+}
+
+func (chk ProcessTableUsage) Status() (int, string, error) {
+	procs, err := procstatus.Processes()
+	if err != nil {
+		return 1, "", err
+	}
+	pidMax, err := procstatus.KernelLimit("pid_max")
+	if err != nil {
+		return 1, "", err
+	}
+	threadsMax, err := procstatus.KernelLimit("threads-max")
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.usageStatus(procs, pidMax, threadsMax)
+}
@@ -0,0 +1,217 @@
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CiscoCloud/distributive/procstatus"
+)
+
+// startSleep starts a process to look for, with an unusual command line
+func startSleep(t *testing.T) *exec.Cmd {
+	cmd := exec.Command("sleep", "3141")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// wait for the child to exec sleep
+	for i := 0; i < 100; i++ {
+		if proc, err := procstatus.ReadProcess(cmd.Process.Pid); err == nil &&
+			strings.Join(proc.Cmdline, " ") == "sleep 3141" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cmd
+}
+
+func TestProcess(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"exe=nginx"}, {"exe=/usr/sbin/nginx", "min=2", "max=4"},
+		{"cmdline=^java .*kafka", "user=kafka", "max=1", "max-threads=500"},
+		{"user=0", "max-rss=512MB", "max-fds=10000", "max-cpu=50", "cpu-sample=5s"},
+		{"exe=sshd", "min-uptime=1m", "max-uptime=720h", "min=0"},
+	}
+	invalidInputs := [][]string{
+		{}, {"nginx"}, {"min=1"}, {"exe=nginx", "min=3", "max=2"},
+		{"cmdline=(unclosed"}, {"exe=nginx", "min=-1"}, {"exe=nginx", "max-rss=lots"},
+		{"exe=nginx", "max-cpu=-5"}, {"exe=nginx", "cpu-sample=0s"},
+		{"exe=nginx", "max-uptime=forever"}, {"exe=nginx", "colour=blue"},
+	}
+	testParameters(validInputs, invalidInputs, Process{}, t)
+
+	cmd := startSleep(t)
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	sleep := "cmdline=^sleep 3141$"
+	user := "user=" + strconv.Itoa(os.Getuid())
+	goodEggs := [][]string{
+		{sleep}, {sleep, user, "max=1"}, {sleep, "max-rss=1GB", "max-threads=10"},
+		{sleep, "max-fds=100", "max-cpu=50", "cpu-sample=100ms", "max-uptime=1h"},
+		{"cmdline=^sleep 2718$", "min=0"},
+	}
+	badEggs := [][]string{
+		{"cmdline=^sleep 2718$"}, {sleep, "min=2"}, {sleep, "user=99999"},
+		{sleep, "max=0", "min=0"}, {sleep, "max-rss=1KB"}, {sleep, "max-threads=0"},
+		{sleep, "max-fds=0"}, {sleep, "min-uptime=1h"},
+	}
+	testCheck(goodEggs, badEggs, Process{}, t)
+}
+
+func TestProcessLimitsExceeded(t *testing.T) {
+	t.Parallel()
+	chk, err := Process{}.New([]string{"exe=java", "max-rss=1GB", "max-threads=100",
+		"max-fds=1000", "max-cpu=90", "min-uptime=1m", "max-uptime=24h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proc := procstatus.Process{PID: 42, Comm: "java", RSS: 2 << 30,
+		Stat: procstatus.Stat{Threads: 150}}
+	within := chk.(Process).limitsExceeded(procstatus.Process{RSS: 1 << 30,
+		Stat: procstatus.Stat{Threads: 100}}, time.Hour, 1000, 90)
+	if len(within) != 0 {
+		t.Errorf("Unexpected limits exceeded: %v", within)
+	}
+	exceeded := chk.(Process).limitsExceeded(proc, 30*time.Second, 1001, 150)
+	expected := []string{"rss 2048.0 MB > 1024.0 MB", "threads 150 > 100",
+		"fds 1001 > 1000", "cpu 150.0% > 90.0%", "uptime 30s < 1m0s"}
+	if strings.Join(exceeded, "; ") != strings.Join(expected, "; ") {
+		t.Errorf("Unexpected limits exceeded: %v", exceeded)
+	}
+	// unmeasured fds and CPU aren't limits exceeded
+	exceeded = chk.(Process).limitsExceeded(procstatus.Process{}, 48*time.Hour, -1, -1)
+	if len(exceeded) != 1 || exceeded[0] != "uptime 48h0m0s > 24h0m0s" {
+		t.Errorf("Unexpected limits exceeded: %v", exceeded)
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"/var/run/nginx.pid"}, {"/run/sshd.pid", "exe=sshd", "cmdline=^/usr/sbin/sshd"},
+	}
+	invalidInputs := [][]string{
+		{}, {"/run/sshd.pid", "sshd"}, {"/run/sshd.pid", "max=1"},
+		{"/run/sshd.pid", "cmdline=(unclosed"},
+	}
+	testParameters(validInputs, invalidInputs, PIDFile{}, t)
+
+	cmd := startSleep(t)
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	dir, err := ioutil.TempDir("", "distributive-pidfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	running := write("sleep.pid", strconv.Itoa(cmd.Process.Pid)+"\n")
+	stale := write("stale.pid", strconv.Itoa(cmd.Process.Pid))
+	if err := os.Chtimes(stale, time.Unix(0, 0), time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	goodEggs := [][]string{
+		{running}, {running, "exe=sleep"}, {running, "cmdline=^sleep 3141$"},
+	}
+	badEggs := [][]string{
+		{filepath.Join(dir, "missing.pid")}, {running, "exe=nginx"},
+		{running, "cmdline=^sleep 2718$"}, {stale},
+		// the largest PID Linux allows, which can't be running
+		{write("exited.pid", "4194304")},
+	}
+	testCheck(goodEggs, badEggs, PIDFile{}, t)
+
+	if _, _, err := readPIDFile(write("invalid.pid", "nginx")); err == nil {
+		t.Error("Expected an error reading a PID file without a PID")
+	}
+}
+
+func TestZombies(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"0"}, {"5"}}, [][]string{{}, {"-1"}, {"none"}, {"1", "2"}},
+		Zombies{}, t)
+	procs := []procstatus.Process{
+		{PID: 1, Comm: "init", Stat: procstatus.Stat{State: "S"}},
+		{PID: 40, Comm: "worker", Stat: procstatus.Stat{State: "Z", PPID: 1}},
+		{PID: 41, Comm: "worker", Stat: procstatus.Stat{State: "Z", PPID: 30}},
+	}
+	for max, expected := range []int{1, 1, 0} {
+		code, msg, _ := Zombies{max}.zombieStatus(procs)
+		if code != expected {
+			t.Errorf("Unexpected status with maximum %d: %d, %s", max, code, msg)
+		} else if code != 0 && !strings.Contains(msg, "worker[40] (parent init[1])") {
+			t.Errorf("Message didn't name zombie's parent: %s", msg)
+		}
+	}
+}
+
+func TestUninterruptibleProcesses(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"1m"}, {"30s"}}, [][]string{{}, {"0s"}, {"long"}, {"1m", "2"}},
+		UninterruptibleProcesses{}, t)
+	chk := UninterruptibleProcesses{time.Minute}
+	start := time.Now()
+	stuck := func(pid int, startTicks uint64) procstatus.Process {
+		return procstatus.Process{PID: pid, Comm: "rsync",
+			Stat: procstatus.Stat{State: "D", StartTicks: startTicks}}
+	}
+	runs := []struct {
+		procs []procstatus.Process
+		after time.Duration
+		code  int
+	}{
+		{[]procstatus.Process{stuck(10, 100), stuck(11, 100)}, 0, 0},
+		{[]procstatus.Process{stuck(10, 100)}, 30 * time.Second, 0},
+		{[]procstatus.Process{stuck(10, 100), stuck(11, 100)}, 90 * time.Second, 1},
+		// 10 has woken up, and 11 is a new process with the same PID
+		{[]procstatus.Process{{PID: 10}, stuck(11, 200)}, 2 * time.Minute, 0},
+		{[]procstatus.Process{stuck(11, 200)}, 3 * time.Minute, 0},
+	}
+	last := make(map[int]uninterruptibleState)
+	for i, run := range runs {
+		next, code, msg, _ := chk.uninterruptibleStatus(last, run.procs, start.Add(run.after))
+		if code != run.code {
+			t.Errorf("Unexpected status on run %d: %d, %s", i, code, msg)
+		} else if code != 0 && !strings.Contains(msg, "rsync[10] for 1m30s") {
+			t.Errorf("Message didn't name stuck process: %s", msg)
+		}
+		last = next
+	}
+	if len(last) != 1 || !last[11].Since.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Unexpected state: %+v", last)
+	}
+}
+
+func TestProcessTableUsage(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"80"}, {"50.5"}}, [][]string{{}, {"0"}, {"101"}, {"most"}},
+		ProcessTableUsage{}, t)
+	testCheck([][]string{{"100"}}, [][]string{}, ProcessTableUsage{}, t)
+	procs := []procstatus.Process{
+		{PID: 1, Stat: procstatus.Stat{Threads: 1}},
+		{PID: 2, Stat: procstatus.Stat{Threads: 79}},
+	}
+	usages := []struct {
+		pidMax, threadsMax, code int
+	}{
+		{100, 100, 0}, {99, 1000, 1}, {1000, 99, 1},
+	}
+	for _, usage := range usages {
+		code, msg, _ := ProcessTableUsage{80}.usageStatus(procs, usage.pidMax, usage.threadsMax)
+		if code != usage.code {
+			t.Errorf("Unexpected status for %+v: %d, %s", usage, code, msg)
+		}
+	}
+}
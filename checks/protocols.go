@@ -0,0 +1,357 @@
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+func init() {
+	chkutil.Register("TCPExpect", func() chkutil.Check {
+		return &TCPExpect{}
+	})
+	chkutil.Register("RedisPing", func() chkutil.Check {
+		return &RedisPing{}
+	})
+	chkutil.Register("MemcachedStats", func() chkutil.Check {
+		return &MemcachedStats{}
+	})
+	chkutil.Register("SMTPBanner", func() chkutil.Check {
+		return &SMTPBanner{}
+	})
+	chkutil.Register("SSHBanner", func() chkutil.Check {
+		return &SSHBanner{}
+	})
+	chkutil.Register("ZooKeeperFLW", func() chkutil.Check {
+		return &ZooKeeperFLW{}
+	})
+}
+
+// unescape interprets the escape sequences in s that Go string literals
+// support, e.g. \r, \n, \t, \\, \x00 and \u00e9
+func unescape(s string) (string, error) {
+	var unescaped []byte
+	for len(s) > 0 {
+		value, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return "", err
+		}
+		if value < utf8.RuneSelf || !multibyte {
+			unescaped = append(unescaped, byte(value))
+		} else {
+			var encoded [utf8.UTFMax]byte
+			n := utf8.EncodeRune(encoded[:], value)
+			unescaped = append(unescaped, encoded[:n]...)
+		}
+		s = tail
+	}
+	return string(unescaped), nil
+}
+
+// expectation is a request to send to a TCP server, and a regexp that its
+// response should match. It's the basis of TCPExpect and its presets.
+type expectation struct {
+	address string
+	timeout time.Duration
+	send    string
+	re      *regexp.Regexp
+}
+
+// parseAddressTimeout parses the address and optional timeout that TCPExpect's
+// presets take as their first parameters
+func (exp *expectation) parseAddressTimeout(params []string) error {
+	exp.address = params[0]
+	exp.timeout = 5 * time.Second
+	if len(params) > 1 {
+		timeout, err := time.ParseDuration(params[1])
+		if err != nil || timeout <= 0 {
+			return errutil.ParameterTypeError{params[1], "time.Duration"}
+		}
+		exp.timeout = timeout
+	}
+	return nil
+}
+
+// exchange sends the request, and reads the response until it matches
+func (exp expectation) exchange() (response string, matched bool, err error) {
+	return netstatus.Exchange(exp.address, []byte(exp.send), exp.re, exp.timeout)
+}
+
+// failure describes an exchange that failed, as the protocol name
+func (exp expectation) failure(name, response string, err error) (int, string, error) {
+	if err != nil {
+		return 1, fmt.Sprintf("Couldn't connect to %s: %s", exp.address, err), nil
+	}
+	const maxShown = 200
+	if len(response) > maxShown {
+		response = response[:maxShown] + "..."
+	}
+	msg := fmt.Sprintf("%s response from %s didn't match within %v",
+		name, exp.address, exp.timeout)
+	return errutil.GenericError(msg, exp.re.String(), []string{strconv.Quote(response)})
+}
+
+// status runs the exchange, and describes it as name if it fails
+func (exp expectation) status(name string) (int, string, error) {
+	response, matched, err := exp.exchange()
+	if err != nil || !matched {
+		return exp.failure(name, response, err)
+	}
+	return errutil.Success()
+}
+
+/*
+#### TCPExpect
+Description: Does the server at this address respond to this request with
+something that matches this regexp, within this timeout? The request can be
+empty, to check the banner that some servers send when a client connects.
+Escape sequences like \r\n, \t and \x00 are interpreted in the request. The
+response is read until it matches, the server closes the connection, or the
+timeout passes. See RedisPing, MemcachedStats, SMTPBanner, SSHBanner and
+ZooKeeperFLW for common protocols.
+Parameters:
+- Address (host:port): Server to connect to
+- Timeout (time.Duration): How long the whole exchange may take
+- Request (string): What to send after connecting, may be empty
+- Expected (regexp): What the response should match
+Example parameters:
+- localhost:6379, 10.0.0.4:11211
+- 1s, 500ms
+- "", "PING\r\n", "GET / HTTP/1.0\r\n\r\n"
+- "^\+PONG", "^HTTP/1\.[01] 200"
+*/
+
+type TCPExpect struct{ expectation }
+
+func (chk TCPExpect) New(params []string) (chkutil.Check, error) {
+	if len(params) != 4 {
+		return chk, errutil.ParameterLengthError{4, params}
+	}
+	timeout, err := time.ParseDuration(params[1])
+	if err != nil || timeout <= 0 {
+		return chk, errutil.ParameterTypeError{params[1], "time.Duration"}
+	}
+	send, err := unescape(params[2])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[2], "string with escape sequences"}
+	}
+	re, err := regexp.Compile(params[3])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[3], "regexp"}
+	}
+	chk.expectation = expectation{params[0], timeout, send, re}
+	return chk, nil
+}
+
+func (chk TCPExpect) Status() (int, string, error) {
+	return chk.status("TCP")
+}
+
+/*
+#### RedisPing
+Description: Does the Redis server at this address answer PING with PONG?
+Parameters:
+- Address (host:port): Redis server
+- Timeout (time.Duration, optional): How long to wait, 5s by default
+Example parameters:
+- localhost:6379, redis.service.consul:6379
+- 1s, 500ms
+*/
+
+type RedisPing struct{ expectation }
+
+func (chk RedisPing) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 && len(params) != 2 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.send = "PING\r\n"
+	chk.re = regexp.MustCompile(`^\+PONG\r\n`)
+	err := chk.parseAddressTimeout(params)
+	return chk, err
+}
+
+func (chk RedisPing) Status() (int, string, error) {
+	return chk.status("Redis")
+}
+
+/*
+#### MemcachedStats
+Description: Does the memcached server at this address answer the stats
+command?
+Parameters:
+- Address (host:port): memcached server
+- Timeout (time.Duration, optional): How long to wait, 5s by default
+Example parameters:
+- localhost:11211
+- 1s, 500ms
+*/
+
+type MemcachedStats struct{ expectation }
+
+func (chk MemcachedStats) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 && len(params) != 2 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.send = "stats\r\n"
+	chk.re = regexp.MustCompile(`^STAT pid \d+\r\n(?s:.*)END\r\n`)
+	err := chk.parseAddressTimeout(params)
+	return chk, err
+}
+
+func (chk MemcachedStats) Status() (int, string, error) {
+	return chk.status("memcached")
+}
+
+/*
+#### SMTPBanner
+Description: Does the SMTP server at this address greet clients with a 220
+(service ready) banner?
+Parameters:
+- Address (host:port): SMTP server
+- Timeout (time.Duration, optional): How long to wait, 5s by default
+Example parameters:
+- localhost:25, mail.example.com:587
+- 10s
+*/
+
+type SMTPBanner struct{ expectation }
+
+func (chk SMTPBanner) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 && len(params) != 2 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.re = regexp.MustCompile(`^220[ -].*\r?\n`)
+	err := chk.parseAddressTimeout(params)
+	return chk, err
+}
+
+func (chk SMTPBanner) Status() (int, string, error) {
+	return chk.status("SMTP")
+}
+
+/*
+#### SSHBanner
+Description: Does the SSH server at this address send an SSH 2.0 banner, and
+optionally, does its software version match this regexp?
+Parameters:
+- Address (host:port): SSH server
+- Timeout (time.Duration, optional): How long to wait, 5s by default
+- Version (regexp, optional): What the software version in the banner, e.g.
+  "OpenSSH_7.4p1", should match
+Example parameters:
+- localhost:22, bastion.example.com:22
+- 5s
+- "^OpenSSH_[7-9]\."
+*/
+
+type SSHBanner struct {
+	expectation
+	version *regexp.Regexp
+}
+
+// sshBanner matches an SSH 2.0 banner, capturing the software version
+var sshBanner = regexp.MustCompile(`^SSH-2\.0-(\S+).*\r?\n`)
+
+func (chk SSHBanner) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 || len(params) > 3 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	if len(params) == 3 {
+		version, err := regexp.Compile(params[2])
+		if err != nil {
+			return chk, errutil.ParameterTypeError{params[2], "regexp"}
+		}
+		chk.version = version
+	}
+	chk.re = sshBanner
+	err := chk.parseAddressTimeout(params)
+	return chk, err
+}
+
+func (chk SSHBanner) Status() (int, string, error) {
+	response, matched, err := chk.exchange()
+	if err != nil || !matched {
+		return chk.failure("SSH", response, err)
+	}
+	software := sshBanner.FindStringSubmatch(response)[1]
+	if chk.version != nil && !chk.version.MatchString(software) {
+		msg := "SSH server at " + chk.address + " had wrong version"
+		return errutil.GenericError(msg, chk.version.String(), []string{software})
+	}
+	return errutil.Success()
+}
+
+// zkWords are ZooKeeper's four letter words, and what their responses should
+// match to be healthy
+var zkWords = map[string]string{
+	"ruok": `^imok$`,
+	"isro": `^rw$`,
+	"srvr": `Mode: (leader|follower|standalone|observer)\n`,
+	"stat": `Mode: (leader|follower|standalone|observer)\n`,
+	"mntr": `zk_server_state\s+(leader|follower|standalone|observer)\n`,
+	"conf": `clientPort=\d+\n`,
+	"envi": `^Environment:\n`,
+	"cons": `\S`,
+	"wchs": `watching \d+ paths\n`,
+	"dump": `Session`,
+}
+
+/*
+#### ZooKeeperFLW
+Description: Does the ZooKeeper server at this address answer this four letter
+word as a healthy server would? Each word has a default expectation, e.g.
+"imok" for ruok, "rw" for isro (not read-only), or a leader, follower or
+standalone Mode for srvr. Newer versions of ZooKeeper only answer words on
+their whitelist (4lw.commands.whitelist).
+Parameters:
+- Address (host:port): ZooKeeper server
+- Timeout (time.Duration): How long to wait
+- Word (string): ruok | isro | srvr | stat | mntr | conf | envi | cons | wchs
+  | dump
+- Expected (regexp, optional): What the response should match, instead of the
+  default
+Example parameters:
+- localhost:2181, zookeeper.service.consul:2181
+- 1s, 5s
+- ruok, isro, mntr
+- "zk_server_state\sleader", "zk_outstanding_requests\s0\n"
+*/
+
+type ZooKeeperFLW struct {
+	expectation
+	word string
+}
+
+func (chk ZooKeeperFLW) New(params []string) (chkutil.Check, error) {
+	if len(params) != 3 && len(params) != 4 {
+		return chk, errutil.ParameterLengthError{3, params}
+	}
+	word := strings.ToLower(params[2])
+	expected, ok := zkWords[word]
+	if !ok {
+		return chk, errutil.ParameterTypeError{params[2], "four letter word"}
+	}
+	if len(params) == 4 {
+		expected = params[3]
+	}
+	re, err := regexp.Compile(expected)
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[3], "regexp"}
+	}
+	chk.word = word
+	chk.send = word
+	chk.re = re
+	err = chk.parseAddressTimeout(params)
+	return chk, err
+}
+
+func (chk ZooKeeperFLW) Status() (int, string, error) {
+	return chk.status("ZooKeeper " + chk.word)
+}
@@ -0,0 +1,185 @@
+package checks
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+// startTCPServer serves each connection to a local listener with handle, and
+// returns its address
+func startTCPServer(t *testing.T, handle func(net.Conn)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// lineServer answers each line it's sent from responses, or with "ERROR"
+func lineServer(t *testing.T, banner string, responses map[string]string) string {
+	return startTCPServer(t, func(conn net.Conn) {
+		conn.Write([]byte(banner))
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			response, ok := responses[strings.TrimSpace(scanner.Text())]
+			if !ok {
+				response = "ERROR\r\n"
+			}
+			conn.Write([]byte(response))
+		}
+	})
+}
+
+func TestUnescape(t *testing.T) {
+	t.Parallel()
+	escaped := map[string]string{
+		``:                 "",
+		`PING\r\n`:         "PING\r\n",
+		`\x00\x01\xff`:     "\x00\x01\xff",
+		`tab\tback\\slash`: "tab\tback\\slash",
+		`café "quote"`:     "café \"quote\"",
+	}
+	for input, expected := range escaped {
+		if actual, err := unescape(input); err != nil || actual != expected {
+			t.Errorf("Unexpected unescape of %q: %q, %v", input, actual, err)
+		}
+	}
+	if _, err := unescape(`\q`); err == nil {
+		t.Error("Expected an error unescaping an invalid sequence")
+	}
+}
+
+func TestTCPExpect(t *testing.T) {
+	t.Parallel()
+	addr := lineServer(t, "", map[string]string{"HELLO": "WORLD\r\n"})
+	closed := closedAddress(t, "tcp")
+	validInputs := [][]string{
+		{addr, "1s", `HELLO\r\n`, "^WORLD"},
+		{addr, "1s", "", "^banner"},
+	}
+	invalidInputs := [][]string{
+		{}, {addr, "1s", "HELLO"},
+		{addr, "notime", "HELLO", "WORLD"},
+		{addr, "1s", `\q`, "WORLD"},
+		{addr, "1s", "HELLO", "(unclosed"},
+	}
+	goodEggs := [][]string{{addr, "1s", `HELLO\r\n`, "^WORLD\r\n$"}}
+	badEggs := [][]string{
+		{addr, "100ms", `GOODBYE\r\n`, "^WORLD"},
+		{addr, "100ms", "", "^banner"},
+		{closed, "1s", `HELLO\r\n`, "^WORLD"},
+	}
+	testParameters(validInputs, invalidInputs, TCPExpect{}, t)
+	testCheck(goodEggs, badEggs, TCPExpect{}, t)
+}
+
+func TestProtocolPresets(t *testing.T) {
+	t.Parallel()
+	redis := lineServer(t, "", map[string]string{"PING": "+PONG\r\n"})
+	memcached := lineServer(t, "", map[string]string{
+		"stats": "STAT pid 42\r\nSTAT uptime 100\r\nEND\r\n",
+	})
+	smtp := lineServer(t, "220 mail.example.test ESMTP Postfix\r\n", nil)
+	ssh := lineServer(t, "SSH-2.0-OpenSSH_7.4p1 Debian-10\r\n", nil)
+	oldSSH := lineServer(t, "SSH-1.5-OpenSSH_2.1\r\n", nil)
+	silent := lineServer(t, "", nil)
+	closed := closedAddress(t, "tcp")
+	presets := []struct {
+		chk               chkutil.Check
+		goodEggs, badEggs [][]string
+		invalidInputs     [][]string
+	}{
+		{RedisPing{},
+			[][]string{{redis}, {redis, "1s"}},
+			[][]string{{memcached, "100ms"}, {closed}},
+			[][]string{{}, {redis, "notime"}, {redis, "1s", "extra"}},
+		},
+		{MemcachedStats{},
+			[][]string{{memcached}},
+			[][]string{{redis, "100ms"}, {closed}},
+			[][]string{{}, {memcached, "1s", "extra"}},
+		},
+		{SMTPBanner{},
+			[][]string{{smtp}},
+			[][]string{{ssh, "100ms"}, {silent, "100ms"}},
+			[][]string{{}, {smtp, "-1s"}},
+		},
+		{SSHBanner{},
+			[][]string{{ssh}, {ssh, "1s", "^OpenSSH_7\\."}},
+			[][]string{
+				{ssh, "1s", "^OpenSSH_[89]"},
+				{oldSSH, "100ms"},
+				{smtp, "100ms"},
+			},
+			[][]string{{}, {ssh, "1s", "(unclosed"}, {ssh, "1s", "OpenSSH", "extra"}},
+		},
+	}
+	for _, preset := range presets {
+		testParameters(preset.goodEggs, preset.invalidInputs, preset.chk, t)
+		testCheck(preset.goodEggs, preset.badEggs, preset.chk, t)
+	}
+}
+
+func TestZooKeeperFLW(t *testing.T) {
+	t.Parallel()
+	// ZooKeeper answers a single word, then closes the connection
+	zk := func(responses map[string]string) string {
+		return startTCPServer(t, func(conn net.Conn) {
+			word := make([]byte, 4)
+			if _, err := conn.Read(word); err == nil {
+				conn.Write([]byte(responses[string(word)]))
+			}
+		})
+	}
+	healthy := zk(map[string]string{
+		"ruok": "imok",
+		"isro": "rw",
+		"mntr": "zk_version\t3.4.13\nzk_server_state\tfollower\n",
+		"srvr": "Zookeeper version: 3.4.13\nMode: leader\nNode count: 4\n",
+	})
+	readOnly := zk(map[string]string{
+		"isro": "ro",
+		"srvr": "This ZooKeeper instance is not currently serving requests\n",
+	})
+	validInputs := [][]string{
+		{healthy, "1s", "ruok"},
+		{healthy, "1s", "MNTR", "zk_server_state\\s+leader"},
+	}
+	invalidInputs := [][]string{
+		{}, {healthy, "1s"},
+		{healthy, "1s", "kill"},
+		{healthy, "1s", "mntr", "(unclosed"},
+		{healthy, "notime", "ruok"},
+	}
+	goodEggs := [][]string{
+		{healthy, "1s", "ruok"},
+		{healthy, "1s", "isro"},
+		{healthy, "1s", "mntr"},
+		{healthy, "1s", "srvr"},
+		{healthy, "1s", "srvr", "Mode: leader"},
+	}
+	badEggs := [][]string{
+		{readOnly, "1s", "isro"},
+		{readOnly, "1s", "srvr"},
+		{readOnly, "1s", "ruok"},
+		{healthy, "1s", "mntr", "zk_server_state\\s+leader"},
+	}
+	testParameters(validInputs, invalidInputs, ZooKeeperFLW{}, t)
+	testCheck(goodEggs, badEggs, ZooKeeperFLW{}, t)
+}
@@ -0,0 +1,233 @@
+package checks
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+func init() {
+	chkutil.Register("ResolvNameservers", func() chkutil.Check {
+		return &ResolvNameservers{}
+	})
+	chkutil.Register("ResolvSearch", func() chkutil.Check {
+		return &ResolvSearch{}
+	})
+	chkutil.Register("ResolvOption", func() chkutil.Check {
+		return &ResolvOption{}
+	})
+	chkutil.Register("HostsEntry", func() chkutil.Check {
+		return &HostsEntry{}
+	})
+}
+
+// sameList reports whether two lists have the same items in the same order
+func sameList(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+#### ResolvNameservers
+Description: Does /etc/resolv.conf list exactly these nameservers, in this
+order? The resolver tries them in order, so a stale first nameserver slows
+down every lookup.
+Parameters:
+- Nameservers ([]IP address): Expected nameservers
+Example parameters:
+- 10.0.0.2, 10.0.0.3
+Dependencies:
+- /etc/resolv.conf
+*/
+
+type ResolvNameservers struct{ nameservers []string }
+
+func (chk ResolvNameservers) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	for _, param := range params {
+		if net.ParseIP(param) == nil {
+			return chk, errutil.ParameterTypeError{param, "IP address"}
+		}
+	}
+	chk.nameservers = params
+	return chk, nil
+}
+
+func (chk ResolvNameservers) resolvStatus(conf netstatus.ResolvConf) (int, string, error) {
+	if sameList(chk.nameservers, conf.Nameservers) {
+		return errutil.Success()
+	}
+	return errutil.GenericError("Unexpected nameservers in /etc/resolv.conf",
+		strings.Join(chk.nameservers, ", "), conf.Nameservers)
+}
+
+func (chk ResolvNameservers) Status() (int, string, error) {
+	conf, err := netstatus.ReadResolvConf()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.resolvStatus(conf)
+}
+
+/*
+#### ResolvSearch
+Description: Does /etc/resolv.conf search exactly these domains, in this
+order? Either a search or a domain line may set them, and the last one wins.
+Parameters:
+- Domains ([]string): Expected search domains
+Example parameters:
+- corp.example.com, example.com
+Dependencies:
+- /etc/resolv.conf
+*/
+
+type ResolvSearch struct{ domains []string }
+
+func (chk ResolvSearch) New(params []string) (chkutil.Check, error) {
+	if len(params) < 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.domains = params
+	return chk, nil
+}
+
+func (chk ResolvSearch) resolvStatus(conf netstatus.ResolvConf) (int, string, error) {
+	if sameList(chk.domains, conf.Search) {
+		return errutil.Success()
+	}
+	return errutil.GenericError("Unexpected search domains in /etc/resolv.conf",
+		strings.Join(chk.domains, " "), conf.Search)
+}
+
+func (chk ResolvSearch) Status() (int, string, error) {
+	conf, err := netstatus.ReadResolvConf()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.resolvStatus(conf)
+}
+
+/*
+#### ResolvOption
+Description: Is this option set in /etc/resolv.conf, with this value?
+Parameters:
+- Name (string): Name of the option, e.g. ndots, timeout, attempts or rotate
+- Value (string, optional): Expected value, for options that have one
+Example parameters:
+- ndots, timeout, rotate
+- 2, 1
+Dependencies:
+- /etc/resolv.conf
+*/
+
+type ResolvOption struct{ name, value string }
+
+func (chk ResolvOption) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 && len(params) != 2 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	chk.name = params[0]
+	if len(params) == 2 {
+		chk.value = params[1]
+	}
+	return chk, nil
+}
+
+func (chk ResolvOption) resolvStatus(conf netstatus.ResolvConf) (int, string, error) {
+	value, ok := conf.Options[chk.name]
+	if ok && value == chk.value {
+		return errutil.Success()
+	}
+	var options []string
+	for name, value := range conf.Options {
+		if value != "" {
+			name += ":" + value
+		}
+		options = append(options, name)
+	}
+	// map order is random, so sort them to give the same message every time
+	sort.Strings(options)
+	specified := chk.name
+	if chk.value != "" {
+		specified += ":" + chk.value
+	}
+	return errutil.GenericError("Option not set in /etc/resolv.conf", specified, options)
+}
+
+func (chk ResolvOption) Status() (int, string, error) {
+	conf, err := netstatus.ReadResolvConf()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.resolvStatus(conf)
+}
+
+/*
+#### HostsEntry
+Description: Does /etc/hosts map this hostname to this IP address? The address
+must be the first one of its family listed for the name, since that's the one
+lookups return.
+Parameters:
+- Hostname (string): Name, or alias, in /etc/hosts
+- IP (IP address): Expected address
+Example parameters:
+- db.example.com, localhost
+- 10.0.0.5, 127.0.0.1, ::1
+Dependencies:
+- /etc/hosts
+*/
+
+type HostsEntry struct {
+	name string
+	ip   net.IP
+}
+
+func (chk HostsEntry) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	ip := net.ParseIP(params[1])
+	if ip == nil {
+		return chk, errutil.ParameterTypeError{params[1], "IP address"}
+	}
+	chk.name = params[0]
+	chk.ip = ip
+	return chk, nil
+}
+
+func (chk HostsEntry) hostsStatus(entries []netstatus.HostsEntry) (int, string, error) {
+	addresses := netstatus.HostsAddresses(entries, chk.name)
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil || (ip.To4() == nil) != (chk.ip.To4() == nil) {
+			continue
+		}
+		if ip.Equal(chk.ip) {
+			return errutil.Success()
+		}
+		break
+	}
+	msg := "/etc/hosts didn't map " + chk.name + " to the expected address"
+	return errutil.GenericError(msg, chk.ip.String(), addresses)
+}
+
+func (chk HostsEntry) Status() (int, string, error) {
+	entries, err := netstatus.ReadHosts()
+	if err != nil {
+		return 1, "", err
+	}
+	return chk.hostsStatus(entries)
+}
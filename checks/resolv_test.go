@@ -0,0 +1,82 @@
+package checks
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/netstatus"
+)
+
+var testResolvConf = netstatus.ParseResolvConf([]byte(`search corp.example.com example.com
+nameserver 10.0.0.2
+nameserver 10.0.0.3
+options ndots:2 rotate
+`))
+
+func TestResolvNameservers(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"10.0.0.2"}, {"10.0.0.2", "fd00::2"}},
+		[][]string{{}, {"dns.example.com"}}, ResolvNameservers{}, t)
+	statuses := map[int][][]string{
+		0: {{"10.0.0.2", "10.0.0.3"}},
+		1: {{"10.0.0.2"}, {"10.0.0.3", "10.0.0.2"}, {"10.0.0.2", "10.0.0.3", "10.0.0.4"}},
+	}
+	testStatuses(t, statuses, ResolvNameservers{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(ResolvNameservers).resolvStatus(testResolvConf)
+		})
+}
+
+func TestResolvSearch(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"example.com"}}, [][]string{{}}, ResolvSearch{}, t)
+	statuses := map[int][][]string{
+		0: {{"corp.example.com", "example.com"}},
+		1: {{"corp.example.com"}, {"example.com", "corp.example.com"}},
+	}
+	testStatuses(t, statuses, ResolvSearch{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(ResolvSearch).resolvStatus(testResolvConf)
+		})
+}
+
+func TestResolvOption(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"rotate"}, {"ndots", "2"}},
+		[][]string{{}, {"ndots", "2", "3"}}, ResolvOption{}, t)
+	statuses := map[int][][]string{
+		0: {{"ndots", "2"}, {"rotate"}},
+		1: {{"ndots", "1"}, {"ndots"}, {"timeout", "1"}, {"rotate", "1"}},
+	}
+	testStatuses(t, statuses, ResolvOption{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(ResolvOption).resolvStatus(testResolvConf)
+		})
+	chk, _ := ResolvOption{}.New([]string{"timeout", "1"})
+	_, msg, _ := chk.(ResolvOption).resolvStatus(testResolvConf)
+	if !strings.HasSuffix(msg, "Actual: ndots:2, rotate") {
+		t.Errorf("Unexpected message: %s", msg)
+	}
+}
+
+func TestHostsEntry(t *testing.T) {
+	t.Parallel()
+	testParameters([][]string{{"localhost", "127.0.0.1"}, {"localhost", "::1"}},
+		[][]string{{}, {"localhost"}, {"localhost", "home"}}, HostsEntry{}, t)
+	testCheck([][]string{{"localhost", "127.0.0.1"}}, [][]string{{"localhost", "192.0.2.1"}},
+		HostsEntry{}, t)
+	entries := netstatus.ParseHosts([]byte(`127.0.0.1 localhost
+::1 localhost
+10.0.0.5 db.example.com db
+10.0.0.6 db
+`))
+	statuses := map[int][][]string{
+		0: {{"localhost", "::1"}, {"db", "10.0.0.5"}, {"DB.example.com", "10.0.0.5"}},
+		1: {{"db", "10.0.0.6"}, {"web", "10.0.0.7"}, {"db.example.com", "::1"}},
+	}
+	testStatuses(t, statuses, HostsEntry{}.New,
+		func(chk chkutil.Check) (int, string, error) {
+			return chk.(HostsEntry).hostsStatus(entries)
+		})
+}
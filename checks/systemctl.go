@@ -1,12 +1,19 @@
 package checks
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/CiscoCloud/distributive/chkutil"
 	"github.com/CiscoCloud/distributive/errutil"
 	"github.com/CiscoCloud/distributive/systemdstatus"
 	"github.com/CiscoCloud/distributive/tabular"
-	"os"
-	"strings"
+	log "github.com/Sirupsen/logrus"
 )
 
 /*
@@ -36,6 +43,12 @@ func init() {
     chkutil.Register("SystemctlUnitFileStatus", func() chkutil.Check {
         return &SystemctlUnitFileStatus{}
     })
+    chkutil.Register("SystemdUnitProperty", func() chkutil.Check {
+        return &SystemdUnitProperty{}
+    })
+    chkutil.Register("SystemdNoFailedUnits", func() chkutil.Check {
+        return &SystemdNoFailedUnits{}
+    })
 }
 
 func (chk SystemctlLoaded) New(params []string) (chkutil.Check, error) {
@@ -47,13 +60,17 @@ func (chk SystemctlLoaded) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk SystemctlLoaded) Status() (int, string, error) {
-	boo, err := systemdstatus.ServiceLoaded(chk.service)
+	if err := chkutil.RequireHost("SystemctlLoaded"); err != nil {
+		return 1, "", err
+	}
+	unit, err := systemdstatus.GetUnit(chk.service)
 	if err != nil {
 		return 1, "", err
-	} else if boo {
+	} else if unit.LoadState == "loaded" {
 		return errutil.Success()
 	}
-	return 1, "Service wasn't loaded: " + chk.service, nil
+	msg := "Service wasn't loaded: " + unit.Name + ", LoadState=" + unit.LoadState
+	return 1, msg, nil
 }
 
 /*
@@ -76,13 +93,17 @@ func (chk SystemctlActive) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk SystemctlActive) Status() (int, string, error) {
-	boo, err := systemdstatus.ServiceActive(chk.service)
+	if err := chkutil.RequireHost("SystemctlActive"); err != nil {
+		return 1, "", err
+	}
+	unit, err := systemdstatus.GetUnit(chk.service)
 	if err != nil {
 		return 1, "", err
-	} else if boo {
+	} else if unit.ActiveState == "active" {
 		return errutil.Success()
 	}
-	return 1, "Service wasn't active: " + chk.service, nil
+	msg := "Service wasn't active: " + unit.Name + ", ActiveState=" + unit.ActiveState
+	return 1, msg, nil
 }
 
 /*
@@ -99,7 +120,7 @@ type SystemctlSockListening struct{ path string }
 func (chk SystemctlSockListening) New(params []string) (chkutil.Check, error) {
 	if len(params) != 1 {
 		return chk, errutil.ParameterLengthError{1, params}
-	} else if _, err := os.Stat(params[0]); err != nil {
+	} else if _, err := os.Stat(chkutil.HostPath(params[0])); err != nil {
 		return chk, errutil.ParameterTypeError{params[0], "filepath"}
 	}
 	chk.path = params[0]
@@ -107,6 +128,9 @@ func (chk SystemctlSockListening) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk SystemctlSockListening) Status() (int, string, error) {
+	if err := chkutil.RequireHost("SystemctlSockListening"); err != nil {
+		return 1, "", err
+	}
 	listening, err := systemdstatus.ListeningSockets()
 	if err != nil {
 		return 1, "", err
@@ -148,6 +172,9 @@ func (chk SystemctlTimer) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk SystemctlTimer) Status() (int, string, error) {
+	if err := chkutil.RequireHost("SystemctlTimer"); err != nil {
+		return 1, "", err
+	}
 	return timerCheck(chk.unit, false)
 }
 
@@ -173,6 +200,9 @@ func (chk SystemctlTimerLoaded) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk SystemctlTimerLoaded) Status() (int, string, error) {
+	if err := chkutil.RequireHost("SystemctlTimerLoaded"); err != nil {
+		return 1, "", err
+	}
 	return timerCheck(chk.unit, true)
 }
 
@@ -204,6 +234,9 @@ func (chk SystemctlUnitFileStatus) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk SystemctlUnitFileStatus) Status() (int, string, error) {
+	if err := chkutil.RequireHost("SystemctlUnitFileStatus"); err != nil {
+		return 1, "", err
+	}
 	units, statuses, err := systemdstatus.UnitFileStatuses()
 	if err != nil {
 		return 1, "", err
@@ -229,3 +262,212 @@ func (chk SystemctlUnitFileStatus) Status() (int, string, error) {
 	msg := "Unit didn't have status"
 	return errutil.GenericError(msg, chk.status, []string{actualStatus})
 }
+
+/*
+#### SystemdUnitProperty
+Description: Does this property of a systemd unit (as shown by `systemctl show`)
+compare to this value with this operator? The operators are:
+  - == (or =) and != compare the property as a string, e.g. SubState ==
+    running.
+  - matches compares the property with a regular expression.
+  - <, <=, > and >= compare numeric properties, like NRestarts or
+    MemoryCurrent. Byte units are allowed, e.g. MemoryCurrent < 512mb.
+  - older and newer compare the age of timestamp properties with a duration,
+    e.g. ActiveEnterTimestamp older 10m fails if the unit restarted within
+    the last ten minutes.
+Parameters:
+  - Unit (string): Name of systemd unit
+  - Property (string): Name of the unit property
+  - Operator (string): == | != | matches | < | <= | > | >= | older | newer
+  - Value (string): Value to compare the property with
+Example parameters:
+  - nginx.service, backup.service, docker.socket
+  - SubState, NRestarts, ActiveEnterTimestamp, MemoryCurrent, Result
+  - ==, <, older
+  - running, 3, 10m, 512mb, success
+Dependencies:
+  - systemd's D-Bus API, or systemctl
+*/
+
+type SystemdUnitProperty struct {
+	unit, property, operator, value string
+	number                          float64
+	duration                        time.Duration
+	re                              *regexp.Regexp
+}
+
+// unitPropertyOperators are the operators that SystemdUnitProperty accepts
+var unitPropertyOperators = []string{
+	"==", "!=", "matches", "<", "<=", ">", ">=", "older", "newer",
+}
+
+// parseUnitPropertyNumber parses a plain number or an amount with byte units
+func parseUnitPropertyNumber(str string) (float64, error) {
+	if n, err := strconv.ParseFloat(str, 64); err == nil {
+		return n, nil
+	}
+	bytes, err := chkutil.ParseBytes(str)
+	return float64(bytes), err
+}
+
+func (chk SystemdUnitProperty) New(params []string) (chkutil.Check, error) {
+	if len(params) != 4 {
+		return chk, errutil.ParameterLengthError{4, params}
+	}
+	chk.unit, chk.property, chk.operator, chk.value =
+		params[0], params[1], params[2], params[3]
+	var err error
+	switch chk.operator {
+	case "=", "==", "!=":
+	case "matches":
+		chk.re, err = regexp.Compile(chk.value)
+		if err != nil {
+			return chk, errutil.ParameterTypeError{chk.value, "regexp"}
+		}
+	case "<", "<=", ">", ">=":
+		chk.number, err = parseUnitPropertyNumber(chk.value)
+		if err != nil {
+			return chk, errutil.ParameterTypeError{chk.value, "number"}
+		}
+	case "older", "newer":
+		chk.duration, err = time.ParseDuration(chk.value)
+		if err != nil {
+			return chk, errutil.ParameterTypeError{chk.value, "time.Duration"}
+		}
+	default:
+		validOperators := strings.Join(unitPropertyOperators, " | ")
+		return chk, errutil.ParameterTypeError{chk.operator, validOperators}
+	}
+	return chk, nil
+}
+
+// compare reports whether the property's value passes the check, and the
+// value as it should be reported if it doesn't
+func (chk SystemdUnitProperty) compare(props map[string]interface{}) (bool, string) {
+	str := systemdstatus.StringProperty(props, chk.property)
+	switch chk.operator {
+	case "=", "==":
+		return str == chk.value, str
+	case "!=":
+		return str != chk.value, str
+	case "matches":
+		return chk.re.MatchString(str), str
+	case "older", "newer":
+		t, ok := systemdstatus.TimeProperty(props, chk.property)
+		if !ok {
+			return false, "not set"
+		}
+		age := time.Since(t)
+		if chk.operator == "older" {
+			return age >= chk.duration, age.String() + " old"
+		}
+		return age <= chk.duration, age.String() + " old"
+	}
+	n, ok := systemdstatus.NumberProperty(props, chk.property)
+	if !ok {
+		return false, "not set"
+	}
+	passed := map[string]bool{
+		"<": n < chk.number, "<=": n <= chk.number,
+		">": n > chk.number, ">=": n >= chk.number,
+	}[chk.operator]
+	return passed, fmt.Sprint(n)
+}
+
+func (chk SystemdUnitProperty) Status() (int, string, error) {
+	if err := chkutil.RequireHost("SystemdUnitProperty"); err != nil {
+		return 1, "", err
+	}
+	props, err := systemdstatus.Properties(chk.unit)
+	if err != nil {
+		return 1, "", err
+	}
+	if _, ok := props[chk.property]; !ok {
+		return 1, "Unit doesn't have property: " + chk.property, nil
+	}
+	passed, actual := chk.compare(props)
+	if passed {
+		return errutil.Success()
+	}
+	msg := "Unit property didn't match: " + chk.unit + " " + chk.property
+	specified := chk.operator + " " + chk.value
+	return errutil.GenericError(msg, specified, []string{actual})
+}
+
+/*
+#### SystemdNoFailedUnits
+Description: Are there no failed systemd units, other than those matching one
+of these patterns? Failed units are listed with their Result and the last few
+lines they logged.
+Parameters:
+  - Patterns (glob, optional, any number): Names of units that may fail
+Example parameters:
+  - (none)
+  - systemd-networkd-wait-online.service, "backup-*.service"
+Dependencies:
+  - systemd's D-Bus API, or systemctl
+  - journalctl, for the log lines
+*/
+
+type SystemdNoFailedUnits struct{ ignore []string }
+
+// failedUnitLogLines is how many lines of each failed unit's log are shown
+const failedUnitLogLines = 3
+
+func (chk SystemdNoFailedUnits) New(params []string) (chkutil.Check, error) {
+	for _, pattern := range params {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return chk, errutil.ParameterTypeError{pattern, "glob"}
+		}
+	}
+	chk.ignore = params
+	return chk, nil
+}
+
+// unignoredUnits filters out the units that match any of the patterns
+func unignoredUnits(units []systemdstatus.Unit, patterns []string) (unignored []systemdstatus.Unit) {
+	for _, unit := range units {
+		ignored := false
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, unit.Name); matched {
+				ignored = true
+			}
+		}
+		if !ignored {
+			unignored = append(unignored, unit)
+		}
+	}
+	return unignored
+}
+
+func (chk SystemdNoFailedUnits) Status() (int, string, error) {
+	if err := chkutil.RequireHost("SystemdNoFailedUnits"); err != nil {
+		return 1, "", err
+	}
+	units, err := systemdstatus.FailedUnits()
+	if err != nil {
+		return 1, "", err
+	}
+	units = unignoredUnits(units, chk.ignore)
+	if len(units) == 0 {
+		return errutil.Success()
+	}
+	msg := "Units have failed:"
+	for _, unit := range units {
+		msg += "\n\t" + unit.Name
+		if unit.Result != "" {
+			msg += " (Result=" + unit.Result + ")"
+		}
+		lines, err := systemdstatus.LastLogLines(unit.Name, failedUnitLogLines)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"unit":  unit.Name,
+				"error": err.Error(),
+			}).Debug("Couldn't get log lines of failed unit")
+		}
+		for _, line := range lines {
+			msg += "\n\t\t" + line
+		}
+	}
+	return 1, msg, nil
+}
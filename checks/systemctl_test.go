@@ -2,6 +2,9 @@ package checks
 
 import (
 	"testing"
+	"time"
+
+	"github.com/CiscoCloud/distributive/systemdstatus"
 )
 
 var activeServices = [][]string{
@@ -50,3 +53,67 @@ func TestSystemctlUnitFileStatus(t *testing.T) {
 	testParameters(validInputs, notLengthTwo, SystemctlUnitFileStatus{}, t)
 	testCheck(goodEggs, validInputs, SystemctlUnitFileStatus{}, t)
 }
+
+func TestSystemdUnitProperty(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{
+		{"sshd.service", "SubState", "==", "running"},
+		{"sshd.service", "SubState", "matches", "^(running|exited)$"},
+		{"sshd.service", "NRestarts", "<", "3"},
+		{"sshd.service", "MemoryCurrent", "<=", "512mb"},
+		{"sshd.service", "ActiveEnterTimestamp", "older", "10m"},
+	}
+	invalidInputs := append(notLengthOne,
+		[]string{"sshd.service", "SubState", "~", "running"},
+		[]string{"sshd.service", "SubState", "matches", "(running"},
+		[]string{"sshd.service", "NRestarts", "<", "lots"},
+		[]string{"sshd.service", "ActiveEnterTimestamp", "older", "10"},
+	)
+	testParameters(validInputs, invalidInputs, SystemdUnitProperty{}, t)
+	props := map[string]interface{}{
+		"SubState":             "running",
+		"NRestarts":            uint32(2),
+		"MemoryCurrent":        uint64(1 << 20),
+		"MemoryLimit":          ^uint64(0),
+		"ActiveEnterTimestamp": uint64(time.Now().Add(-time.Hour).UnixNano() / 1000),
+		"Result":               "success",
+	}
+	for params, expected := range map[[3]string]bool{
+		{"SubState", "==", "running"}:            true,
+		{"SubState", "!=", "running"}:            false,
+		{"Result", "matches", "^succ"}:           true,
+		{"NRestarts", "<", "3"}:                  true,
+		{"NRestarts", ">=", "3"}:                 false,
+		{"MemoryCurrent", "<", "2mb"}:            true,
+		{"MemoryCurrent", ">", "1mb"}:            false,
+		{"MemoryLimit", "<", "1tb"}:              false,
+		{"ActiveEnterTimestamp", "older", "10m"}: true,
+		{"ActiveEnterTimestamp", "newer", "10m"}: false,
+		{"ActiveEnterTimestamp", "older", "2h"}:  false,
+	} {
+		chk, err := SystemdUnitProperty{}.New(
+			[]string{"sshd.service", params[0], params[1], params[2]},
+		)
+		if err != nil {
+			t.Fatalf("Couldn't create check with %v: %v", params, err)
+		}
+		actual, _ := chk.(SystemdUnitProperty).compare(props)
+		if actual != expected {
+			t.Errorf("SystemdUnitProperty %v: expected %v, got %v", params, expected, actual)
+		}
+	}
+}
+
+func TestSystemdNoFailedUnits(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{}, {"*.mount"}, {"backup-*.service", "foo"}}
+	invalidInputs := [][]string{{"[unclosed"}, {"foo", "ba[r"}}
+	testParameters(validInputs, invalidInputs, SystemdNoFailedUnits{}, t)
+	units := []systemdstatus.Unit{
+		{Name: "backup-daily.service"}, {Name: "home.mount"}, {Name: "nginx.service"},
+	}
+	unignored := unignoredUnits(units, []string{"backup-*.service", "*.mount"})
+	if len(unignored) != 1 || unignored[0].Name != "nginx.service" {
+		t.Errorf("unignoredUnits returned %+v", unignored)
+	}
+}
@@ -3,21 +3,20 @@ package checks
 import (
 	"fmt"
 	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/cpustatus"
 	"github.com/CiscoCloud/distributive/errutil"
 	"github.com/CiscoCloud/distributive/fsstatus"
 	"github.com/CiscoCloud/distributive/memstatus"
 	log "github.com/Sirupsen/logrus"
-	"io/ioutil"
-	"os"
 	"strconv"
 	"strings"
-	"syscall"
 	"time"
 )
 
 /*
 #### MemoryUsage
-Description: Is system memory usage below this threshold?
+Description: Is system memory usage below this threshold? Memory that's
+available for reuse, like the page cache, doesn't count as used.
 Parameters:
 - Percent (int8 percentage): Maximum acceptable percentage memory used
 Example parameters:
@@ -40,6 +39,12 @@ func init() {
     chkutil.Register("FreeSwap", func() chkutil.Check {
         return &FreeSwap{}
     })
+    chkutil.Register("AvailableMemory", func() chkutil.Check {
+        return &AvailableMemory{}
+    })
+    chkutil.Register("HugePagesFree", func() chkutil.Check {
+        return &HugePagesFree{}
+    })
     chkutil.Register("CPUUsage", func() chkutil.Check {
         return &CPUUsage{}
     })
@@ -49,6 +54,12 @@ func init() {
     chkutil.Register("InodeUsage", func() chkutil.Check {
         return &InodeUsage{}
     })
+    chkutil.Register("DiskFree", func() chkutil.Check {
+        return &DiskFree{}
+    })
+    chkutil.Register("InodesFree", func() chkutil.Check {
+        return &InodesFree{}
+    })
 }
 
 func (chk MemoryUsage) New(params []string) (chkutil.Check, error) {
@@ -64,7 +75,7 @@ func (chk MemoryUsage) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk MemoryUsage) Status() (int, string, error) {
-	actualPercentUsed, err := memstatus.FreeMemory("percent")
+	actualPercentUsed, err := memstatus.UsedMemory("percent")
 	if err != nil {
 		return 1, "", err
 	}
@@ -129,6 +140,8 @@ func freeMemOrSwap(input string, swapOrMem string) (int, string, error) {
 		actualAmount, err = memstatus.FreeMemory(units)
 	case "swap":
 		actualAmount, err = memstatus.FreeSwap(units)
+	case "available memory":
+		actualAmount, err = memstatus.AvailableMemory(units)
 	default:
 		log.Fatalf("Invalid option passed to freeMemoOrSwap: %s", swapOrMem)
 	}
@@ -192,48 +205,111 @@ func (chk FreeSwap) Status() (int, string, error) {
 	return freeMemOrSwap(chk.amount, "swap")
 }
 
-// getCPUSample helps CPUUsage do its thing. Taken from a stackoverflow:
-// http://stackoverflow.com/questions/11356330/getting-cpu-usage-with-golang
-func getCPUSample() (idle, total uint64) {
-	contents, err := ioutil.ReadFile("/proc/stat")
+/*
+#### AvailableMemory
+Description: Is at least this amount of memory available? Unlike free memory,
+this includes memory that the kernel can reclaim, like the page cache, so it's
+a better measure of how much can be used without swapping.
+Parameters:
+- Amount (string with byte unit): minimum acceptable amount of available memory
+Example parameters:
+- 100mb, 1gb, 3TB, 20kib
+Dependencies:
+- /proc/meminfo
+*/
+
+type AvailableMemory struct{ amount string }
+
+func (chk AvailableMemory) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	_, _, err := chkutil.SeparateByteUnits(params[0])
 	if err != nil {
-		return
-	}
-	lines := strings.Split(string(contents), "\n")
-	for _, line := range lines {
-		fields := strings.Fields(line)
-		if fields[0] == "cpu" {
-			numFields := len(fields)
-			for i := 1; i < numFields; i++ {
-				val, err := strconv.ParseUint(fields[i], 10, 64)
-				if err != nil {
-					fmt.Println("Error: ", i, fields[i], err)
-				}
-				total += val // tally up all the numbers to get total ticks
-				if i == 4 {  // idle is the 5th field in the cpu line
-					idle = val
-				}
-			}
-			return
-		}
-	}
-	return
+		return chk, errutil.ParameterTypeError{params[0], "amount"}
+	}
+	chk.amount = params[0]
+	return chk, nil
+}
+
+func (chk AvailableMemory) Status() (int, string, error) {
+	return freeMemOrSwap(chk.amount, "available memory")
 }
 
+/*
+#### HugePagesFree
+Description: Are at least this many huge pages free?
+Parameters:
+- Pages (positive int): minimum acceptable number of free huge pages
+Example parameters:
+- 1, 64, 1024
+Dependencies:
+- /proc/meminfo
+*/
+
+type HugePagesFree struct{ min uint64 }
+
+func (chk HugePagesFree) New(params []string) (chkutil.Check, error) {
+	if len(params) != 1 {
+		return chk, errutil.ParameterLengthError{1, params}
+	}
+	min, err := strconv.ParseUint(params[0], 10, 64)
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[0], "positive int"}
+	}
+	chk.min = min
+	return chk, nil
+}
+
+func (chk HugePagesFree) Status() (int, string, error) {
+	info, err := memstatus.Snapshot()
+	if err != nil {
+		return 1, "", err
+	} else if info.HugePagesFree >= chk.min {
+		return errutil.Success()
+	}
+	msg := "Fewer huge pages free than defined threshold"
+	slc := []string{fmt.Sprint(info.HugePagesFree)}
+	return errutil.GenericError(msg, fmt.Sprint(chk.min), slc)
+}
+
+// defaultCPUWindow is how long CPU usage is measured over by default
+const defaultCPUWindow = 3 * time.Second
+
 /*
 #### CPUUsage
-Description: Is the cpu usage below this percentage in a 3 second interval?
+Description: Is the cpu usage at most this percentage over this window?
 Parameters:
 - Percent (int8 percentage): Maximum acceptable percentage used
+- Window (time.Duration, optional): How long to measure usage over, 3s by
+  default
 Example parameters:
 - 95%, 90%, 87%
+- 1s, 10s
+Dependencies:
+- /proc/stat
 */
 
 // TODO use a uint
-type CPUUsage struct{ maxPercentUsed int8 }
+type CPUUsage struct {
+	maxPercentUsed int8
+	window         time.Duration
+}
+
+// parseCPUWindow parses an optional window to measure CPU usage over
+func parseCPUWindow(params []string, i int) (time.Duration, error) {
+	if len(params) <= i {
+		return defaultCPUWindow, nil
+	}
+	window, err := time.ParseDuration(params[i])
+	if err != nil || window <= 0 {
+		return 0, errutil.ParameterTypeError{params[i], "time.Duration"}
+	}
+	return window, nil
+}
 
 func (chk CPUUsage) New(params []string) (chkutil.Check, error) {
-	if len(params) != 1 {
+	if len(params) != 1 && len(params) != 2 {
 		return chk, errutil.ParameterLengthError{1, params}
 	}
 	per, err := strconv.ParseInt(strings.Replace(params[0], "%", "", -1), 10, 8)
@@ -241,25 +317,24 @@ func (chk CPUUsage) New(params []string) (chkutil.Check, error) {
 		return chk, errutil.ParameterTypeError{params[0], "int8"}
 	}
 	chk.maxPercentUsed = int8(per)
+	if chk.window, err = parseCPUWindow(params, 1); err != nil {
+		return chk, err
+	}
 	return chk, nil
 }
 
 func (chk CPUUsage) Status() (int, string, error) {
 	// TODO check that parameters are in range 0 < x < 100
-	cpuPercentUsed := func(sampleTime time.Duration) float32 {
-		idle0, total0 := getCPUSample()
-		time.Sleep(sampleTime)
-		idle1, total1 := getCPUSample()
-		idleTicks := float32(idle1 - idle0)
-		totalTicks := float32(total1 - total0)
-		return (100 * (totalTicks - idleTicks) / totalTicks)
-	}
-	actualPercentUsed := cpuPercentUsed(3 * time.Second)
-	if actualPercentUsed < float32(chk.maxPercentUsed) {
+	usages, err := cpustatus.Sample(chk.window)
+	if err != nil {
+		return 1, "", err
+	}
+	actualPercentUsed := usages[0].Used
+	if actualPercentUsed <= float64(chk.maxPercentUsed) {
 		return errutil.Success()
 	}
 	msg := "CPU usage above defined maximum"
-	slc := []string{fmt.Sprint(actualPercentUsed)}
+	slc := []string{fmt.Sprintf("%.1f", actualPercentUsed)}
 	return errutil.GenericError(msg, fmt.Sprint(chk.maxPercentUsed), slc)
 }
 
@@ -267,10 +342,11 @@ func (chk CPUUsage) Status() (int, string, error) {
 #### DiskUsage
 Description: Is the disk usage below this percentage?
 Parameters:
-- Path (filepath): Path to the disk
+- Path (filepath): Mountpoint or device of the disk, any file or directory on
+  it, or the source shown in mountinfo, e.g. tmpfs
 - Percent (int8 percentage): Maximum acceptable percentage used
 Example parameters:
-- /dev/sda1, /mnt/my-disk/
+- /dev/sda1, /mnt/my-disk/, tmpfs
 - 95%, 90%, 87%
 */
 
@@ -283,8 +359,6 @@ type DiskUsage struct {
 func (chk DiskUsage) New(params []string) (chkutil.Check, error) {
 	if len(params) != 2 {
 		return chk, errutil.ParameterLengthError{2, params}
-	} else if _, err := os.Stat(params[0]); err != nil {
-		return chk, errutil.ParameterTypeError{params[0], "dir"}
 	}
 	per, err := strconv.ParseInt(strings.Replace(params[1], "%", "", -1), 10, 8)
 	if err != nil {
@@ -296,23 +370,12 @@ func (chk DiskUsage) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk DiskUsage) Status() (int, string, error) {
-	// TODO: migrate to fsstatus
-	// percentFSUsed gets the percent of the filesystem that is occupied
-	percentFSUsed := func(path string) int {
-		// get FS info (*nix systems only!)
-		var stat syscall.Statfs_t
-		syscall.Statfs(path, &stat)
-
-		// blocks * size of block = available size
-		totalBytes := stat.Blocks * uint64(stat.Bsize)
-		availableBytes := stat.Bavail * uint64(stat.Bsize)
-		usedBytes := totalBytes - availableBytes
-		percentUsed := int((float64(usedBytes) / float64(totalBytes)) * 100)
-		return percentUsed
-
-	}
-	actualPercentUsed := percentFSUsed(chk.path)
-	if actualPercentUsed < int(chk.maxPercentUsed) {
+	usage, err := fsstatus.UsageOf(chk.path)
+	if err != nil {
+		return 1, "", err
+	}
+	actualPercentUsed := usage.PercentBytesUsed()
+	if actualPercentUsed < uint8(chk.maxPercentUsed) {
 		return errutil.Success()
 	}
 	msg := "More disk space used than expected"
@@ -324,7 +387,7 @@ func (chk DiskUsage) Status() (int, string, error) {
 #### InodeUsage
 Description: Is the inode usage below this percentage?
 Parameters:
-- Filesystem (string): Filesystem as shown by `df -i`
+- Filesystem (string): Mountpoint, device, or filesystem as shown by `df -i`
 - Percent (int8 percentage): Maximum acceptable percentage used
 Example parameters:
 - /dev/sda1, /mnt/my-disk/, tmpfs
@@ -361,3 +424,88 @@ func (chk InodeUsage) Status() (int, string, error) {
 	slc := []string{fmt.Sprint(actualPercentUsed) + "%"}
 	return errutil.GenericError(msg, fmt.Sprint(chk.maxPercentUsed)+"%", slc)
 }
+
+/*
+#### DiskFree
+Description: Is at least this much disk space free? Like `df`, this only counts
+space that's available to unprivileged users.
+Parameters:
+- Filesystem (string): Mountpoint, device, or filesystem as shown by `df`
+- Amount (string with byte unit): minimum acceptable amount of free space
+Example parameters:
+- /dev/sda1, /mnt/my-disk/, tmpfs
+- 100mb, 10gb, 1TB
+*/
+
+type DiskFree struct {
+	filesystem string
+	min        uint64
+	amount     string
+}
+
+func (chk DiskFree) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	min, err := chkutil.ParseBytes(params[1])
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[1], "amount"}
+	}
+	chk.filesystem = params[0]
+	chk.min = min
+	chk.amount = params[1]
+	return chk, nil
+}
+
+func (chk DiskFree) Status() (int, string, error) {
+	usage, err := fsstatus.UsageOf(chk.filesystem)
+	if err != nil {
+		return 1, "", err
+	} else if usage.AvailableBytes >= chk.min {
+		return errutil.Success()
+	}
+	msg := "Less disk space free than expected"
+	slc := []string{fmt.Sprint(usage.AvailableBytes) + "b"}
+	return errutil.GenericError(msg, chk.amount, slc)
+}
+
+/*
+#### InodesFree
+Description: Are at least this many inodes free?
+Parameters:
+- Filesystem (string): Mountpoint, device, or filesystem as shown by `df -i`
+- Inodes (positive int): minimum acceptable number of free inodes
+Example parameters:
+- /dev/sda1, /mnt/my-disk/, tmpfs
+- 1000, 50000, 1000000
+*/
+
+type InodesFree struct {
+	filesystem string
+	min        uint64
+}
+
+func (chk InodesFree) New(params []string) (chkutil.Check, error) {
+	if len(params) != 2 {
+		return chk, errutil.ParameterLengthError{2, params}
+	}
+	min, err := strconv.ParseUint(params[1], 10, 64)
+	if err != nil {
+		return chk, errutil.ParameterTypeError{params[1], "positive int"}
+	}
+	chk.filesystem = params[0]
+	chk.min = min
+	return chk, nil
+}
+
+func (chk InodesFree) Status() (int, string, error) {
+	usage, err := fsstatus.UsageOf(chk.filesystem)
+	if err != nil {
+		return 1, "", err
+	} else if usage.FreeInodes >= chk.min {
+		return errutil.Success()
+	}
+	msg := "Fewer inodes free than expected"
+	slc := []string{fmt.Sprint(usage.FreeInodes)}
+	return errutil.GenericError(msg, fmt.Sprint(chk.min), slc)
+}
@@ -1,8 +1,10 @@
 package checks
 
 import (
-	"github.com/CiscoCloud/distributive/chkutil"
+	"path/filepath"
 	"testing"
+
+	"github.com/CiscoCloud/distributive/chkutil"
 )
 
 var smallInts = [][]string{{"0"}, {"1"}, {"2"}}
@@ -21,6 +23,15 @@ func TestMemoryUsage(t *testing.T) {
 	testCheck(bigIntsUnder100, smallInts, MemoryUsage{}, t)
 }
 
+func TestCPUUsage(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"95%"}, {"90", "1s"}}
+	invalidInputs := [][]string{{}, {"most"}, {"90", "soon"}, {"90", "0s"}, {"90", "1s", "2"}}
+	testParameters(validInputs, invalidInputs, CPUUsage{}, t)
+	// nothing can use more than 100%, but an idle host can use 0%
+	testCheck([][]string{{"100", "100ms"}}, [][]string{}, CPUUsage{}, t)
+}
+
 func TestSwapUsage(t *testing.T) {
 	t.Parallel()
 	validInputs := append(smallInts, bigIntsUnder100...)
@@ -56,6 +67,19 @@ func TestFreeSwap(t *testing.T) {
 	testFreeMemoryOrSwap(t, FreeSwap{})
 }
 
+func TestAvailableMemory(t *testing.T) {
+	t.Parallel()
+	testFreeMemoryOrSwap(t, AvailableMemory{})
+}
+
+func TestHugePagesFree(t *testing.T) {
+	t.Parallel()
+	validInputs := append(smallInts, bigIntsUnder100...)
+	invalidInputs := append(append(notLengthOne, notInts...), negativeInts...)
+	testParameters(validInputs, invalidInputs, HugePagesFree{}, t)
+	testCheck([][]string{{"0"}}, reallyBigInts, HugePagesFree{}, t)
+}
+
 // $1 - path, $2 maxpercent
 func TestDiskUsage(t *testing.T) {
 	t.Parallel()
@@ -63,8 +87,13 @@ func TestDiskUsage(t *testing.T) {
 	invalidInputs := append(notLengthTwo,
 		[][]string{{"", ""}, {}, {"/", "garble"}}...,
 	)
-	goodEggs := [][]string{[]string{"/", "99"}}
-	badEggs := [][]string{[]string{"/", "1"}}
+	validInputs = append(validInputs, []string{"tmpfs", "95"})
+	file, err := filepath.Abs("usage_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goodEggs := [][]string{[]string{"/", "99"}, []string{file, "99"}}
+	badEggs := [][]string{[]string{"/", "1"}, []string{file, "1"}}
 	testParameters(validInputs, invalidInputs, DiskUsage{}, t)
 	testCheck(goodEggs, badEggs, DiskUsage{}, t)
 }
@@ -78,3 +107,29 @@ func TestInodeUsage(t *testing.T) {
 	)
 	testParameters(validInputs, invalidInputs, DiskUsage{}, t)
 }
+
+// $1 - filesystem, $2 - min free amount
+func TestDiskFree(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"/", "1b"}, {"tmpfs", "10gb"}, {"/dev/sda1", "5mb"}}
+	invalidInputs := append(notLengthTwo,
+		[][]string{{"", ""}, {}, {"/", "garble"}, {"/", "99999999tb"}}...,
+	)
+	goodEggs := [][]string{{"/", "0b"}, {"/", "1kb"}}
+	badEggs := [][]string{{"/", "999999tb"}}
+	testParameters(validInputs, invalidInputs, DiskFree{}, t)
+	testCheck(goodEggs, badEggs, DiskFree{}, t)
+}
+
+// $1 - filesystem, $2 - min free inodes
+func TestInodesFree(t *testing.T) {
+	t.Parallel()
+	validInputs := [][]string{{"/", "1"}, {"tmpfs", "1000"}}
+	invalidInputs := append(notLengthTwo,
+		[][]string{{"", ""}, {}, {"/", "garble"}, {"/", "-1"}}...,
+	)
+	goodEggs := [][]string{{"/", "0"}}
+	badEggs := [][]string{{"/", "18446744073709551615"}}
+	testParameters(validInputs, invalidInputs, InodesFree{}, t)
+	testCheck(goodEggs, badEggs, InodesFree{}, t)
+}
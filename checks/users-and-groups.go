@@ -6,17 +6,81 @@ package checks
 // https://github.com/aelsabbahy/goss/blob/d28f3cc6d708fb012ea614acf712eb56712a7de3/LICENSE
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 
 	"github.com/CiscoCloud/distributive/chkutil"
 	"github.com/CiscoCloud/distributive/errutil"
 	"github.com/CiscoCloud/distributive/tabular"
-	gossuser "github.com/aelsabbahy/goss/system"
-	gossutil "github.com/aelsabbahy/goss/util"
 	libcontaineruser "github.com/opencontainers/runc/libcontainer/user"
 )
 
+// lookupUser finds a user by name in /etc/passwd, under the root
+func lookupUser(name string) (libcontaineruser.User, error) {
+	path := chkutil.HostPath("/etc/passwd")
+	users, err := libcontaineruser.ParsePasswdFileFilter(path,
+		func(usr libcontaineruser.User) bool { return usr.Name == name })
+	if err != nil {
+		return libcontaineruser.User{}, err
+	} else if len(users) < 1 {
+		return libcontaineruser.User{}, errors.New("No such user: " + name)
+	}
+	return users[0], nil
+}
+
+// userName returns the name of the user with this UID, or the UID itself if
+// there's no such user in /etc/passwd
+func userName(uid int) string {
+	path := chkutil.HostPath("/etc/passwd")
+	users, err := libcontaineruser.ParsePasswdFileFilter(path,
+		func(usr libcontaineruser.User) bool { return usr.Uid == uid })
+	if err != nil || len(users) < 1 {
+		return strconv.Itoa(uid)
+	}
+	return users[0].Name
+}
+
+// userID returns the UID of a user given by name or UID
+func userID(user string) (int, error) {
+	if uid, err := strconv.Atoi(user); err == nil {
+		return uid, nil
+	}
+	usr, err := lookupUser(user)
+	return usr.Uid, err
+}
+
+// lookupGroup finds a group by name in /etc/group, under the root
+func lookupGroup(name string) (libcontaineruser.Group, error) {
+	path := chkutil.HostPath("/etc/group")
+	groups, err := libcontaineruser.ParseGroupFileFilter(path,
+		func(grp libcontaineruser.Group) bool { return grp.Name == name })
+	if err != nil {
+		return libcontaineruser.Group{}, err
+	} else if len(groups) < 1 {
+		return libcontaineruser.Group{}, errors.New("No such group: " + name)
+	}
+	return groups[0], nil
+}
+
+// userGroups lists the names of the groups a user is in, including their
+// primary group
+func userGroups(name string) (names []string, err error) {
+	usr, err := lookupUser(name)
+	if err != nil {
+		return names, err
+	}
+	path := chkutil.HostPath("/etc/group")
+	groups, err := libcontaineruser.ParseGroupFileFilter(path,
+		func(grp libcontaineruser.Group) bool {
+			return grp.Gid == usr.Gid || tabular.StrIn(name, grp.List)
+		})
+	for _, grp := range groups {
+		names = append(names, grp.Name)
+	}
+	return names, err
+}
+
 // validGroupName asks: Is this a valid POSIX+Linux group name?
 func validGroupName(name string) bool {
 	return !strings.Contains(name, ":")
@@ -54,11 +118,10 @@ func (chk GroupExists) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk GroupExists) Status() (int, string, error) {
-	_, err := libcontaineruser.LookupGroup(chk.name)
-	if err != nil {
-		return 1, "", err
+	if _, err := lookupGroup(chk.name); err == nil {
+		return errutil.Success()
 	}
-	return errutil.Success()
+	return 1, "Group does not exist: " + chk.name, nil
 }
 
 /*
@@ -112,8 +175,7 @@ func (chk UserInGroup) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UserInGroup) Status() (int, string, error) {
-	usr := gossuser.NewDefUser(chk.user, nil, gossutil.Config{})
-	groups, err := usr.Groups()
+	groups, err := userGroups(chk.user)
 	if err != nil {
 		return 1, "", err
 	} else if tabular.StrIn(chk.group, groups) {
@@ -154,7 +216,7 @@ func (chk GroupID) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk GroupID) Status() (int, string, error) {
-	group, err := libcontaineruser.LookupGroup(chk.name)
+	group, err := lookupGroup(chk.name)
 	if err != nil {
 		return 1, "", err
 	} else if group.Gid == chk.id {
@@ -185,7 +247,7 @@ func (chk UserExists) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UserExists) Status() (int, string, error) {
-	if _, err := libcontaineruser.LookupUser(chk.username); err == nil {
+	if _, err := lookupUser(chk.username); err == nil {
 		return errutil.Success()
 	}
 	return 1, "User does not exist: " + chk.username, nil
@@ -224,7 +286,7 @@ func (chk UserHasUID) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UserHasUID) Status() (int, string, error) {
-	usr, err := libcontaineruser.LookupUser(chk.username)
+	usr, err := lookupUser(chk.username)
 	if err != nil {
 		return 1, "", err
 	} else if usr.Uid == chk.expectedUID {
@@ -265,7 +327,7 @@ func (chk UserHasGID) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UserHasGID) Status() (int, string, error) {
-	usr, err := libcontaineruser.LookupUser(chk.username)
+	usr, err := lookupUser(chk.username)
 	if err != nil {
 		return 1, "", err
 	} else if usr.Gid == chk.expectedGID {
@@ -299,7 +361,7 @@ func (chk UserHasHomeDir) New(params []string) (chkutil.Check, error) {
 }
 
 func (chk UserHasHomeDir) Status() (int, string, error) {
-	usr, err := libcontaineruser.LookupUser(chk.username)
+	usr, err := lookupUser(chk.username)
 	if err != nil {
 		return 1, "", err
 	} else if usr.Home == chk.expectedHomeDir {
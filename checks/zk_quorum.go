@@ -51,7 +51,7 @@ func (chk ZooKeeperQuorum) New(params []string) (chkutil.Check, error) {
 
 func (chk ZooKeeperQuorum) LoadConfig() ([]string, error) {
 	beginOfServerLine := regexp.MustCompile("^server\\.\\d+=(.*)$")
-	file, err := os.Open(chk.config)
+	file, err := os.Open(chkutil.HostPath(chk.config))
 	if err != nil {
 		return nil, err
 	}
@@ -8,6 +8,7 @@ import (
 	"github.com/CiscoCloud/distributive/tabular"
 	log "github.com/Sirupsen/logrus"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os/exec"
 	"regexp"
@@ -28,8 +29,8 @@ type Check interface {
 	// msg is a descriptive, human-readable description of the status.
 	//
 	// code is exit code defining whether or not this check is passing.  0 is
-	// considered passing, 1 is failing, with other values reserved for later
-	// use.
+	// considered passing, 1 is failing, and 2 is a warning, which is reported
+	// but doesn't fail the run. Other values are reserved for later use.
 	Status() (code int, msg string, err error)
 }
 
@@ -82,17 +83,21 @@ func SeparateByteUnits(str string) (int, string, error) {
 	// getByteUnits takes an arbitrary string containing any crazy possible
 	// string representing byte units and returns something normal like kb, mb.
 	getByteUnits := func(str string) (string, error) {
-		regexps := map[string]*regexp.Regexp{
+		// in order, since "b" also matches e.g. the iB of TiB
+		regexps := []struct {
+			unit string
+			re   *regexp.Regexp
+		}{
+			{"tb", regexp.MustCompile(`tera(bytes){0,1}|[tT]{1}[iI]{0,1}[bB]{1}`)},
+			{"gb", regexp.MustCompile(`giga(bytes){0,1}|[gG]{1}[iI]{0,1}[bB]{1}`)},
+			{"mb", regexp.MustCompile(`mega(bytes){0,1}|[mM]{1}[iI]{0,1}[bB]{1}`)},
+			{"kb", regexp.MustCompile(`kilo(bytes){0,1}|[kK]{1}[iI]{0,1}[bB]{1}`)},
 			// because "bytes" is harder, use it last
-			"tb": regexp.MustCompile(`tera(bytes){0,1}|[tT]{1}[iI]{0,1}[bB]{1}`),
-			"gb": regexp.MustCompile(`giga(bytes){0,1}|[gG]{1}[iI]{0,1}[bB]{1}`),
-			"mb": regexp.MustCompile(`mega(bytes){0,1}|[mM]{1}[iI]{0,1}[bB]{1}`),
-			"kb": regexp.MustCompile(`kilo(bytes){0,1}|[kK]{1}[iI]{0,1}[bB]{1}`),
-			"b":  regexp.MustCompile(`[^oa]bytes{0,1}|[^kKmMgGtT][bB]{1}`),
+			{"b", regexp.MustCompile(`[^oa]bytes{0,1}|[^kKmMgGtT][bB]{1}`)},
 		}
-		for unit, re := range regexps {
-			if re.MatchString(str) {
-				return unit, nil
+		for _, unit := range regexps {
+			if unit.re.MatchString(str) {
+				return unit.unit, nil
 			}
 		}
 		return "", errors.New("Couldn't extract byte units from string " + str)
@@ -122,6 +127,29 @@ func SeparateByteUnits(str string) (int, string, error) {
 	return scalar, unit, nil
 }
 
+// ByteUnits are the sizes of the units that SeparateByteUnits returns. Like
+// the kernel (and unlike some versions of `free`), a kb is 1024 bytes.
+var ByteUnits = map[string]uint64{
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+}
+
+// ParseBytes parses an amount with byte units, like 90KB, into bytes
+func ParseBytes(str string) (uint64, error) {
+	amount, units, err := SeparateByteUnits(str)
+	if err != nil {
+		return 0, err
+	}
+	multiplier := ByteUnits[units]
+	if uint64(amount) > math.MaxUint64/multiplier {
+		return 0, errors.New("Amount of bytes is too large: " + str)
+	}
+	return uint64(amount) * multiplier, nil
+}
+
 // SubmatchMap returns a map of submatch names to their captures, if any.
 // If no matches are found, it returns an empty dict.
 // Submatch names are specified using (?P<name>[matchme])
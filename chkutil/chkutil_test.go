@@ -58,6 +58,23 @@ func TestSeparateByteUnits(t *testing.T) {
 	}
 }
 
+func TestParseBytes(t *testing.T) {
+	t.Parallel()
+	amounts := map[string]uint64{
+		"10b": 10, "12Kb": 12 << 10, "100mb": 100 << 20, "3017TiB": 3017 << 40,
+	}
+	for input, expected := range amounts {
+		if actual, err := ParseBytes(input); err != nil || actual != expected {
+			t.Errorf("Unexpected bytes in %s: %d, %v", input, actual, err)
+		}
+	}
+	for _, input := range []string{"lots", "100", "99999999tb"} {
+		if _, err := ParseBytes(input); err == nil {
+			t.Errorf("Expected an error parsing %q", input)
+		}
+	}
+}
+
 // TODO
 func TestSubmatchMap(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,189 @@
+package chkutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/CiscoCloud/distributive/errutil"
+	log "github.com/Sirupsen/logrus"
+)
+
+/// External check plugins
+//
+// A plugin is any executable file in the plugin directory. It is run with a
+// single argument, which is one of:
+//
+//   describe - print a PluginDescription as JSON on stdout
+//   validate - read a PluginRequest from stdin, print a PluginResponse whose
+//              "error" field is empty if the parameters are acceptable
+//   status   - read a PluginRequest from stdin, print a PluginResponse
+//
+// Plugins are registered under the ID they describe, and can then be used in
+// checklists just like any built-in check.
+
+// PluginTimeout is how long a plugin may run before it is killed
+var PluginTimeout = 30 * time.Second
+
+// PluginDescription is what a plugin prints when run with "describe"
+type PluginDescription struct {
+	// ID is the name of the check, as used in checklists
+	ID string `json:"id"`
+	// Parameters are the names of the parameters the check expects
+	Parameters []string `json:"parameters"`
+	// Variadic plugins accept any number of parameters >= len(Parameters)
+	Variadic bool `json:"variadic"`
+	// Validate asks distributive to run the plugin with "validate" when the
+	// check is constructed, instead of only checking the parameter count
+	Validate bool `json:"validate"`
+}
+
+// PluginRequest is passed to a plugin on stdin
+type PluginRequest struct {
+	Parameters []string `json:"parameters"`
+}
+
+// PluginResponse is read from a plugin's stdout
+type PluginResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+// runPlugin executes the plugin at path with the given argument, writes input
+// to its stdin, and returns its stdout.
+func runPlugin(path string, arg string, input []byte) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, arg)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			msg := "Plugin %s %s failed: %s: %s"
+			return nil, fmt.Errorf(msg, path, arg, err, stderr.String())
+		}
+	case <-time.After(PluginTimeout):
+		cmd.Process.Kill()
+		<-done
+		msg := "Plugin %s %s timed out after %s"
+		return nil, fmt.Errorf(msg, path, arg, PluginTimeout)
+	}
+	return stdout.Bytes(), nil
+}
+
+// callPlugin sends params to the plugin and parses its response
+func callPlugin(path string, arg string, params []string) (resp PluginResponse, err error) {
+	input, err := json.Marshal(PluginRequest{Parameters: params})
+	if err != nil {
+		return resp, err
+	}
+	out, err := runPlugin(path, arg, input)
+	if err != nil {
+		return resp, err
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		msg := "Couldn't parse response from plugin %s: %s"
+		return resp, fmt.Errorf(msg, path, err)
+	}
+	return resp, nil
+}
+
+// DescribePlugin runs the plugin at path with "describe" and parses the result
+func DescribePlugin(path string) (desc PluginDescription, err error) {
+	out, err := runPlugin(path, "describe", nil)
+	if err != nil {
+		return desc, err
+	}
+	if err := json.Unmarshal(out, &desc); err != nil {
+		msg := "Couldn't parse description from plugin %s: %s"
+		return desc, fmt.Errorf(msg, path, err)
+	} else if desc.ID == "" {
+		return desc, errors.New("Plugin didn't describe its ID: " + path)
+	}
+	return desc, nil
+}
+
+// Plugin is a Check that is implemented by an external executable
+type Plugin struct {
+	path   string
+	desc   PluginDescription
+	params []string
+}
+
+func (chk Plugin) New(params []string) (Check, error) {
+	expected := len(chk.desc.Parameters)
+	if len(params) < expected || (!chk.desc.Variadic && len(params) > expected) {
+		return chk, errutil.ParameterLengthError{expected, params}
+	}
+	if chk.desc.Validate {
+		resp, err := callPlugin(chk.path, "validate", params)
+		if err != nil {
+			return chk, err
+		} else if resp.Error != "" {
+			return chk, errors.New(resp.Error)
+		}
+	}
+	chk.params = params
+	return chk, nil
+}
+
+func (chk Plugin) Status() (int, string, error) {
+	resp, err := callPlugin(chk.path, "status", chk.params)
+	if err != nil {
+		return 1, "", err
+	} else if resp.Error != "" {
+		return 1, resp.Message, errors.New(resp.Error)
+	}
+	return resp.Code, resp.Message, nil
+}
+
+// isExecutable reports whether this is a regular file that anyone can execute
+func isExecutable(finfo os.FileInfo) bool {
+	return finfo.Mode().IsRegular() && finfo.Mode().Perm()&0111 != 0
+}
+
+// LoadPlugins describes every executable in dir and registers each of them as
+// a check. It refuses to replace checks that are already registered. It
+// returns the IDs of the plugins that were registered.
+func LoadPlugins(dir string) (ids []string, err error) {
+	finfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ids, err
+	}
+	for _, finfo := range finfos {
+		path := filepath.Join(dir, finfo.Name())
+		// follow symlinks, so plugins can be linked into the directory
+		if finfo, err := os.Stat(path); err != nil || !isExecutable(finfo) {
+			continue
+		}
+		desc, err := DescribePlugin(path)
+		if err != nil {
+			return ids, err
+		} else if LookupCheck(desc.ID) != nil {
+			msg := "Plugin %s would replace the existing check %s"
+			return ids, fmt.Errorf(msg, path, desc.ID)
+		}
+		log.WithFields(log.Fields{
+			"id":   desc.ID,
+			"path": path,
+		}).Debug("Registering plugin")
+		Register(desc.ID, func() Check {
+			return &Plugin{path: path, desc: desc}
+		})
+		ids = append(ids, desc.ID)
+	}
+	return ids, nil
+}
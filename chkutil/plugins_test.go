@@ -0,0 +1,84 @@
+package chkutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPlugin passes when its only parameter is "pass"
+var testPlugin = `#!/bin/sh
+case "$1" in
+describe)
+	echo '{"id": "TestPlugin", "parameters": ["word"]}' ;;
+status)
+	if grep -q '"pass"'; then
+		echo '{"code": 0}'
+	else
+		echo '{"code": 1, "message": "Word was not pass"}'
+	fi ;;
+esac
+`
+
+// writePlugin makes a temporary plugin directory containing one plugin
+func writePlugin(t *testing.T, name string, script string) string {
+	dir, err := ioutil.TempDir("", "distributive-plugins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// not executable, so not a plugin
+	ioutil.WriteFile(filepath.Join(dir, "README"), []byte("hi"), 0644)
+	return dir
+}
+
+func TestLoadPlugins(t *testing.T) {
+	t.Parallel()
+	dir := writePlugin(t, "test-plugin", testPlugin)
+	defer os.RemoveAll(dir)
+	ids, err := LoadPlugins(dir)
+	if err != nil {
+		t.Fatalf("LoadPlugins failed: %v", err)
+	} else if len(ids) != 1 || ids[0] != "TestPlugin" {
+		t.Fatalf("LoadPlugins registered unexpected plugins: %v", ids)
+	}
+	chk := LookupCheck("testplugin")
+	if chk == nil {
+		t.Fatal("Plugin wasn't registered")
+	}
+	if _, err := chk.New([]string{}); err == nil {
+		t.Error("Plugin accepted the wrong number of parameters")
+	}
+	for param, expected := range map[string]int{"pass": 0, "fail": 1} {
+		newChk, err := chk.New([]string{param})
+		if err != nil {
+			t.Fatalf("Plugin rejected valid parameters: %v", err)
+		}
+		code, msg, err := newChk.Status()
+		if err != nil {
+			t.Errorf("Plugin returned an error: %v", err)
+		} else if code != expected {
+			t.Errorf("Plugin returned code %v for %v, msg: %v", code, param, msg)
+		}
+	}
+	// loading the same plugins twice would replace them
+	if _, err := LoadPlugins(dir); err == nil {
+		t.Error("LoadPlugins replaced an existing check")
+	}
+}
+
+func TestDescribePlugin(t *testing.T) {
+	t.Parallel()
+	dir := writePlugin(t, "bad-plugin", "#!/bin/sh\necho '{\"id\": \"\"}'\n")
+	defer os.RemoveAll(dir)
+	if _, err := DescribePlugin(filepath.Join(dir, "bad-plugin")); err == nil {
+		t.Error("DescribePlugin accepted a plugin without an ID")
+	}
+	if _, err := DescribePlugin(filepath.Join(dir, "README")); err == nil {
+		t.Error("DescribePlugin accepted a file that can't be executed")
+	}
+}
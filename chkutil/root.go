@@ -0,0 +1,144 @@
+package chkutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/// Alternate roots
+//
+// Distributive can inspect a filesystem other than the one it's running in,
+// e.g. the host's filesystem mounted into a container, or an unpacked VM
+// image. File-based checks look up their paths under the root, and /proc and
+// /sys readers look under the proc and sys directories.
+
+var root = "/"
+var procDir = "/proc"
+var sysDir = "/sys"
+
+// maxSymlinks is how many symlinks HostPath will follow before giving up
+const maxSymlinks = 255
+
+// SetRoot sets the root, proc and sys directories that checks inspect. Empty
+// proc and sys directories default to proc/ and sys/ under the root.
+func SetRoot(rootDir, proc, sys string) {
+	if rootDir == "" {
+		rootDir = "/"
+	}
+	root = filepath.Clean(rootDir)
+	procDir = filepath.Join(root, "proc")
+	if proc != "" {
+		procDir = filepath.Clean(proc)
+	}
+	sysDir = filepath.Join(root, "sys")
+	if sys != "" {
+		sysDir = filepath.Clean(sys)
+	}
+}
+
+// Root returns the root directory that checks inspect
+func Root() string { return root }
+
+// AlternateRoot reports whether checks are inspecting something other than
+// the filesystem distributive is running in
+func AlternateRoot() bool { return root != "/" }
+
+// AlternateProc reports whether /proc is being read from somewhere other than
+// the proc directory of the system distributive is running in
+func AlternateProc() bool { return procDir != "/proc" }
+
+// RequireHost returns an error if checks are inspecting an alternate root,
+// proc or sys. Checks that can only inspect the running system (e.g. because
+// they call systemctl or docker) use it to avoid silently reporting on the
+// wrong one.
+func RequireHost(id string) error {
+	alternate := ""
+	switch {
+	case AlternateRoot():
+		alternate = "root: " + root
+	case AlternateProc():
+		alternate = "proc directory: " + procDir
+	case sysDir != "/sys":
+		alternate = "sys directory: " + sysDir
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s can't be checked against an alternate %s", id, alternate)
+}
+
+// resolveInRoot finds path under the root, resolving symlinks as though the
+// root were /, so that absolute links and ..s can't escape it. If followLast
+// is false, the last element of the path is left as-is when it's a symlink.
+func resolveInRoot(path string, followLast bool) string {
+	resolved := "/"
+	remaining := strings.Split(path, "/")
+	links := 0
+	for len(remaining) > 0 {
+		elem := remaining[0]
+		remaining = remaining[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, elem)
+		if len(remaining) == 0 && !followLast {
+			resolved = next
+			break
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil || links >= maxSymlinks {
+			resolved = next
+			continue
+		}
+		links++
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = append(strings.Split(target, "/"), remaining...)
+	}
+	return filepath.Join(root, resolved)
+}
+
+// HostPath returns where path is found under the root, following symlinks
+// within the root. Without an alternate root, it returns path unchanged.
+func HostPath(path string) string {
+	if !AlternateRoot() {
+		return path
+	}
+	return resolveInRoot(path, true)
+}
+
+// HostLinkPath is like HostPath, but doesn't follow a symlink at the end of
+// the path, so that the link itself can be inspected.
+func HostLinkPath(path string) string {
+	if !AlternateRoot() {
+		return path
+	}
+	return resolveInRoot(path, false)
+}
+
+// ProcPath joins elems onto the proc directory, e.g. ProcPath("stat")
+func ProcPath(elems ...string) string {
+	return filepath.Join(append([]string{procDir}, elems...)...)
+}
+
+// ProcSelfPath is like ProcPath, but under the directory of the process that
+// represents the inspected system: /proc/self normally, or init's directory
+// under an alternate proc, where "self" would be distributive itself.
+func ProcSelfPath(elems ...string) string {
+	self := "self"
+	if AlternateProc() {
+		self = "1"
+	}
+	return ProcPath(append([]string{self}, elems...)...)
+}
+
+// SysPath joins elems onto the sys directory, e.g. SysPath("class", "net")
+func SysPath(elems ...string) string {
+	return filepath.Join(append([]string{sysDir}, elems...)...)
+}
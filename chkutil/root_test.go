@@ -0,0 +1,63 @@
+package chkutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// not parallel, since it changes the root for the whole package
+func TestHostPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distributive-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "etc", "real"), 0755)
+	os.Symlink("/etc/real", filepath.Join(dir, "etc", "absolute"))
+	os.Symlink("real", filepath.Join(dir, "etc", "relative"))
+	os.Symlink("../../../..", filepath.Join(dir, "etc", "escape"))
+
+	SetRoot(dir, "", "")
+	defer SetRoot("/", "", "")
+	paths := map[string]string{
+		"/etc/passwd":          "etc/passwd",
+		"/etc/absolute/file":   "etc/real/file",
+		"/etc/relative/file":   "etc/real/file",
+		"/etc/escape/etc/file": "etc/file",
+		"/etc/../../etc/file":  "etc/file",
+		"/etc/absolute":        "etc/real",
+	}
+	for path, expected := range paths {
+		expected = filepath.Join(dir, expected)
+		if actual := HostPath(path); actual != expected {
+			t.Errorf("HostPath(%q): expected %q, got %q", path, expected, actual)
+		}
+	}
+	linkPath := filepath.Join(dir, "etc", "absolute")
+	if actual := HostLinkPath("/etc/absolute"); actual != linkPath {
+		t.Errorf("HostLinkPath didn't leave the link: expected %q, got %q", linkPath, actual)
+	}
+	if ProcPath("stat") != filepath.Join(dir, "proc", "stat") {
+		t.Errorf("ProcPath wasn't under the root: %q", ProcPath("stat"))
+	}
+	if err := RequireHost("Test"); err == nil {
+		t.Error("RequireHost didn't fail with an alternate root")
+	}
+
+	SetRoot("/", "/host/proc", "")
+	if HostPath("/etc/passwd") != "/etc/passwd" || SysPath("class") != "/sys/class" {
+		t.Error("Paths changed without an alternate root")
+	}
+	if !AlternateProc() || AlternateRoot() {
+		t.Error("Expected only an alternate proc directory")
+	}
+	if ProcSelfPath("mounts") != "/host/proc/1/mounts" {
+		t.Errorf("Unexpected ProcSelfPath: %q", ProcSelfPath("mounts"))
+	}
+	if err := RequireHost("Test"); err == nil || !strings.HasSuffix(err.Error(), "/host/proc") {
+		t.Errorf("RequireHost didn't name the proc directory: %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package chkutil
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/// State between runs
+//
+// Some checks compare what they see with what they saw on their last run,
+// e.g. to only read the lines appended to a log since then. They keep that
+// state as JSON files in the state directory.
+
+// DefaultStateDir is where state is kept unless SetStateDir is called
+const DefaultStateDir = "/var/lib/distributive"
+
+var stateDir = DefaultStateDir
+
+// SetStateDir sets the directory that checks keep their state in
+func SetStateDir(dir string) {
+	if dir == "" {
+		dir = DefaultStateDir
+	}
+	stateDir = filepath.Clean(dir)
+}
+
+// statePath is the file that the state for this check and key is kept in.
+// Keys are often paths, so they're hashed to get a valid filename.
+func statePath(id, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(stateDir, fmt.Sprintf("%s-%x.json", id, sum[:8]))
+}
+
+// LoadState reads the state that the check id saved for key into state. found
+// is false if nothing has been saved yet.
+func LoadState(id, key string, state interface{}) (found bool, err error) {
+	data, err := ioutil.ReadFile(statePath(id, key))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return false, fmt.Errorf("Couldn't parse state of %s: %v", id, err)
+	}
+	return true, nil
+}
+
+// SaveState saves state for the check id and key, for LoadState to read on
+// the next run. The file is replaced atomically, so that concurrent runs
+// don't see partially written state.
+func SaveState(id, key string, state interface{}) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return err
+	}
+	path := statePath(id, key)
+	tmp, err := ioutil.TempFile(stateDir, filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
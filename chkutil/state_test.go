@@ -0,0 +1,34 @@
+package chkutil
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distributive-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	SetStateDir(dir)
+	defer SetStateDir("")
+
+	type offset struct{ Offset int64 }
+	var loaded offset
+	if found, err := LoadState("Test", "/var/log/syslog", &loaded); err != nil || found {
+		t.Errorf("LoadState found state before any was saved: %v, %v", found, err)
+	}
+	if err := SaveState("Test", "/var/log/syslog", offset{42}); err != nil {
+		t.Fatalf("SaveState failed unexpectedly: %v", err)
+	}
+	if found, err := LoadState("Test", "/var/log/syslog", &loaded); err != nil || !found {
+		t.Errorf("LoadState didn't find saved state: %v, %v", found, err)
+	} else if loaded.Offset != 42 {
+		t.Errorf("LoadState: expected 42, got %v", loaded.Offset)
+	}
+	if found, _ := LoadState("Test", "/var/log/messages", &loaded); found {
+		t.Error("LoadState found state saved under a different key")
+	}
+}
@@ -0,0 +1,223 @@
+// cpustatus provides information about CPU usage and load on the host, and
+// the pressure stall information of its CPU, memory and IO.
+package cpustatus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+// CPUTimes is the time a CPU has spent in each state since boot, in clock
+// ticks, from a cpu line of /proc/stat
+type CPUTimes struct {
+	// Name is "cpu" for all of the CPUs together, or e.g. "cpu0" for one
+	Name                                                  string
+	User, Nice, System, Idle, IOWait, IRQ, SoftIRQ, Steal uint64
+}
+
+// Total is the time spent in every state. Time spent running guests is
+// already counted as user time.
+func (times CPUTimes) Total() uint64 {
+	return times.User + times.Nice + times.System + times.Idle + times.IOWait +
+		times.IRQ + times.SoftIRQ + times.Steal
+}
+
+// ParseStat parses the cpu lines of /proc/stat. The first is all of the CPUs
+// together, followed by one for each online CPU.
+func ParseStat(data []byte) (cpus []CPUTimes, err error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 1 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		} else if len(fields) < 9 {
+			return cpus, fmt.Errorf("Too few fields in /proc/stat: %s", line)
+		}
+		var values [8]uint64
+		for i := range values {
+			if values[i], err = strconv.ParseUint(fields[i+1], 10, 64); err != nil {
+				return cpus, fmt.Errorf("Invalid value in /proc/stat: %s", line)
+			}
+		}
+		cpus = append(cpus, CPUTimes{fields[0], values[0], values[1], values[2],
+			values[3], values[4], values[5], values[6], values[7]})
+	}
+	if len(cpus) < 2 || cpus[0].Name != "cpu" {
+		return cpus, fmt.Errorf("Couldn't find CPUs in /proc/stat")
+	}
+	return cpus, nil
+}
+
+// ReadStat reads the CPU times in /proc/stat
+func ReadStat() ([]CPUTimes, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcPath("stat"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseStat(data)
+}
+
+// Usage is how a CPU spent its time over a sample, in percent
+type Usage struct {
+	Name string
+	// Used is time that wasn't idle, including waiting for IO, and Busy is
+	// time that wasn't idle or waiting for IO
+	Used, Busy, IOWait, Steal float64
+}
+
+// UsageBetween works out the usage of a CPU between two readings of its times
+func UsageBetween(before, after CPUTimes) Usage {
+	usage := Usage{Name: after.Name}
+	// the kernel's iowait counter can go backwards, so a counter that went
+	// down counts as no time
+	delta := func(before, after uint64) float64 {
+		if after < before {
+			return 0
+		}
+		return float64(after - before)
+	}
+	idle := delta(before.Idle, after.Idle)
+	iowait := delta(before.IOWait, after.IOWait)
+	steal := delta(before.Steal, after.Steal)
+	total := idle + iowait + steal + delta(before.User, after.User) +
+		delta(before.Nice, after.Nice) + delta(before.System, after.System) +
+		delta(before.IRQ, after.IRQ) + delta(before.SoftIRQ, after.SoftIRQ)
+	if total == 0 {
+		return usage
+	}
+	usage.Used = 100 * (total - idle) / total
+	usage.IOWait = 100 * iowait / total
+	usage.Steal = 100 * steal / total
+	usage.Busy = 100 * (total - idle - iowait) / total
+	return usage
+}
+
+// Sample measures the usage of all of the CPUs together, followed by each CPU,
+// over the window
+func Sample(window time.Duration) (usages []Usage, err error) {
+	before, err := ReadStat()
+	if err != nil {
+		return usages, err
+	}
+	time.Sleep(window)
+	after, err := ReadStat()
+	if err != nil {
+		return usages, err
+	}
+	previous := make(map[string]CPUTimes)
+	for _, times := range before {
+		previous[times.Name] = times
+	}
+	for _, times := range after {
+		// CPUs brought online during the window have no earlier reading
+		if last, ok := previous[times.Name]; ok {
+			usages = append(usages, UsageBetween(last, times))
+		}
+	}
+	return usages, nil
+}
+
+// LoadAvg is the load average over 1, 5 and 15 minutes, from /proc/loadavg
+type LoadAvg struct{ Load1, Load5, Load15 float64 }
+
+// ParseLoadAvg parses the contents of /proc/loadavg
+func ParseLoadAvg(data []byte) (load LoadAvg, err error) {
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return load, fmt.Errorf("Too few fields in /proc/loadavg: %s", data)
+	}
+	var values [3]float64
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return load, fmt.Errorf("Invalid load average: %s", fields[i])
+		}
+	}
+	return LoadAvg{values[0], values[1], values[2]}, nil
+}
+
+// ReadLoadAvg reads the load average
+func ReadLoadAvg() (LoadAvg, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcPath("loadavg"))
+	if err != nil {
+		return LoadAvg{}, err
+	}
+	return ParseLoadAvg(data)
+}
+
+// PressureAvgs are the percentages of time that tasks were stalled, averaged
+// over 10, 60 and 300 seconds
+type PressureAvgs struct{ Avg10, Avg60, Avg300 float64 }
+
+// Pressure is the pressure stall information of a resource, from
+// /proc/pressure. Some is when at least one task was stalled waiting for the
+// resource, and Full is when all of them were.
+type Pressure struct {
+	Some, Full PressureAvgs
+	// HasFull is whether the kernel reported Full, which it didn't for cpu
+	// before Linux 5.13
+	HasFull bool
+}
+
+// PressureResources are the resources that have pressure stall information
+var PressureResources = []string{"cpu", "memory", "io"}
+
+// ParsePressure parses the contents of a file in /proc/pressure
+func ParsePressure(data []byte) (pressure Pressure, err error) {
+	found := false
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 1 {
+			continue
+		}
+		var avgs *PressureAvgs
+		switch fields[0] {
+		case "some":
+			avgs = &pressure.Some
+			found = true
+		case "full":
+			avgs = &pressure.Full
+			pressure.HasFull = true
+		default:
+			return pressure, fmt.Errorf("Invalid line of pressure: %s", line)
+		}
+		for _, field := range fields[1:] {
+			i := strings.Index(field, "=")
+			if i < 0 {
+				return pressure, fmt.Errorf("Invalid field of pressure: %s", field)
+			}
+			var value *float64
+			switch field[:i] {
+			case "avg10":
+				value = &avgs.Avg10
+			case "avg60":
+				value = &avgs.Avg60
+			case "avg300":
+				value = &avgs.Avg300
+			default:
+				continue
+			}
+			if *value, err = strconv.ParseFloat(field[i+1:], 64); err != nil {
+				return pressure, fmt.Errorf("Invalid field of pressure: %s", field)
+			}
+		}
+	}
+	if !found {
+		return pressure, fmt.Errorf("Couldn't find some line of pressure")
+	}
+	return pressure, nil
+}
+
+// ReadPressure reads the pressure stall information of a resource: cpu |
+// memory | io. It's only available on Linux 4.20 and later, built with
+// CONFIG_PSI.
+func ReadPressure(resource string) (Pressure, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcPath("pressure", resource))
+	if err != nil {
+		return Pressure{}, err
+	}
+	return ParsePressure(data)
+}
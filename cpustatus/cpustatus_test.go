@@ -0,0 +1,114 @@
+package cpustatus
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var stat = []byte(`cpu  1000 20 300 8000 400 10 30 240 0 0
+cpu0 600 10 200 3800 300 10 20 60 0 0
+cpu1 400 10 100 4200 100 0 10 180 0 0
+intr 631306 0 0 0
+btime 1792356221
+`)
+
+func TestParseStat(t *testing.T) {
+	t.Parallel()
+	cpus, err := ParseStat(stat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []CPUTimes{
+		{"cpu", 1000, 20, 300, 8000, 400, 10, 30, 240},
+		{"cpu0", 600, 10, 200, 3800, 300, 10, 20, 60},
+		{"cpu1", 400, 10, 100, 4200, 100, 0, 10, 180},
+	}
+	if !reflect.DeepEqual(cpus, expected) {
+		t.Errorf("Unexpected CPU times: %+v", cpus)
+	}
+	if cpus[0].Total() != 10000 {
+		t.Errorf("Unexpected total: %d", cpus[0].Total())
+	}
+	invalid := []string{"", "cpu 1 2 3\n", "cpu 1 2 3 4 5 6 7 x\ncpu0 1 2 3 4 5 6 7 8\n",
+		"intr 1\n", "cpu 1 2 3 4 5 6 7 8\n"}
+	for _, data := range invalid {
+		if _, err := ParseStat([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestUsageBetween(t *testing.T) {
+	t.Parallel()
+	before := CPUTimes{"cpu0", 100, 0, 100, 600, 100, 0, 0, 100}
+	after := CPUTimes{"cpu0", 400, 0, 200, 800, 300, 0, 0, 300}
+	// 1000 ticks, of which 200 idle, 200 iowait and 200 steal
+	expected := Usage{"cpu0", 80, 60, 20, 20}
+	if usage := UsageBetween(before, after); usage != expected {
+		t.Errorf("Unexpected usage: %+v", usage)
+	}
+	// iowait went backwards, leaving 800 ticks of which 200 idle
+	after.IOWait = 50
+	expected = Usage{"cpu0", 75, 75, 0, 25}
+	if usage := UsageBetween(before, after); usage != expected {
+		t.Errorf("Unexpected usage when iowait went backwards: %+v", usage)
+	}
+	if usage := UsageBetween(before, before); usage != (Usage{Name: "cpu0"}) {
+		t.Errorf("Unexpected usage without any ticks: %+v", usage)
+	}
+}
+
+func TestSample(t *testing.T) {
+	t.Parallel()
+	usages, err := Sample(100 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usages) < 2 || usages[0].Name != "cpu" {
+		t.Errorf("Unexpected usages: %+v", usages)
+	}
+	for _, usage := range usages {
+		if usage.Busy < 0 || usage.Busy > 100 {
+			t.Errorf("Unexpected usage: %+v", usage)
+		}
+	}
+}
+
+func TestParseLoadAvg(t *testing.T) {
+	t.Parallel()
+	load, err := ParseLoadAvg([]byte("0.63 0.36 0.24 2/72 22913\n"))
+	if err != nil || load != (LoadAvg{0.63, 0.36, 0.24}) {
+		t.Errorf("Unexpected load average: %+v, %v", load, err)
+	}
+	for _, data := range []string{"", "0.63 0.36", "0.63 high 0.24 2/72 22913"} {
+		if _, err := ParseLoadAvg([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+	if _, err := ReadLoadAvg(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParsePressure(t *testing.T) {
+	t.Parallel()
+	data := []byte("some avg10=1.50 avg60=0.75 avg300=0.20 total=123456\n" +
+		"full avg10=0.50 avg60=0.25 avg300=0.00 total=23456\n")
+	expected := Pressure{PressureAvgs{1.5, 0.75, 0.2}, PressureAvgs{0.5, 0.25, 0}, true}
+	if pressure, err := ParsePressure(data); err != nil || pressure != expected {
+		t.Errorf("Unexpected pressure: %+v, %v", pressure, err)
+	}
+	// cpu pressure had no full line before Linux 5.13
+	data = []byte("some avg10=1.50 avg60=0.75 avg300=0.20 total=123456\n")
+	if pressure, err := ParsePressure(data); err != nil || pressure.HasFull || pressure.Full != (PressureAvgs{}) {
+		t.Errorf("Unexpected pressure: %+v, %v", pressure, err)
+	}
+	invalid := []string{"", "full avg10=0.50\n", "some avg10\n", "some avg10=high\n",
+		"partial avg10=0.50\n"}
+	for _, data := range invalid {
+		if _, err := ParsePressure([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
@@ -65,6 +65,13 @@ func GenericError(msg string, specified interface{}, actual interface{}) (int, s
 	return 1, msg, nil
 }
 
+// Warning is like GenericError, but for checks that are degraded rather than
+// failing, e.g. a service that's slow to respond. It returns exit code 2.
+func Warning(msg string, specified interface{}, actual interface{}) (int, string, error) {
+	_, msg, err := GenericError(msg, specified, actual)
+	return 2, msg, err
+}
+
 // ExecError logs.Fatal with a useful message for errors that occur when
 // using os/exec to run commands
 func ExecError(cmd *exec.Cmd, out string, err error) {
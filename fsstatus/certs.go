@@ -0,0 +1,136 @@
+package fsstatus
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// pemBlocks returns the PEM blocks of the given type in data, or nil if data
+// isn't PEM-encoded
+func pemBlocks(data []byte, blockTypes ...string) (blocks [][]byte) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return blocks
+		}
+		for _, blockType := range blockTypes {
+			if block.Type == blockType {
+				blocks = append(blocks, block.Bytes)
+			}
+		}
+	}
+}
+
+// isPEM reports whether data looks PEM-encoded rather than DER
+func isPEM(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "))
+}
+
+// ParseCertificates parses the certificates in data, which is either a PEM
+// bundle of one or more certificates or a single DER-encoded certificate
+func ParseCertificates(data []byte) (certs []*x509.Certificate, err error) {
+	if !isPEM(data) {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return certs, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	for _, der := range pemBlocks(data, "CERTIFICATE") {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return certs, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) < 1 {
+		return certs, errors.New("No certificates found in PEM data")
+	}
+	return certs, nil
+}
+
+// ReadCertificates reads the certificates in a PEM or DER file
+func ReadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		return certs, fmt.Errorf("Couldn't parse certificates in %s: %v", path, err)
+	}
+	return certs, nil
+}
+
+// ReadCertPool reads a CA bundle into a pool, for verifying certificates
+func ReadCertPool(path string) (*x509.CertPool, error) {
+	certs, err := ReadCertificates(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool, nil
+}
+
+// ParsePrivateKey parses a PEM or DER private key in PKCS #1 (RSA), SEC 1
+// (EC) or PKCS #8 form
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	der := data
+	if isPEM(data) {
+		blocks := pemBlocks(data, "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY")
+		if len(blocks) < 1 {
+			return nil, errors.New("No private key found in PEM data")
+		}
+		der = blocks[0]
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.New("Unknown private key format")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("Unsupported private key type")
+	}
+	return signer, nil
+}
+
+// ReadPrivateKey reads a PEM or DER private key from a file
+func ReadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return key, fmt.Errorf("Couldn't parse private key in %s: %v", path, err)
+	}
+	return key, nil
+}
+
+// KeyMatches reports whether key is the private key for cert
+func KeyMatches(cert *x509.Certificate, key crypto.Signer) (bool, error) {
+	certPub, err := x509.MarshalPKIXPublicKey(cert.PublicKey)
+	if err != nil {
+		return false, err
+	}
+	keyPub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(certPub, keyPub), nil
+}
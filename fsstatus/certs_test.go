@@ -0,0 +1,81 @@
+package fsstatus
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestParseCertificatesAndKeys(t *testing.T) {
+	t.Parallel()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, rsaKey.Public(), rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	bundle := append(append([]byte{}, certPEM...), certPEM...)
+	for name, data := range map[string][]byte{"DER": der, "PEM": certPEM, "bundle": bundle} {
+		certs, err := ParseCertificates(data)
+		if err != nil {
+			t.Errorf("ParseCertificates failed on %s: %v", name, err)
+		} else if certs[0].Subject.CommonName != "test" {
+			t.Errorf("ParseCertificates parsed %s incorrectly: %v", name, certs[0].Subject)
+		}
+	}
+	if _, err := ParseCertificates([]byte("-----BEGIN NOTHING-----\n")); err == nil {
+		t.Error("ParseCertificates didn't fail on PEM without certificates")
+	}
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1 := x509.MarshalPKCS1PrivateKey(rsaKey)
+	cert, _ := x509.ParseCertificate(der)
+	for name, test := range map[string]struct {
+		data    []byte
+		matches bool
+	}{
+		"PKCS #1 DER": {pkcs1, true},
+		"PKCS #1 PEM": {pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pkcs1}), true},
+		"SEC 1 PEM":   {pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}), false},
+		"PKCS #8 DER": {pkcs8, false},
+	} {
+		key, err := ParsePrivateKey(test.data)
+		if err != nil {
+			t.Errorf("ParsePrivateKey failed on %s: %v", name, err)
+			continue
+		}
+		if matches, err := KeyMatches(cert, key); err != nil || matches != test.matches {
+			t.Errorf("KeyMatches(%s): expected %v, got %v, %v", name, test.matches, matches, err)
+		}
+	}
+	if _, err := ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Error("ParsePrivateKey didn't fail on garbage")
+	}
+}
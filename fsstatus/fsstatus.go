@@ -8,14 +8,19 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"github.com/CiscoCloud/distributive/chkutil"
 	"github.com/CiscoCloud/distributive/tabular"
 	"golang.org/x/crypto/sha3"
 	"hash"
+	"io/ioutil"
+	"math"
 	"os"
-	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 // IsFile checks to see if there's a regular ol' file at path.
@@ -99,66 +104,210 @@ func FileHasPermissions(expectedPerms string, path string) (bool, error) {
 	return (actualMode == expectedPerms), nil
 }
 
-// how many inodes are in this given state? state can be one of:
-// total, used, free, percent
-func inodesInState(filesystem, state string) (total uint64, err error) {
-	cmd := exec.Command("df", "-i")
-	out, err := cmd.CombinedOutput()
+// Mount is a mounted filesystem, as listed in /proc/self/mountinfo
+type Mount struct {
+	// the major:minor device number, e.g. 8:1
+	DeviceNumber string
+	// the device or other source, e.g. /dev/sda1 or tmpfs
+	Device string
+	// the directory within the filesystem that's mounted, / unless it's a
+	// bind mount
+	Root       string
+	Mountpoint string
+	FSType     string
+	Options    []string
+}
+
+// unescapeMountField replaces the octal escapes that the kernel uses for
+// whitespace and backslashes in /proc/self/mountinfo, e.g. \040 for a space
+func unescapeMountField(field string) string {
+	re := regexp.MustCompile(`\\[0-7]{3}`)
+	return re.ReplaceAllStringFunc(field, func(escape string) string {
+		char, _ := strconv.ParseUint(escape[1:], 8, 8)
+		return string([]byte{byte(char)})
+	})
+}
+
+// parseMountInfo parses the contents of /proc/self/mountinfo, whose lines are:
+// id parent major:minor root mountpoint options [optional...] - type source superoptions
+func parseMountInfo(data []byte) (mounts []Mount, err error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 6 || sep < 0 || len(fields) < sep+3 {
+			return mounts, fmt.Errorf("Couldn't parse mountinfo line: %v", line)
+		}
+		options := strings.Split(fields[5], ",")
+		if len(fields) > sep+3 {
+			for _, opt := range strings.Split(fields[sep+3], ",") {
+				if !tabular.StrIn(opt, options) {
+					options = append(options, opt)
+				}
+			}
+		}
+		mounts = append(mounts, Mount{
+			DeviceNumber: fields[2],
+			Device:       unescapeMountField(fields[sep+2]),
+			Root:         unescapeMountField(fields[3]),
+			Mountpoint:   unescapeMountField(fields[4]),
+			FSType:       fields[sep+1],
+			Options:      options,
+		})
+	}
+	return mounts, nil
+}
+
+// Mounts lists all of the currently mounted filesystems
+func Mounts() (mounts []Mount, err error) {
+	data, err := ioutil.ReadFile(chkutil.ProcSelfPath("mountinfo"))
 	if err != nil {
-		return total, err
-	}
-	table := tabular.ProbabalisticSplit(string(out))
-	filesystems := tabular.GetColumnByHeader("Filesystem", table)
-	var totals []string
-	switch state {
-	case "total":
-		totals = tabular.GetColumnByHeader("Inodes", table)
-	case "free":
-		totals = tabular.GetColumnByHeader("IFree", table)
-	case "used":
-		totals = tabular.GetColumnByHeader("IUsed", table)
-	case "percent":
-		totals = tabular.GetColumnByHeader("IUse%", table)
-	default:
-		formatStr := "Internal error: unexpected state in inodesInState: %v"
-		return total, fmt.Errorf(formatStr, state)
-	}
-	if len(filesystems) != len(totals) {
-		formatStr := "The number of filesystems (%d) didn't match the number of"
-		formatStr += " inode totals (%d)!"
-		return total, fmt.Errorf(formatStr, len(filesystems), len(totals))
-	}
-	for i := range totals {
-		if filesystems[i] == filesystem {
-			// trim the % in case the state was "percent"
-			return strconv.ParseUint(strings.TrimSuffix(totals[i], "%"), 10, 64)
+		return mounts, err
+	}
+	return parseMountInfo(data)
+}
+
+// deviceNumber returns the major:minor number of the block device at path
+func deviceNumber(path string) (string, error) {
+	finfo, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	stat, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok || finfo.Mode()&os.ModeDevice == 0 {
+		return "", fmt.Errorf("Not a device: %v", path)
+	}
+	// see major() and minor() in glibc's sys/sysmacros.h
+	rdev := uint64(stat.Rdev)
+	major := (rdev>>8)&0xfff | (rdev>>32)&^0xfff
+	minor := rdev&0xff | (rdev>>12)&^0xff
+	return fmt.Sprintf("%d:%d", major, minor), nil
+}
+
+// Mountpoint finds where a filesystem is mounted. The filesystem can be given
+// as any file or directory on it, as a block device (e.g. /dev/sda1 or a
+// symlink to it), or as the source shown in mountinfo (e.g. tmpfs). For a file
+// or directory, that path itself is returned, since statfs works on any path.
+func Mountpoint(filesystem string) (string, error) {
+	path := chkutil.HostPath(filesystem)
+	info, err := os.Stat(path)
+	if err == nil && info.Mode()&os.ModeDevice == 0 && strings.HasPrefix(filesystem, "/") {
+		return filesystem, nil
+	}
+	mounts, err := Mounts()
+	if err != nil {
+		return "", err
+	}
+	devNum, _ := deviceNumber(path)
+	var found []Mount
+	for _, mount := range mounts {
+		if mount.Device == filesystem || mount.DeviceNumber == devNum {
+			found = append(found, mount)
 		}
 	}
-	return total, fmt.Errorf("Couldn't find that filesystem: " + filesystem)
+	// prefer mounts of the whole filesystem to bind mounts of part of it
+	for _, mount := range found {
+		if mount.Root == "/" {
+			return mount.Mountpoint, nil
+		}
+	}
+	if len(found) > 0 {
+		return found[0].Mountpoint, nil
+	}
+	return "", errors.New("Couldn't find that filesystem: " + filesystem)
+}
+
+// Usage is the space and inode usage of a filesystem
+type Usage struct {
+	TotalBytes uint64
+	FreeBytes  uint64
+	// AvailableBytes is the free space that unprivileged users can use
+	AvailableBytes uint64
+	TotalInodes    uint64
+	FreeInodes     uint64
+}
+
+// UsedBytes is the space that isn't free
+func (usage Usage) UsedBytes() uint64 { return usage.TotalBytes - usage.FreeBytes }
+
+// UsedInodes is the number of inodes that aren't free
+func (usage Usage) UsedInodes() uint64 { return usage.TotalInodes - usage.FreeInodes }
+
+// percentUsed is the percent of space that's used, rounded up like df does,
+// out of the space available to unprivileged users
+func percentUsed(used, available uint64) uint8 {
+	if used+available == 0 {
+		return 0
+	}
+	return uint8(math.Ceil(float64(used) / float64(used+available) * 100))
+}
+
+// PercentBytesUsed is the percent of space that's used, as shown by df
+func (usage Usage) PercentBytesUsed() uint8 {
+	return percentUsed(usage.UsedBytes(), usage.AvailableBytes)
+}
+
+// PercentInodesUsed is the percent of inodes that are used, as shown by df -i
+func (usage Usage) PercentInodesUsed() uint8 {
+	return percentUsed(usage.UsedInodes(), usage.FreeInodes)
+}
+
+// Statfs reports the usage of the filesystem that path is on
+func Statfs(path string) (usage Usage, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return usage, err
+	}
+	// blocks * size of block = size
+	usage.TotalBytes = stat.Blocks * uint64(stat.Bsize)
+	usage.FreeBytes = stat.Bfree * uint64(stat.Bsize)
+	usage.AvailableBytes = stat.Bavail * uint64(stat.Bsize)
+	usage.TotalInodes = stat.Files
+	usage.FreeInodes = stat.Ffree
+	return usage, nil
+}
+
+// UsageOf reports the usage of a filesystem, given in any of the ways that
+// Mountpoint accepts
+func UsageOf(filesystem string) (usage Usage, err error) {
+	mountpoint, err := Mountpoint(filesystem)
+	if err != nil {
+		return usage, err
+	}
+	return Statfs(chkutil.HostPath(mountpoint))
 }
 
 // FreeInodes reports the number of free inodes in a given filesystem, e.g.
-// /dev/sda1, as given by `df -i`
+// /dev/sda1, given in any of the ways that Mountpoint accepts
 func FreeInodes(filesystem string) (free uint64, err error) {
-	return inodesInState(filesystem, "free")
+	usage, err := UsageOf(filesystem)
+	return usage.FreeInodes, err
 }
 
 // UsedInodes is like FreeInodes
 func UsedInodes(filesystem string) (used uint64, err error) {
-	return inodesInState(filesystem, "used")
+	usage, err := UsageOf(filesystem)
+	return usage.UsedInodes(), err
 }
 
 // TotalInodes is like FreeInodes
 func TotalInodes(filesystem string) (total uint64, err error) {
-	return inodesInState(filesystem, "total")
+	usage, err := UsageOf(filesystem)
+	return usage.TotalInodes, err
 }
 
-// PercentInodesUsed reports the percentage given by `df -i`, which is ceilinged
-// to the next integer value, so a percent like .001% would round to 1%.
+// PercentInodesUsed reports the percentage that `df -i` would, which is
+// ceilinged to the next integer value, so a percent like .001% would round
+// to 1%.
 func PercentInodesUsed(filesystem string) (percent uint8, err error) {
-	percent64, err := inodesInState(filesystem, "percent")
-	if err != nil {
-		return percent, err
-	}
-	return uint8(percent64), err
+	usage, err := UsageOf(filesystem)
+	return usage.PercentInodesUsed(), err
 }
@@ -2,7 +2,7 @@ package fsstatus
 
 import (
 	"math"
-	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
@@ -91,8 +91,6 @@ func testInodeCountingFunction(t *testing.T, f inodeFun, fName string) {
 
 func TestInodeCountingFunctions(t *testing.T) {
 	t.Parallel()
-	cmd := exec.Command("df", "-i")
-	out, _ := cmd.CombinedOutput()
 	testInodeCountingFunction(t, FreeInodes, "FreeInodes")
 	testInodeCountingFunction(t, UsedInodes, "UsedInodes")
 	testInodeCountingFunction(t, TotalInodes, "TotalInodes")
@@ -107,7 +105,6 @@ func TestInodeCountingFunctions(t *testing.T) {
 		}
 	}
 	if free+used != total {
-		t.Logf("Output of `df -i`: %v", string(out))
 		msg := "(free inodes) + (used inodes) != (total inodes), %v + %v != %v"
 		t.Errorf(msg, free, used, total)
 	}
@@ -132,3 +129,52 @@ func TestInodePercentFunction(t *testing.T) {
 		t.Errorf(msg, calculatedPercent, givenPercent)
 	}
 }
+
+var mountinfo = []byte(`22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro
+23 22 0:22 / /proc rw,nosuid - proc proc rw
+40 22 8:1 /srv/data /mnt/my\040disk rw - ext4 /dev/sda1 rw
+41 22 0:35 / /run rw,nosuid,nodev - tmpfs tmpfs rw,size=1024k
+`)
+
+func TestParseMountInfo(t *testing.T) {
+	t.Parallel()
+	mounts, err := parseMountInfo(mountinfo)
+	if err != nil {
+		t.Fatalf("parseMountInfo failed unexpectedly: %v", err)
+	} else if len(mounts) != 4 {
+		t.Fatalf("parseMountInfo: expected 4 mounts, got %v", len(mounts))
+	}
+	bind := mounts[2]
+	if bind.Mountpoint != "/mnt/my disk" || bind.Root != "/srv/data" ||
+		bind.Device != "/dev/sda1" || bind.DeviceNumber != "8:1" {
+		t.Errorf("parseMountInfo parsed a bind mount incorrectly: %+v", bind)
+	}
+	tmpfs := mounts[3]
+	if tmpfs.FSType != "tmpfs" || len(tmpfs.Options) != 4 {
+		t.Errorf("parseMountInfo parsed tmpfs incorrectly: %+v", tmpfs)
+	}
+	if _, err := parseMountInfo([]byte("22 1 8:1 / / rw\n")); err == nil {
+		t.Error("parseMountInfo accepted a line without a separator")
+	}
+}
+
+func TestUsageOf(t *testing.T) {
+	t.Parallel()
+	file, err := filepath.Abs("fsstatus_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filesystem := range []string{"/", "/proc", file} {
+		usage, err := UsageOf(filesystem)
+		if err != nil {
+			t.Errorf("UsageOf(%v) failed unexpectedly: %v", filesystem, err)
+		} else if usage.FreeBytes > usage.TotalBytes ||
+			usage.AvailableBytes > usage.FreeBytes ||
+			usage.PercentBytesUsed() > 100 {
+			t.Errorf("UsageOf(%v) was inconsistent: %+v", filesystem, usage)
+		}
+	}
+	if _, err := UsageOf("testfail"); err == nil {
+		t.Error("UsageOf didn't fail on a nonexistent filesystem")
+	}
+}
@@ -9,12 +9,14 @@ import (
 	"os"
 
 	"github.com/CiscoCloud/distributive/checklists"
+	"github.com/CiscoCloud/distributive/chkutil"
 	log "github.com/Sirupsen/logrus"
 	"github.com/mitchellh/panicwrap"
     _ "github.com/CiscoCloud/distributive/checks"
 )
 
-var useCache bool // should remote checks be run from the cache when possible?
+var useCache bool    // should remote checks be run from the cache when possible?
+var pluginDir string // where to look for external check plugins
 
 const Version = "v0.2.5"
 const Name = "distributive"
@@ -72,6 +74,26 @@ func getChecklists(file string, dir string, url string, stdin bool) (lsts []chec
 	return lsts
 }
 
+// loadPlugins registers the external check plugins in dir. A missing default
+// plugin directory is not an error.
+func loadPlugins(dir string) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) && dir == defaultPluginDir {
+		log.Debug("No plugin directory at " + dir)
+		return
+	}
+	ids, err := chkutil.LoadPlugins(dir)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"dir":   dir,
+			"error": err.Error(),
+		}).Fatal("Couldn't load plugins")
+	}
+	log.WithFields(log.Fields{
+		"dir":     dir,
+		"plugins": ids,
+	}).Debug("Loaded plugins")
+}
+
 // main reads the command line flag -f, runs the Check specified in the YAML,
 // and exits with the appropriate message and exit code.
 func main() {
@@ -96,6 +118,10 @@ func main() {
 	file, URL, directory, stdin := getFlags()
 	log.Debug("Validating flags")
 	validateFlags(file, URL, directory)
+	if pluginDir != "" {
+		log.Debug("Loading plugins")
+		loadPlugins(pluginDir)
+	}
 	// add workers to workers, parameterLength
 	log.Debug("Running checklists")
 	exitCode := 0
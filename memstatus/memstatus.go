@@ -4,131 +4,157 @@ package memstatus
 
 import (
 	"errors"
-	"os/exec"
-	"regexp"
+	"fmt"
+	"io/ioutil"
 	"strconv"
 	"strings"
 
-	"github.com/CiscoCloud/distributive/tabular"
+	"github.com/CiscoCloud/distributive/chkutil"
 )
 
-// swapOrMemory returns output from `free`, it is an abstraction of swap and
-// memory. inputs: status: free | used | total; swapOrMem: memory | swap;
-// units: b | kb | mb | gb | tb
-func swapOrMemory(status string, swapOrMem string, units string) (int, error) {
-	unitsToFlag := map[string]string{
-		"b":  "--bytes",
-		"kb": "--kilo",
-		"mb": "--mega",
-		"gb": "--giga",
-		"tb": "--tera",
+// MemInfo is a snapshot of /proc/meminfo. Sizes are in bytes, except for the
+// HugePages counts, which are in pages of size Hugepagesize.
+type MemInfo struct {
+	MemTotal     uint64
+	MemFree      uint64
+	MemAvailable uint64
+	Buffers      uint64
+	Cached       uint64
+	SwapTotal    uint64
+	SwapFree     uint64
+	Dirty        uint64
+	Slab         uint64
+
+	HugePagesTotal uint64
+	HugePagesFree  uint64
+	Hugepagesize   uint64
+}
+
+// ParseMemInfo parses the contents of /proc/meminfo
+func ParseMemInfo(data []byte) (info MemInfo, err error) {
+	fields := map[string]*uint64{
+		"MemTotal":        &info.MemTotal,
+		"MemFree":         &info.MemFree,
+		"MemAvailable":    &info.MemAvailable,
+		"Buffers":         &info.Buffers,
+		"Cached":          &info.Cached,
+		"SwapTotal":       &info.SwapTotal,
+		"SwapFree":        &info.SwapFree,
+		"Dirty":           &info.Dirty,
+		"Slab":            &info.Slab,
+		"HugePages_Total": &info.HugePagesTotal,
+		"HugePages_Free":  &info.HugePagesFree,
+		"Hugepagesize":    &info.Hugepagesize,
 	}
-	typeToRow := map[string]int{
-		"memory": 0,
-		"swap":   1,
+	found := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		// e.g. "MemTotal:       16316412 kB" or "HugePages_Free:        0"
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		key := line[:colon]
+		ptr, ok := fields[key]
+		if !ok {
+			continue
+		}
+		value := strings.Fields(line[colon+1:])
+		if len(value) < 1 {
+			return info, fmt.Errorf("No value for %s in meminfo", key)
+		}
+		*ptr, err = strconv.ParseUint(value[0], 10, 64)
+		if err != nil {
+			return info, fmt.Errorf("Couldn't parse %s in meminfo: %v", key, err)
+		}
+		if len(value) > 1 && value[1] == "kB" {
+			*ptr *= 1024
+		}
+		found[key] = true
 	}
-	// check to see that our keys are really in our dict
-	if _, ok := unitsToFlag[units]; !ok {
-		return 0, errors.New("Invalid units in swapOrMemory: " + units)
-	} else if _, ok := typeToRow[swapOrMem]; !ok {
-		return 0, errors.New("Invalid option in swapOrMemory: " + swapOrMem)
+	if !found["MemTotal"] || !found["MemFree"] {
+		return info, errors.New("meminfo didn't include MemTotal and MemFree")
 	}
-	// execute free and return the appropriate output
-	cmd := exec.Command("free", unitsToFlag[units])
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return 0, err
+	// MemAvailable was added in Linux 3.14, estimate it on older kernels
+	if !found["MemAvailable"] {
+		info.MemAvailable = info.MemFree + info.Buffers + info.Cached
 	}
-	// TODO probabalisticsplit isn't handling this appropriately
-	//table := tabular.ProbabalisticSplit(outStr)
-	colSep := regexp.MustCompile(`\s+`)
-	rowSep := regexp.MustCompile(`\n+`)
-	table := tabular.SeparateString(rowSep, colSep, string(out))
-	column := tabular.GetColumnByHeader(status, table)
+	return info, nil
+}
 
-	// filter out useless row from some versions of `free`
-	for i, row := range table {
-		if len(row) > 0 && strings.Contains(row[0], "-/+") {
-			table = append(table[:i], table[i+1:]...)
-		}
+// Snapshot reads and parses /proc/meminfo
+func Snapshot() (info MemInfo, err error) {
+	data, err := ioutil.ReadFile(chkutil.ProcPath("meminfo"))
+	if err != nil {
+		return info, err
 	}
+	return ParseMemInfo(data)
+}
 
-	row := typeToRow[swapOrMem]
-	// check for errors in output of `free`
-	if column == nil || len(column) < 1 {
-		errors.New("Free column was empty")
+// inUnits converts amount bytes out of total into the given units:
+// b | kb | mb | gb | tb | percent
+func inUnits(amount, total uint64, units string) (int, error) {
+	units = strings.ToLower(units)
+	if units == "percent" {
+		if total == 0 {
+			return 0, nil
+		}
+		return int((float64(amount) / float64(total)) * 100), nil
 	}
-	if row >= len(column) {
-		errors.New("`free` didn't output enough rows")
+	multiplier, ok := chkutil.ByteUnits[units]
+	if !ok {
+		return 0, errors.New("Invalid units: " + units)
 	}
-	toReturn, err := strconv.ParseInt(column[row], 10, 64)
+	return int(amount / multiplier), nil
+}
+
+// FreeMemory returns the amount of memory that's currently unoccupied. Memory
+// used by the page cache doesn't count as free, see AvailableMemory.
+// units : b, kb, mb, gb, tb, percent
+func FreeMemory(units string) (int, error) {
+	info, err := Snapshot()
 	if err != nil {
-		errors.New("Couldn't parse output of `free` as an int")
+		return 0, err
 	}
-	return int(toReturn), nil
+	return inUnits(info.MemFree, info.MemTotal, units)
 }
 
-// FreeMemory returns the amount of memory that's currently unoccupied.
+// AvailableMemory returns the amount of memory that's available for starting
+// new applications without swapping, including reclaimable caches.
 // units : b, kb, mb, gb, tb, percent
-func FreeMemory(units string) (int, error) {
-	if strings.ToLower(units) == "percent" {
-		free, err := swapOrMemory("free", "memory", "b")
-		if err != nil {
-			return 0, err
-		}
-		total, err := swapOrMemory("total", "memory", "b")
-		if err != nil {
-			return 0, err
-		}
-		return int((float32(free) / float32(total)) * 100), nil
+func AvailableMemory(units string) (int, error) {
+	info, err := Snapshot()
+	if err != nil {
+		return 0, err
 	}
-	return swapOrMemory("free", "memory", units)
+	return inUnits(info.MemAvailable, info.MemTotal, units)
 }
 
-// FreeMemory returns the amount of memory that's currently unoccupied.
+// UsedMemory returns the amount of memory that isn't available.
 // units : b, kb, mb, gb, tb, percent
 func UsedMemory(units string) (int, error) {
-	if strings.ToLower(units) == "percent" {
-		used, err := swapOrMemory("used", "memory", "b")
-		if err != nil {
-			return 0, err
-		}
-		total, err := swapOrMemory("total", "memory", "b")
-		if err != nil {
-			return 0, err
-		}
-		return int((float32(used) / float32(total)) * 100), nil
+	info, err := Snapshot()
+	if err != nil {
+		return 0, err
 	}
-	return swapOrMemory("used", "memory", units)
+	return inUnits(info.MemTotal-info.MemAvailable, info.MemTotal, units)
 }
 
+// FreeSwap returns the amount of swap that's currently unoccupied.
+// units : b, kb, mb, gb, tb, percent
 func FreeSwap(units string) (int, error) {
-	if strings.ToLower(units) == "percent" {
-		free, err := swapOrMemory("free", "swap", "b")
-		if err != nil {
-			return 0, err
-		}
-		total, err := swapOrMemory("total", "swap", "b")
-		if err != nil {
-			return 0, err
-		}
-		return int((float32(free) / float32(total)) * 100), nil
+	info, err := Snapshot()
+	if err != nil {
+		return 0, err
 	}
-	return swapOrMemory("free", "swap", units)
+	return inUnits(info.SwapFree, info.SwapTotal, units)
 }
 
+// UsedSwap returns the amount of swap that's in use.
+// units : b, kb, mb, gb, tb, percent
 func UsedSwap(units string) (int, error) {
-	if strings.ToLower(units) == "percent" {
-		used, err := swapOrMemory("used", "swap", "b")
-		if err != nil {
-			return 0, err
-		}
-		total, err := swapOrMemory("total", "swap", "b")
-		if err != nil {
-			return 0, err
-		}
-		return int((float32(used) / float32(total)) * 100), nil
+	info, err := Snapshot()
+	if err != nil {
+		return 0, err
 	}
-	return swapOrMemory("used", "swap", units)
-
+	return inUnits(info.SwapTotal-info.SwapFree, info.SwapTotal, units)
 }
@@ -1,93 +1,114 @@
 package memstatus
 
 import (
-	"os/exec"
 	"testing"
 )
 
 var units = []string{"b", "kb", "mb", "gb", "tb"}
 
-func logFreeOutput(t *testing.T) {
-	cmd := exec.Command("free")
-	out, _ := cmd.CombinedOutput()
-	t.Logf("Output of `free`: %v", string(out))
-}
+var meminfo = []byte(`MemTotal:       16316412 kB
+MemFree:         1234560 kB
+MemAvailable:    8000000 kB
+Buffers:          512000 kB
+Cached:          6000000 kB
+SwapCached:            0 kB
+SwapTotal:       2097148 kB
+SwapFree:        1048574 kB
+Dirty:               128 kB
+Slab:             800000 kB
+HugePages_Total:      16
+HugePages_Free:        4
+Hugepagesize:       2048 kB
+`)
 
-func TestSwapOrMemory(t *testing.T) {
+func TestParseMemInfo(t *testing.T) {
 	t.Parallel()
-	for _, status := range []string{"free", "used", "total"} {
-		for _, swapOrMem := range []string{"swap", "memory"} {
-			for _, unit := range units {
-				amt, err := swapOrMemory(status, swapOrMem, unit)
-				if err != nil {
-					logFreeOutput(t)
-					t.Errorf("swapOrMemory failed unexpectedly: %v", err)
-				}
-				if amt < 0 {
-					logFreeOutput(t)
-					t.Logf("swapOrMemory reported negative: %v", amt)
-				}
-			}
+	info, err := ParseMemInfo(meminfo)
+	if err != nil {
+		t.Fatalf("ParseMemInfo failed unexpectedly: %v", err)
+	}
+	expected := MemInfo{
+		MemTotal:       16316412 * 1024,
+		MemFree:        1234560 * 1024,
+		MemAvailable:   8000000 * 1024,
+		Buffers:        512000 * 1024,
+		Cached:         6000000 * 1024,
+		SwapTotal:      2097148 * 1024,
+		SwapFree:       1048574 * 1024,
+		Dirty:          128 * 1024,
+		Slab:           800000 * 1024,
+		HugePagesTotal: 16,
+		HugePagesFree:  4,
+		Hugepagesize:   2048 * 1024,
+	}
+	if info != expected {
+		t.Errorf("ParseMemInfo: expected %+v, got %+v", expected, info)
+	}
+	// older kernels don't have MemAvailable
+	old := []byte("MemTotal: 100 kB\nMemFree: 10 kB\nBuffers: 5 kB\nCached: 20 kB\n")
+	if info, err := ParseMemInfo(old); err != nil {
+		t.Errorf("ParseMemInfo failed without MemAvailable: %v", err)
+	} else if info.MemAvailable != 35*1024 {
+		t.Errorf("MemAvailable wasn't estimated: %v", info.MemAvailable)
+	}
+	for _, bad := range []string{"", "MemTotal: 100 kB\n", "MemTotal: x kB\nMemFree: 1 kB"} {
+		if _, err := ParseMemInfo([]byte(bad)); err == nil {
+			t.Errorf("ParseMemInfo didn't fail on %q", bad)
 		}
 	}
 }
 
-func TestFreeMemory(t *testing.T) {
+func TestInUnits(t *testing.T) {
 	t.Parallel()
-	for _, unit := range append(units, "percent") {
-		amt, err := FreeMemory(unit)
-		if err != nil {
-			logFreeOutput(t)
-			t.Error("FreeMemory failed unexpectedly")
-		}
-		if amt < 0 {
-			logFreeOutput(t)
-			t.Errorf("FreeMemory reported negative: %v", amt)
+	for amount, expected := range map[string]int{
+		"b": 3 << 20, "kb": 3 << 10, "mb": 3, "gb": 0, "percent": 75,
+	} {
+		if actual, _ := inUnits(3<<20, 4<<20, amount); actual != expected {
+			t.Errorf("inUnits(%v): expected %v, got %v", amount, expected, actual)
 		}
 	}
+	if actual, err := inUnits(0, 0, "percent"); err != nil || actual != 0 {
+		t.Errorf("inUnits failed on an empty total: %v, %v", actual, err)
+	}
+	if _, err := inUnits(1, 1, "furlongs"); err == nil {
+		t.Error("inUnits accepted invalid units")
+	}
 }
 
-func TestUsedMemory(t *testing.T) {
-	t.Parallel()
+// testAmounts checks that an amount function works in all units
+func testAmounts(name string, amount func(string) (int, error), t *testing.T) {
 	for _, unit := range append(units, "percent") {
-		amt, err := UsedMemory(unit)
+		amt, err := amount(unit)
 		if err != nil {
-			logFreeOutput(t)
-			t.Error("UsedMemory failed unexpectedly")
+			t.Errorf("%s failed unexpectedly: %v", name, err)
 		}
 		if amt < 0 {
-			logFreeOutput(t)
-			t.Errorf("UsedMemory reported negative: %v", amt)
+			t.Errorf("%s reported negative: %v", name, amt)
 		}
 	}
 }
 
+func TestFreeMemory(t *testing.T) {
+	t.Parallel()
+	testAmounts("FreeMemory", FreeMemory, t)
+}
+
+func TestAvailableMemory(t *testing.T) {
+	t.Parallel()
+	testAmounts("AvailableMemory", AvailableMemory, t)
+}
+
+func TestUsedMemory(t *testing.T) {
+	t.Parallel()
+	testAmounts("UsedMemory", UsedMemory, t)
+}
+
 func TestFreeSwap(t *testing.T) {
 	t.Parallel()
-	for _, unit := range append(units, "percent") {
-		amt, err := FreeSwap(unit)
-		if err != nil {
-			logFreeOutput(t)
-			t.Error("FreeSwap failed unexpectedly")
-		}
-		if amt < 0 {
-			logFreeOutput(t)
-			t.Errorf("FreeSwap reported negative: %v", amt)
-		}
-	}
+	testAmounts("FreeSwap", FreeSwap, t)
 }
 
 func TestUsedSwap(t *testing.T) {
 	t.Parallel()
-	for _, unit := range append(units, "percent") {
-		amt, err := UsedSwap(unit)
-		if err != nil {
-			logFreeOutput(t)
-			t.Error("UsedSwap failed unexpectedly")
-		}
-		if amt < 0 {
-			logFreeOutput(t)
-			t.Errorf("UsedSwap reported negative: %v", amt)
-		}
-	}
+	testAmounts("UsedSwap", UsedSwap, t)
 }
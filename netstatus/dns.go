@@ -0,0 +1,116 @@
+package netstatus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/miekg/dns"
+)
+
+// DNSRecord is one record in the answer to a DNS query
+type DNSRecord struct {
+	Name string
+	Type string
+	TTL  uint32
+	// Value is the record's data, e.g. an IP address for A records. SRV
+	// records are "priority weight port target" and MX records are
+	// "preference host". Names don't have a trailing dot.
+	Value string
+}
+
+// DNSRecordTypes are the types of record that QueryDNS can ask for
+var DNSRecordTypes = []string{"A", "AAAA", "CNAME", "SRV", "TXT", "MX", "PTR"}
+
+// SystemNameserver returns the first nameserver in resolv.conf, as host:port
+func SystemNameserver() (string, error) {
+	config, err := dns.ClientConfigFromFile(chkutil.HostPath("/etc/resolv.conf"))
+	if err != nil {
+		return "", err
+	} else if len(config.Servers) < 1 {
+		return "", errors.New("No nameservers in /etc/resolv.conf")
+	}
+	return net.JoinHostPort(config.Servers[0], config.Port), nil
+}
+
+// recordValue formats the data of a resource record
+func recordValue(rr dns.RR) string {
+	trim := func(name string) string { return strings.TrimSuffix(name, ".") }
+	switch record := rr.(type) {
+	case *dns.A:
+		return record.A.String()
+	case *dns.AAAA:
+		return record.AAAA.String()
+	case *dns.CNAME:
+		return trim(record.Target)
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", record.Priority, record.Weight,
+			record.Port, trim(record.Target))
+	case *dns.TXT:
+		return strings.Join(record.Txt, "")
+	case *dns.MX:
+		return fmt.Sprintf("%d %s", record.Preference, trim(record.Mx))
+	case *dns.PTR:
+		return trim(record.Ptr)
+	}
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// QueryDNS asks server (host:port, or the system's nameserver if it's empty)
+// for the records of the given type for name, and returns those in the answer
+// along with how long the server took to respond. For PTR records, name may
+// be an IP address.
+func QueryDNS(server, name, recordType string, timeout time.Duration) ([]DNSRecord, time.Duration, error) {
+	qtype, ok := dns.StringToType[strings.ToUpper(recordType)]
+	if !ok {
+		return nil, 0, errors.New("Unknown DNS record type: " + recordType)
+	}
+	if server == "" {
+		var err error
+		if server, err = SystemNameserver(); err != nil {
+			return nil, 0, err
+		}
+	} else if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	if qtype == dns.TypePTR && net.ParseIP(name) != nil {
+		reverse, err := dns.ReverseAddr(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		name = reverse
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	// without EDNS0, UDP answers are cut off at 512 bytes, and Consul only
+	// returns a few records
+	msg.SetEdns0(4096, false)
+	client := &dns.Client{Timeout: timeout}
+	resp, rtt, err := client.Exchange(msg, server)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, rtt, err = client.Exchange(msg, server)
+	}
+	if err != nil {
+		return nil, rtt, err
+	} else if resp.Rcode != dns.RcodeSuccess {
+		return nil, rtt, fmt.Errorf("%s query for %s failed: %s", recordType,
+			name, dns.RcodeToString[resp.Rcode])
+	}
+	var records []DNSRecord
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype != qtype {
+			continue
+		}
+		records = append(records, DNSRecord{
+			Name:  strings.TrimSuffix(rr.Header().Name, "."),
+			Type:  dns.TypeToString[qtype],
+			TTL:   rr.Header().Ttl,
+			Value: recordValue(rr),
+		})
+	}
+	return records, rtt, nil
+}
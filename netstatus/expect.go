@@ -0,0 +1,43 @@
+package netstatus
+
+import (
+	"net"
+	"regexp"
+	"time"
+)
+
+// maxResponse is the most that Exchange will read from a server
+const maxResponse = 64 * 1024
+
+// Exchange connects to address (host:port) over TCP, sends payload if it
+// isn't empty, and reads the response until it matches re, the server closes
+// the connection, or timeout passes. The timeout covers the whole exchange,
+// including connecting. It returns what was read, and whether it matched; a
+// response that doesn't arrive in time isn't an error.
+func Exchange(address string, payload []byte, re *regexp.Regexp, timeout time.Duration) (response string, matched bool, err error) {
+	deadline := time.Now().Add(timeout)
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return "", false, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+	if len(payload) > 0 {
+		if _, err := conn.Write(payload); err != nil {
+			return "", false, err
+		}
+	}
+	var received []byte
+	buf := make([]byte, 4096)
+	for len(received) < maxResponse {
+		n, err := conn.Read(buf)
+		received = append(received, buf[:n]...)
+		if re.Match(received) {
+			return string(received), true, nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	return string(received), false, nil
+}
@@ -0,0 +1,572 @@
+package netstatus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+/// Firewall rules
+//
+// These parse the output of iptables-save, ip6tables-save and
+// `nft -j list ruleset` into one model, so that checks work the same whichever
+// is in use. Only the parts of rules that checks match on are parsed; the rest
+// is kept in the rule's text.
+
+// PortRange is a range of ports that a rule matches, inclusive
+type PortRange struct{ Min, Max uint16 }
+
+// FirewallChain is a chain of rules, with its default policy
+type FirewallChain struct {
+	// ip | ip6 for iptables, or the nftables table's family, e.g. inet
+	Family string
+	Table  string
+	Name   string
+	// Hook is where packets enter the chain, e.g. input or forward. It's
+	// empty for chains that are only jumped to.
+	Hook string
+	// Policy is e.g. ACCEPT or DROP, and empty for chains without a hook
+	Policy string
+}
+
+// FirewallRule is a rule in a chain
+type FirewallRule struct {
+	Family string
+	Table  string
+	Chain  string
+	Hook   string
+	// Protocol is e.g. tcp or udp, or empty for any protocol
+	Protocol string
+	// Sources and Destinations are the CIDRs the rule matches, or empty for
+	// any address. An nftables rule can match a set of them. Each is prefixed
+	// with ! if the rule matches everything but them, and a named nftables
+	// set, whose contents aren't known, is e.g. @trusted.
+	Sources      []string
+	Destinations []string
+	InInterface  string
+	// Ports are the destination ports, or empty for any port
+	Ports []PortRange
+	// Target is e.g. ACCEPT, DROP, REJECT, or the chain jumped to
+	Target string
+	// Text is the rule as written by iptables-save, or a summary of an
+	// nftables rule in the same style
+	Text string
+}
+
+// Firewall is a host's firewall configuration
+type Firewall struct {
+	Chains []FirewallChain
+	Rules  []FirewallRule
+}
+
+// HasPort reports whether the rule matches packets to this port
+func (rule FirewallRule) HasPort(port uint16) bool {
+	for _, ports := range rule.Ports {
+		if port >= ports.Min && port <= ports.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinHooks are iptables' built in chains, by the hook they're attached to
+var builtinHooks = map[string]string{
+	"INPUT":       "input",
+	"OUTPUT":      "output",
+	"FORWARD":     "forward",
+	"PREROUTING":  "prerouting",
+	"POSTROUTING": "postrouting",
+}
+
+// NormalizeCIDR returns an address or CIDR as a CIDR of the network it
+// describes, e.g. 10.1.2.3 as 10.1.2.3/32, or 10.1.2.3/8 as 10.0.0.0/8
+func NormalizeCIDR(address string) (string, error) {
+	if !strings.Contains(address, "/") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return "", fmt.Errorf("Invalid address: %s", address)
+		}
+		bits := net.IPv6len * 8
+		if ip.To4() != nil {
+			bits = net.IPv4len * 8
+		}
+		address += "/" + strconv.Itoa(bits)
+	}
+	_, network, err := net.ParseCIDR(address)
+	if err != nil {
+		return "", fmt.Errorf("Invalid CIDR: %s", address)
+	}
+	return network.String(), nil
+}
+
+// splitRuleWords splits a line of iptables-save output into words, keeping
+// quoted strings (e.g. comments) together
+func splitRuleWords(line string) (words []string, err error) {
+	var word []rune
+	inWord, quoted, escaped := false, false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word = append(word, r)
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case (r == ' ' || r == '\t') && !quoted:
+			if inWord {
+				words = append(words, string(word))
+				word, inWord = word[:0], false
+			}
+		default:
+			word = append(word, r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return words, fmt.Errorf("Unterminated quote in rule: %s", line)
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+	return words, nil
+}
+
+// parsePortRanges parses iptables' port lists, like 22 or 80,443,8000:8100
+func parsePortRanges(str string) (ranges []PortRange, err error) {
+	for _, item := range strings.Split(str, ",") {
+		bounds := strings.SplitN(item, ":", 2)
+		min, err := strconv.ParseUint(bounds[0], 10, 16)
+		if err != nil {
+			return ranges, fmt.Errorf("Invalid port: %s", item)
+		}
+		max := min
+		if len(bounds) == 2 {
+			if max, err = strconv.ParseUint(bounds[1], 10, 16); err != nil {
+				return ranges, fmt.Errorf("Invalid port: %s", item)
+			}
+		}
+		ranges = append(ranges, PortRange{uint16(min), uint16(max)})
+	}
+	return ranges, nil
+}
+
+// parseIPTablesRule parses the words of an -A line from iptables-save
+func parseIPTablesRule(rule *FirewallRule, words []string) (err error) {
+	negate := ""
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "!" {
+			negate = "!"
+			continue
+		}
+		var value string
+		if strings.HasPrefix(word, "-") && i+1 < len(words) {
+			value = words[i+1]
+		}
+		switch word {
+		case "-p", "--protocol":
+			rule.Protocol = negate + value
+		case "-s", "--source", "-d", "--destination":
+			cidr, err := NormalizeCIDR(value)
+			if err != nil {
+				return err
+			}
+			if word == "-s" || word == "--source" {
+				rule.Sources = []string{negate + cidr}
+			} else {
+				rule.Destinations = []string{negate + cidr}
+			}
+		case "-i", "--in-interface":
+			rule.InInterface = negate + value
+		case "--dport", "--destination-port", "--dports", "--destination-ports":
+			if negate != "" {
+				// matching everything but some ports isn't representable
+				break
+			}
+			if rule.Ports, err = parsePortRanges(value); err != nil {
+				return err
+			}
+		case "-j", "--jump", "-g", "--goto":
+			rule.Target = value
+		default:
+			negate = ""
+			continue
+		}
+		negate = ""
+		i++
+	}
+	return nil
+}
+
+// ParseIPTablesSave parses the output of iptables-save (family "ip") or
+// ip6tables-save (family "ip6")
+func ParseIPTablesSave(family string, data []byte) (fw Firewall, err error) {
+	table := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || line == "COMMIT":
+			continue
+		case strings.HasPrefix(line, "*"):
+			table = line[1:]
+		case strings.HasPrefix(line, ":"):
+			// :NAME POLICY [packets:bytes]
+			fields := strings.Fields(line[1:])
+			if len(fields) < 2 {
+				return fw, fmt.Errorf("Couldn't parse chain on line %d: %s", i+1, line)
+			}
+			chain := FirewallChain{family, table, fields[0], builtinHooks[fields[0]], ""}
+			if fields[1] != "-" {
+				chain.Policy = fields[1]
+			}
+			fw.Chains = append(fw.Chains, chain)
+		case strings.HasPrefix(line, "-A "):
+			words, err := splitRuleWords(line)
+			if err != nil {
+				return fw, err
+			}
+			if len(words) < 2 {
+				return fw, fmt.Errorf("Couldn't parse rule on line %d: %s", i+1, line)
+			}
+			rule := FirewallRule{
+				Family: family,
+				Table:  table,
+				Chain:  words[1],
+				Hook:   builtinHooks[words[1]],
+				Text:   line,
+			}
+			if err := parseIPTablesRule(&rule, words[2:]); err != nil {
+				return fw, fmt.Errorf("Couldn't parse rule on line %d: %s", i+1, err)
+			}
+			fw.Rules = append(fw.Rules, rule)
+		default:
+			return fw, fmt.Errorf("Couldn't parse line %d: %s", i+1, line)
+		}
+		if table == "" {
+			return fw, fmt.Errorf("Line %d is outside of a table: %s", i+1, line)
+		}
+	}
+	return fw, nil
+}
+
+// nftChain is a chain in nft's JSON output
+type nftChain struct {
+	Family string `json:"family"`
+	Table  string `json:"table"`
+	Name   string `json:"name"`
+	Hook   string `json:"hook"`
+	Policy string `json:"policy"`
+}
+
+// nftRule is a rule in nft's JSON output
+type nftRule struct {
+	Family string                       `json:"family"`
+	Table  string                       `json:"table"`
+	Chain  string                       `json:"chain"`
+	Expr   []map[string]json.RawMessage `json:"expr"`
+}
+
+// nftMatch is a match statement, which compares left with right
+type nftMatch struct {
+	Op    string                     `json:"op"`
+	Left  map[string]json.RawMessage `json:"left"`
+	Right json.RawMessage            `json:"right"`
+}
+
+// nftField is the payload, meta or ct expression on the left of a match
+type nftField struct {
+	Protocol string `json:"protocol"`
+	Field    string `json:"field"`
+	Key      string `json:"key"`
+}
+
+// nftAddresses converts the right side of an address match to CIDRs: an
+// address, a prefix, or a set of them. A reference to a named set is kept as
+// it is, e.g. @trusted.
+func nftAddresses(right json.RawMessage) (cidrs []string, err error) {
+	var address string
+	var prefix struct {
+		Prefix *struct {
+			Addr string `json:"addr"`
+			Len  int    `json:"len"`
+		} `json:"prefix"`
+	}
+	var set struct {
+		Set []json.RawMessage `json:"set"`
+	}
+	switch {
+	case json.Unmarshal(right, &address) == nil && strings.HasPrefix(address, "@"):
+		return []string{address}, nil
+	case json.Unmarshal(right, &address) == nil:
+		cidr, err := NormalizeCIDR(address)
+		return []string{cidr}, err
+	case json.Unmarshal(right, &prefix) == nil && prefix.Prefix != nil:
+		cidr, err := NormalizeCIDR(prefix.Prefix.Addr + "/" + strconv.Itoa(prefix.Prefix.Len))
+		return []string{cidr}, err
+	case json.Unmarshal(right, &set) == nil && set.Set != nil:
+		for _, item := range set.Set {
+			itemCIDRs, err := nftAddresses(item)
+			if err != nil {
+				return cidrs, err
+			}
+			cidrs = append(cidrs, itemCIDRs...)
+		}
+		return cidrs, nil
+	}
+	return cidrs, fmt.Errorf("Couldn't parse address: %s", right)
+}
+
+// nftPorts converts the right side of a port match to port ranges: a port, a
+// range, or a set of them
+func nftPorts(right json.RawMessage) (ranges []PortRange, err error) {
+	var port uint16
+	var portRange struct {
+		Range []uint16 `json:"range"`
+	}
+	var set struct {
+		Set []json.RawMessage `json:"set"`
+	}
+	switch {
+	case json.Unmarshal(right, &port) == nil:
+		return []PortRange{{port, port}}, nil
+	case json.Unmarshal(right, &portRange) == nil && len(portRange.Range) == 2:
+		return []PortRange{{portRange.Range[0], portRange.Range[1]}}, nil
+	case json.Unmarshal(right, &set) == nil && set.Set != nil:
+		for _, item := range set.Set {
+			itemRanges, err := nftPorts(item)
+			if err != nil {
+				return ranges, err
+			}
+			ranges = append(ranges, itemRanges...)
+		}
+		return ranges, nil
+	}
+	return ranges, fmt.Errorf("Couldn't parse port: %s", right)
+}
+
+// nftVerdicts are the statements that end a rule, and their iptables names
+var nftVerdicts = map[string]string{
+	"accept": "ACCEPT",
+	"drop":   "DROP",
+	"reject": "REJECT",
+	"return": "RETURN",
+	"queue":  "QUEUE",
+	"jump":   "",
+	"goto":   "",
+}
+
+// parseNFTMatch fills in the part of rule that a match statement describes.
+// Matches on fields that rules aren't matched by (e.g. ct state) are skipped.
+func parseNFTMatch(rule *FirewallRule, match nftMatch) error {
+	negate := ""
+	if match.Op == "!=" {
+		negate = "!"
+	}
+	var field nftField
+	if raw, ok := match.Left["payload"]; ok {
+		if err := json.Unmarshal(raw, &field); err != nil {
+			return err
+		}
+	} else if raw, ok := match.Left["meta"]; ok {
+		if err := json.Unmarshal(raw, &field); err != nil {
+			return err
+		}
+	} else {
+		return nil
+	}
+	switch {
+	case field.Field == "saddr" || field.Field == "daddr":
+		cidrs, err := nftAddresses(match.Right)
+		if err != nil {
+			return err
+		}
+		for i := range cidrs {
+			cidrs[i] = negate + cidrs[i]
+		}
+		if field.Field == "saddr" {
+			rule.Sources = cidrs
+		} else {
+			rule.Destinations = cidrs
+		}
+	case field.Field == "dport" && negate == "":
+		ports, err := nftPorts(match.Right)
+		if err != nil {
+			return err
+		}
+		rule.Ports = ports
+		// th (transport header) is any protocol, given by an l4proto match
+		if field.Protocol != "th" {
+			rule.Protocol = field.Protocol
+		}
+	case field.Key == "l4proto" || field.Key == "iifname":
+		var value string
+		if err := json.Unmarshal(match.Right, &value); err != nil {
+			return nil
+		}
+		if field.Key == "l4proto" {
+			rule.Protocol = negate + value
+		} else {
+			rule.InInterface = negate + value
+		}
+	}
+	return nil
+}
+
+// nftRuleText describes a parsed nftables rule in the style of iptables-save
+func nftRuleText(rule FirewallRule) string {
+	text := "-A " + rule.Chain
+	if rule.InInterface != "" {
+		text += " -i " + rule.InInterface
+	}
+	if len(rule.Sources) > 0 {
+		text += " -s " + strings.Join(rule.Sources, ",")
+	}
+	if len(rule.Destinations) > 0 {
+		text += " -d " + strings.Join(rule.Destinations, ",")
+	}
+	if rule.Protocol != "" {
+		text += " -p " + rule.Protocol
+	}
+	var ports []string
+	for _, portRange := range rule.Ports {
+		port := strconv.Itoa(int(portRange.Min))
+		if portRange.Max != portRange.Min {
+			port += ":" + strconv.Itoa(int(portRange.Max))
+		}
+		ports = append(ports, port)
+	}
+	if len(ports) > 0 {
+		text += " --dports " + strings.Join(ports, ",")
+	}
+	if rule.Target != "" {
+		text += " -j " + rule.Target
+	}
+	return text
+}
+
+// ParseNFTJSON parses the output of `nft -j list ruleset`. Policies and
+// verdicts are upper case, as they are in iptables.
+func ParseNFTJSON(data []byte) (fw Firewall, err error) {
+	var ruleset struct {
+		Nftables []map[string]json.RawMessage `json:"nftables"`
+	}
+	if err := json.Unmarshal(data, &ruleset); err != nil {
+		return fw, err
+	}
+	hooks := make(map[string]string)
+	for _, object := range ruleset.Nftables {
+		if raw, ok := object["chain"]; ok {
+			var chain nftChain
+			if err := json.Unmarshal(raw, &chain); err != nil {
+				return fw, err
+			}
+			hooks[chain.Family+" "+chain.Table+" "+chain.Name] = chain.Hook
+			fw.Chains = append(fw.Chains, FirewallChain{
+				chain.Family, chain.Table, chain.Name, chain.Hook,
+				strings.ToUpper(chain.Policy),
+			})
+		} else if raw, ok := object["rule"]; ok {
+			var parsed nftRule
+			if err := json.Unmarshal(raw, &parsed); err != nil {
+				return fw, err
+			}
+			rule := FirewallRule{
+				Family: parsed.Family,
+				Table:  parsed.Table,
+				Chain:  parsed.Chain,
+				Hook:   hooks[parsed.Family+" "+parsed.Table+" "+parsed.Chain],
+			}
+			for _, expr := range parsed.Expr {
+				if raw, ok := expr["match"]; ok {
+					var match nftMatch
+					if err := json.Unmarshal(raw, &match); err != nil {
+						return fw, err
+					}
+					if err := parseNFTMatch(&rule, match); err != nil {
+						return fw, err
+					}
+					continue
+				}
+				for statement, target := range nftVerdicts {
+					raw, ok := expr[statement]
+					if !ok {
+						continue
+					} else if target == "" {
+						var jump struct {
+							Target string `json:"target"`
+						}
+						json.Unmarshal(raw, &jump)
+						target = jump.Target
+					}
+					rule.Target = target
+				}
+			}
+			rule.Text = nftRuleText(rule)
+			fw.Rules = append(fw.Rules, rule)
+		}
+	}
+	return fw, nil
+}
+
+// iptablesCommands are the commands that ReadFirewall falls back on when it
+// can't use nft, and the family of the rules each one lists
+var iptablesCommands = []struct{ name, family string }{
+	{"iptables-save", "ip"},
+	{"ip6tables-save", "ip6"},
+}
+
+// readNFT reads the ruleset from nft, which must support JSON output
+func readNFT() (Firewall, error) {
+	out, err := exec.Command("nft", "-j", "list", "ruleset").Output()
+	if err != nil {
+		return Firewall{}, fmt.Errorf("Couldn't run nft: %s", err)
+	}
+	fw, err := ParseNFTJSON(out)
+	if err != nil {
+		return fw, fmt.Errorf("Couldn't parse output of nft: %s", err)
+	}
+	return fw, nil
+}
+
+// ReadFirewall reads the firewall rules from `nft -j list ruleset`, or if nft
+// isn't installed or can't list them as JSON, from iptables-save and
+// ip6tables-save. It doesn't read both, since with iptables-nft they list the
+// same rules. This usually requires root.
+func ReadFirewall() (fw Firewall, err error) {
+	var nftErr error
+	if _, err := exec.LookPath("nft"); err == nil {
+		var nft Firewall
+		if nft, nftErr = readNFT(); nftErr == nil {
+			return nft, nil
+		}
+	}
+	found := false
+	for _, command := range iptablesCommands {
+		if _, err := exec.LookPath(command.name); err != nil {
+			continue
+		}
+		found = true
+		out, err := exec.Command(command.name).Output()
+		if err != nil {
+			return fw, fmt.Errorf("Couldn't run %s: %s", command.name, err)
+		}
+		parsed, err := ParseIPTablesSave(command.family, out)
+		if err != nil {
+			return fw, fmt.Errorf("Couldn't parse output of %s: %s", command.name, err)
+		}
+		fw.Chains = append(fw.Chains, parsed.Chains...)
+		fw.Rules = append(fw.Rules, parsed.Rules...)
+	}
+	if !found && nftErr != nil {
+		return fw, nftErr
+	} else if !found {
+		return fw, errors.New("Couldn't find iptables-save, ip6tables-save or nft")
+	}
+	return fw, nil
+}
@@ -0,0 +1,245 @@
+package netstatus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readFirewallFixture parses a captured ruleset from the fixtures directory
+func readFirewallFixture(t *testing.T, name string) Firewall {
+	data, err := ioutil.ReadFile(filepath.Join("fixtures", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fw Firewall
+	switch name {
+	case "iptables-save.txt":
+		fw, err = ParseIPTablesSave("ip", data)
+	case "ip6tables-save.txt":
+		fw, err = ParseIPTablesSave("ip6", data)
+	default:
+		fw, err = ParseNFTJSON(data)
+	}
+	if err != nil {
+		t.Fatalf("Couldn't parse %s: %s", name, err)
+	}
+	return fw
+}
+
+// compareRules compares the parts of rules that are matched on, ignoring Text
+func compareRules(t *testing.T, name string, actual, expected []FirewallRule) {
+	if len(actual) != len(expected) {
+		t.Errorf("Expected %d rules in %s, got %d", len(expected), name, len(actual))
+		return
+	}
+	for i := range expected {
+		rule := actual[i]
+		rule.Text = ""
+		if !reflect.DeepEqual(rule, expected[i]) {
+			t.Errorf("Unexpected rule in %s:\n\tExpected: %+v\n\tActual: %+v",
+				name, expected[i], rule)
+		}
+	}
+}
+
+func TestParseIPTablesSave(t *testing.T) {
+	t.Parallel()
+	fw := readFirewallFixture(t, "iptables-save.txt")
+	chains := []FirewallChain{
+		{"ip", "nat", "PREROUTING", "prerouting", "ACCEPT"},
+		{"ip", "nat", "INPUT", "input", "ACCEPT"},
+		{"ip", "nat", "OUTPUT", "output", "ACCEPT"},
+		{"ip", "nat", "POSTROUTING", "postrouting", "ACCEPT"},
+		{"ip", "nat", "DOCKER", "", ""},
+		{"ip", "filter", "INPUT", "input", "DROP"},
+		{"ip", "filter", "FORWARD", "forward", "DROP"},
+		{"ip", "filter", "OUTPUT", "output", "ACCEPT"},
+		{"ip", "filter", "f2b-sshd", "", ""},
+	}
+	if !reflect.DeepEqual(fw.Chains, chains) {
+		t.Errorf("Unexpected chains: %+v", fw.Chains)
+	}
+	input := func(rule FirewallRule) FirewallRule {
+		rule.Family, rule.Table, rule.Chain, rule.Hook = "ip", "filter", "INPUT", "input"
+		return rule
+	}
+	ssh := []PortRange{{22, 22}}
+	rules := []FirewallRule{
+		{Family: "ip", Table: "nat", Chain: "PREROUTING", Hook: "prerouting", Target: "DOCKER"},
+		{Family: "ip", Table: "nat", Chain: "POSTROUTING", Hook: "postrouting",
+			Sources: []string{"172.17.0.0/16"}, Target: "MASQUERADE"},
+		{Family: "ip", Table: "nat", Chain: "DOCKER", InInterface: "docker0", Target: "RETURN"},
+		input(FirewallRule{InInterface: "lo", Target: "ACCEPT"}),
+		input(FirewallRule{Target: "ACCEPT"}),
+		input(FirewallRule{Protocol: "tcp", Ports: ssh, Target: "f2b-sshd"}),
+		input(FirewallRule{Sources: []string{"10.20.0.0/16"}, Protocol: "tcp", Ports: ssh, Target: "ACCEPT"}),
+		input(FirewallRule{Protocol: "tcp", Target: "ACCEPT",
+			Ports: []PortRange{{80, 80}, {443, 443}, {8000, 8100}}}),
+		input(FirewallRule{Sources: []string{"!192.168.0.0/16"}, Protocol: "udp",
+			Ports: []PortRange{{161, 161}}, Target: "DROP"}),
+		input(FirewallRule{Sources: []string{"10.20.1.5/32"}, Protocol: "tcp",
+			Ports: []PortRange{{9100, 9100}}, Target: "ACCEPT"}),
+		input(FirewallRule{Protocol: "icmp", Target: "ACCEPT"}),
+		input(FirewallRule{Target: "REJECT"}),
+		{Family: "ip", Table: "filter", Chain: "f2b-sshd", Sources: []string{"203.0.113.7/32"}, Target: "REJECT"},
+		{Family: "ip", Table: "filter", Chain: "f2b-sshd", Target: "RETURN"},
+	}
+	compareRules(t, "iptables-save.txt", fw.Rules, rules)
+	comment := `-A INPUT -s 10.20.0.0/16 -p tcp -m tcp --dport 22 -m comment --comment "ssh from management" -j ACCEPT`
+	if len(fw.Rules) > 6 && fw.Rules[6].Text != comment {
+		t.Errorf("Unexpected rule text: %s", fw.Rules[6].Text)
+	}
+
+	fw6 := readFirewallFixture(t, "ip6tables-save.txt")
+	if len(fw6.Rules) != 4 || fw6.Rules[2].Family != "ip6" ||
+		!reflect.DeepEqual(fw6.Rules[2].Sources, []string{"fd00:20::/64"}) {
+		t.Errorf("Unexpected IPv6 rules: %+v", fw6.Rules)
+	}
+
+	invalid := []string{
+		"-A INPUT -j ACCEPT\n",
+		"*filter\n:INPUT\n",
+		"*filter\n-A INPUT -s 10.0.0.300 -j ACCEPT\n",
+		"*filter\n-A INPUT -p tcp --dport ssh -j ACCEPT\n",
+		"*filter\n-A INPUT -m comment --comment \"unterminated -j ACCEPT\n",
+		"*filter\nnonsense\n",
+	}
+	for _, data := range invalid {
+		if _, err := ParseIPTablesSave("ip", []byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestParseNFTJSON(t *testing.T) {
+	t.Parallel()
+	fw := readFirewallFixture(t, "nft-ruleset.json")
+	chains := []FirewallChain{
+		{"inet", "filter", "input", "input", "DROP"},
+		{"inet", "filter", "forward", "forward", "DROP"},
+		{"inet", "filter", "output", "output", "ACCEPT"},
+		{"inet", "filter", "services", "", ""},
+	}
+	if !reflect.DeepEqual(fw.Chains, chains) {
+		t.Errorf("Unexpected chains: %+v", fw.Chains)
+	}
+	input := func(rule FirewallRule) FirewallRule {
+		rule.Family, rule.Table, rule.Chain, rule.Hook = "inet", "filter", "input", "input"
+		return rule
+	}
+	rules := []FirewallRule{
+		input(FirewallRule{Target: "ACCEPT"}),
+		input(FirewallRule{InInterface: "lo", Target: "ACCEPT"}),
+		input(FirewallRule{Sources: []string{"10.20.0.0/16"}, Protocol: "tcp",
+			Ports: []PortRange{{22, 22}}, Target: "ACCEPT"}),
+		input(FirewallRule{Protocol: "tcp", Target: "ACCEPT",
+			Ports: []PortRange{{80, 80}, {443, 443}, {8000, 8100}}}),
+		input(FirewallRule{Protocol: "udp", Ports: []PortRange{{53, 53}}, Target: "services"}),
+		input(FirewallRule{Sources: []string{"!192.168.1.1/32"}, Protocol: "udp",
+			Ports: []PortRange{{161, 161}}, Target: "DROP"}),
+		// each address in a set is kept
+		{Family: "inet", Table: "filter", Chain: "services",
+			Sources: []string{"fd00::1/128", "fd00::2/128"}, Target: "ACCEPT"},
+		input(FirewallRule{Target: "REJECT"}),
+	}
+	compareRules(t, "nft-ruleset.json", fw.Rules, rules)
+	text := "-A input -s 10.20.0.0/16 -p tcp --dports 22 -j ACCEPT"
+	if len(fw.Rules) > 2 && fw.Rules[2].Text != text {
+		t.Errorf("Unexpected rule text: %s", fw.Rules[2].Text)
+	}
+	named := []byte(`{"nftables": [{"rule": {"family": "inet", "table": "filter",
+		"chain": "input", "expr": [{"match": {"op": "==", "left": {"payload":
+		{"protocol": "ip", "field": "saddr"}}, "right": "@trusted"}}, {"accept": null}]}}]}`)
+	if fw, err := ParseNFTJSON(named); err != nil || len(fw.Rules) != 1 ||
+		!reflect.DeepEqual(fw.Rules[0].Sources, []string{"@trusted"}) {
+		t.Errorf("Unexpected rules matching a named set: %+v, %v", fw.Rules, err)
+	}
+	if _, err := ParseNFTJSON([]byte("table inet filter {")); err == nil {
+		t.Error("Expected an error parsing nft's text output")
+	}
+}
+
+func TestFirewallRuleHasPort(t *testing.T) {
+	t.Parallel()
+	rule := FirewallRule{Ports: []PortRange{{80, 80}, {8000, 8100}}}
+	for port, expected := range map[uint16]bool{80: true, 81: false, 8000: true, 8050: true, 8101: false} {
+		if rule.HasPort(port) != expected {
+			t.Errorf("Unexpected HasPort(%d): %v", port, !expected)
+		}
+	}
+}
+
+func TestNormalizeCIDR(t *testing.T) {
+	t.Parallel()
+	cidrs := map[string]string{
+		"10.1.2.3":     "10.1.2.3/32",
+		"10.1.2.3/8":   "10.0.0.0/8",
+		"fd00::1":      "fd00::1/128",
+		"fd00:20::/64": "fd00:20::/64",
+	}
+	for address, expected := range cidrs {
+		if cidr, err := NormalizeCIDR(address); err != nil || cidr != expected {
+			t.Errorf("Unexpected CIDR for %s: %s, %v", address, cidr, err)
+		}
+	}
+	for _, address := range []string{"", "10.0.0.256", "10.0.0.0/33", "example.com"} {
+		if _, err := NormalizeCIDR(address); err == nil {
+			t.Errorf("Expected an error normalizing %q", address)
+		}
+	}
+}
+
+// not parallel, since it changes PATH for the whole package
+func TestReadFirewall(t *testing.T) {
+	dir, err := ioutil.TempDir("", "distributive-firewall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// command writes a script that stands in for a command
+	command := func(name, script string) {
+		script = "#!/bin/sh\n" + script + "\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// prints writes a script that prints a fixture
+	prints := func(name, fixture string) {
+		path, err := filepath.Abs(filepath.Join("fixtures", fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		command(name, "exec /bin/cat "+path)
+	}
+	prints("iptables-save", "iptables-save.txt")
+	prints("ip6tables-save", "ip6tables-save.txt")
+	prints("nft", "nft-ruleset.json")
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir)
+
+	// iptables-nft shows the same rules, so only nft's are read
+	fw, err := ReadFirewall()
+	if expected := readFirewallFixture(t, "nft-ruleset.json"); err != nil ||
+		!reflect.DeepEqual(fw, expected) {
+		t.Errorf("Expected only the rules from nft: %d rules, %v", len(fw.Rules), err)
+	}
+
+	command("nft", "echo 'Error: JSON support not compiled-in' >&2; exit 1")
+	ip := readFirewallFixture(t, "iptables-save.txt")
+	ip6 := readFirewallFixture(t, "ip6tables-save.txt")
+	fw, err = ReadFirewall()
+	if err != nil || len(fw.Rules) != len(ip.Rules)+len(ip6.Rules) ||
+		len(fw.Chains) != len(ip.Chains)+len(ip6.Chains) {
+		t.Errorf("Expected the rules from iptables-save and ip6tables-save: %d rules, %v",
+			len(fw.Rules), err)
+	}
+
+	os.Remove(filepath.Join(dir, "iptables-save"))
+	os.Remove(filepath.Join(dir, "ip6tables-save"))
+	if _, err := ReadFirewall(); err == nil {
+		t.Error("Expected nft's error without iptables-save")
+	}
+}
@@ -0,0 +1,163 @@
+package netstatus
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+// Interface is the state of a network interface, from /sys/class/net
+type Interface struct {
+	Name string
+	MTU  int
+	// Speed is in Mb/s, or -1 if it's unknown, e.g. because the link is down
+	// or the interface is virtual
+	Speed int
+	// Duplex is full | half | unknown
+	Duplex string
+	// Carrier is whether the physical link is up
+	Carrier bool
+	// OperState is RFC 2863's operational state, e.g. up, down or dormant
+	OperState string
+}
+
+// readSysValue reads a single value from a file in /sys
+func readSysValue(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	return strings.TrimSpace(string(data)), err
+}
+
+// readInterface reads an interface from its directory in /sys/class/net. Some
+// attributes can't be read while the interface is down, and are left unknown.
+func readInterface(dir string) (iface Interface, err error) {
+	iface.Name = filepath.Base(dir)
+	mtu, err := readSysValue(filepath.Join(dir, "mtu"))
+	if err != nil {
+		return iface, err
+	}
+	if iface.MTU, err = strconv.Atoi(mtu); err != nil {
+		return iface, fmt.Errorf("Invalid MTU of %s: %s", iface.Name, mtu)
+	}
+	iface.Speed = -1
+	if speed, err := readSysValue(filepath.Join(dir, "speed")); err == nil {
+		if n, err := strconv.Atoi(speed); err == nil && n > 0 {
+			iface.Speed = n
+		}
+	}
+	iface.Duplex = "unknown"
+	if duplex, err := readSysValue(filepath.Join(dir, "duplex")); err == nil && duplex != "" {
+		iface.Duplex = duplex
+	}
+	carrier, _ := readSysValue(filepath.Join(dir, "carrier"))
+	iface.Carrier = carrier == "1"
+	iface.OperState, _ = readSysValue(filepath.Join(dir, "operstate"))
+	return iface, nil
+}
+
+// ReadInterface reads the state of the named interface
+func ReadInterface(name string) (Interface, error) {
+	return readInterface(chkutil.SysPath("class", "net", name))
+}
+
+// readInterfaceCounter reads a counter from an interface's statistics
+// directory. "errors" and "drops" are the sums of the rx and tx counters.
+func readInterfaceCounter(dir, counter string) (total uint64, err error) {
+	counters := []string{counter}
+	switch counter {
+	case "errors":
+		counters = []string{"rx_errors", "tx_errors"}
+	case "drops":
+		counters = []string{"rx_dropped", "tx_dropped"}
+	}
+	for _, name := range counters {
+		value, err := readSysValue(filepath.Join(dir, "statistics", name))
+		if os.IsNotExist(err) {
+			return total, fmt.Errorf("No such counter: %s", name)
+		} else if err != nil {
+			return total, err
+		}
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return total, fmt.Errorf("Invalid value of %s: %s", name, value)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// InterfaceCounter reads one of the counters in an interface's statistics,
+// e.g. rx_errors or tx_dropped. "errors" and "drops" are the sums of the rx
+// and tx counters.
+func InterfaceCounter(name, counter string) (uint64, error) {
+	return readInterfaceCounter(chkutil.SysPath("class", "net", name), counter)
+}
+
+// BondSlave is a member of a bonded interface
+type BondSlave struct {
+	Name      string
+	MIIStatus string
+	Speed     string
+	Duplex    string
+	// LinkFailures is how many times the slave's link has gone down
+	LinkFailures int
+}
+
+// Bond is the state of a bonded interface, from /proc/net/bonding
+type Bond struct {
+	Mode        string
+	MIIStatus   string
+	ActiveSlave string
+	Slaves      []BondSlave
+}
+
+// ParseBonding parses the contents of a file in /proc/net/bonding
+func ParseBonding(data []byte) (bond Bond, err error) {
+	var slave *BondSlave
+	for _, line := range strings.Split(string(data), "\n") {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:colon])
+		value := strings.TrimSpace(line[colon+1:])
+		switch {
+		case key == "Slave Interface":
+			bond.Slaves = append(bond.Slaves, BondSlave{Name: value})
+			slave = &bond.Slaves[len(bond.Slaves)-1]
+		case slave == nil && key == "Bonding Mode":
+			bond.Mode = value
+		case slave == nil && key == "MII Status":
+			bond.MIIStatus = value
+		case slave == nil && key == "Currently Active Slave":
+			bond.ActiveSlave = value
+		case slave != nil && key == "MII Status":
+			slave.MIIStatus = value
+		case slave != nil && key == "Speed":
+			slave.Speed = value
+		case slave != nil && key == "Duplex":
+			slave.Duplex = value
+		case slave != nil && key == "Link Failure Count":
+			if slave.LinkFailures, err = strconv.Atoi(value); err != nil {
+				return bond, fmt.Errorf("Invalid link failure count: %s", value)
+			}
+		}
+	}
+	if bond.MIIStatus == "" {
+		return bond, fmt.Errorf("Couldn't find bond's MII status")
+	}
+	return bond, nil
+}
+
+// ReadBond reads the state of the named bonded interface
+func ReadBond(name string) (Bond, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcSelfPath("net", "bonding", name))
+	if err != nil {
+		return Bond{}, err
+	}
+	return ParseBonding(data)
+}
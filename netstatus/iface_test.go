@@ -0,0 +1,126 @@
+package netstatus
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSysFiles writes files under dir, creating directories as needed
+func writeSysFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadInterface(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "distributive-iface")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeSysFiles(t, filepath.Join(dir, "eth0"), map[string]string{
+		"mtu":                   "9000\n",
+		"speed":                 "10000\n",
+		"duplex":                "full\n",
+		"carrier":               "1\n",
+		"operstate":             "up\n",
+		"statistics/rx_errors":  "3\n",
+		"statistics/tx_errors":  "4\n",
+		"statistics/rx_dropped": "10\n",
+		"statistics/tx_dropped": "0\n",
+	})
+	// a link that's down: speed and duplex can't be read, and there's no
+	// carrier
+	writeSysFiles(t, filepath.Join(dir, "eth1"), map[string]string{
+		"mtu":       "1500\n",
+		"speed":     "-1\n",
+		"carrier":   "0\n",
+		"operstate": "down\n",
+	})
+	up, err := readInterface(filepath.Join(dir, "eth0"))
+	expected := Interface{"eth0", 9000, 10000, "full", true, "up"}
+	if err != nil || up != expected {
+		t.Errorf("Unexpected interface: %+v, %v", up, err)
+	}
+	down, err := readInterface(filepath.Join(dir, "eth1"))
+	expected = Interface{"eth1", 1500, -1, "unknown", false, "down"}
+	if err != nil || down != expected {
+		t.Errorf("Unexpected interface: %+v, %v", down, err)
+	}
+	if _, err := readInterface(filepath.Join(dir, "eth2")); err == nil {
+		t.Error("Expected an error reading a nonexistent interface")
+	}
+
+	counters := map[string]uint64{
+		"rx_errors": 3, "errors": 7, "drops": 10, "tx_dropped": 0,
+	}
+	for counter, expected := range counters {
+		value, err := readInterfaceCounter(filepath.Join(dir, "eth0"), counter)
+		if err != nil || value != expected {
+			t.Errorf("Unexpected value of %s: %d, %v", counter, value, err)
+		}
+	}
+	if _, err := readInterfaceCounter(filepath.Join(dir, "eth0"), "rx_crc_errors"); err == nil {
+		t.Error("Expected an error reading a nonexistent counter")
+	}
+}
+
+var bondingFixture = `Ethernet Channel Bonding Driver: v3.7.1 (April 27, 2011)
+
+Bonding Mode: fault-tolerance (active-backup)
+Primary Slave: None
+Currently Active Slave: eth1
+MII Status: up
+MII Polling Interval (ms): 100
+Up Delay (ms): 0
+Down Delay (ms): 0
+
+Slave Interface: eth0
+MII Status: down
+Speed: Unknown
+Duplex: Unknown
+Link Failure Count: 2
+Permanent HW addr: 52:54:00:12:34:56
+Slave queue ID: 0
+
+Slave Interface: eth1
+MII Status: up
+Speed: 1000 Mbps
+Duplex: full
+Link Failure Count: 0
+Permanent HW addr: 52:54:00:12:34:57
+Slave queue ID: 0
+`
+
+func TestParseBonding(t *testing.T) {
+	t.Parallel()
+	bond, err := ParseBonding([]byte(bondingFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bond.Mode != "fault-tolerance (active-backup)" || bond.MIIStatus != "up" ||
+		bond.ActiveSlave != "eth1" || len(bond.Slaves) != 2 {
+		t.Errorf("Unexpected bond: %+v", bond)
+	}
+	slaves := []BondSlave{
+		{"eth0", "down", "Unknown", "Unknown", 2},
+		{"eth1", "up", "1000 Mbps", "full", 0},
+	}
+	for i, slave := range slaves {
+		if i < len(bond.Slaves) && bond.Slaves[i] != slave {
+			t.Errorf("Unexpected slave: %+v", bond.Slaves[i])
+		}
+	}
+	if _, err := ParseBonding([]byte("Slave Interface: eth0\n")); err == nil {
+		t.Error("Expected an error parsing a bond without a status")
+	}
+}
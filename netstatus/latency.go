@@ -0,0 +1,132 @@
+package netstatus
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Latency is the outcome of connecting to an address several times
+type Latency struct {
+	// Samples is how many attempts were made
+	Samples int
+	// Failures is how many attempts couldn't connect, or timed out
+	Failures int
+	// Times are how long each successful attempt took. For UDP, only attempts
+	// that got a reply are timed.
+	Times []time.Duration
+}
+
+// Min is the shortest time an attempt took
+func (l Latency) Min() (min time.Duration) {
+	for i, t := range l.Times {
+		if i == 0 || t < min {
+			min = t
+		}
+	}
+	return min
+}
+
+// Max is the longest time an attempt took
+func (l Latency) Max() (max time.Duration) {
+	for _, t := range l.Times {
+		if t > max {
+			max = t
+		}
+	}
+	return max
+}
+
+// Avg is the mean time that attempts took
+func (l Latency) Avg() time.Duration {
+	if len(l.Times) < 1 {
+		return 0
+	}
+	var total time.Duration
+	for _, t := range l.Times {
+		total += t
+	}
+	return total / time.Duration(len(l.Times))
+}
+
+// Loss is the percentage of attempts that failed
+func (l Latency) Loss() float64 {
+	if l.Samples < 1 {
+		return 0
+	}
+	return 100 * float64(l.Failures) / float64(l.Samples)
+}
+
+// String summarizes the latency, like ping does
+func (l Latency) String() string {
+	return fmt.Sprintf("min/avg/max = %v/%v/%v, loss = %.0f%%",
+		l.Min(), l.Avg(), l.Max(), l.Loss())
+}
+
+// connectTCP times how long it takes to establish a TCP connection
+func connectTCP(address string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	conn.Close()
+	return elapsed, nil
+}
+
+// errNoReply is returned by connectUDP when nothing answered the probe
+var errNoReply = errors.New("No reply")
+
+// connectUDP sends an empty datagram to address and times how long it takes
+// for a reply. UDP has no handshake, so a closed port only shows up as an
+// ICMP port unreachable message, which is reported as an error by the read.
+func connectUDP(address string, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(start.Add(timeout))
+	if _, err := conn.Write([]byte{}); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1)
+	if _, err := conn.Read(buf); err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return 0, errNoReply
+		}
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// MeasureLatency tries to connect to address (host:port) over protocol ("TCP"
+// | "UDP") samples times, waiting up to timeout each time. Many UDP services
+// don't answer an empty datagram, so for UDP an attempt that gets no reply
+// isn't counted as a failure, it just isn't timed. Only an explicit refusal
+// is.
+func MeasureLatency(protocol, address string, samples int, timeout time.Duration) Latency {
+	latency := Latency{Samples: samples}
+	udp := strings.ToLower(protocol) == "udp"
+	for i := 0; i < samples; i++ {
+		var elapsed time.Duration
+		var err error
+		if udp {
+			elapsed, err = connectUDP(address, timeout)
+		} else {
+			elapsed, err = connectTCP(address, timeout)
+		}
+		switch {
+		case err == errNoReply:
+		case err != nil:
+			latency.Failures++
+		default:
+			latency.Times = append(latency.Times, elapsed)
+		}
+	}
+	return latency
+}
@@ -0,0 +1,78 @@
+package netstatus
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	t.Parallel()
+	latency := Latency{
+		Samples:  4,
+		Failures: 1,
+		Times:    []time.Duration{3 * time.Millisecond, time.Millisecond, 5 * time.Millisecond},
+	}
+	if min := latency.Min(); min != time.Millisecond {
+		t.Errorf("Unexpected min: %v", min)
+	}
+	if avg := latency.Avg(); avg != 3*time.Millisecond {
+		t.Errorf("Unexpected avg: %v", avg)
+	}
+	if max := latency.Max(); max != 5*time.Millisecond {
+		t.Errorf("Unexpected max: %v", max)
+	}
+	if loss := latency.Loss(); loss != 25 {
+		t.Errorf("Unexpected loss: %v", loss)
+	}
+	expected := "min/avg/max = 1ms/3ms/5ms, loss = 25%"
+	if str := latency.String(); str != expected {
+		t.Errorf("Unexpected string:\n\tExpected: %s\n\tActual: %s", expected, str)
+	}
+	if (Latency{}).Avg() != 0 || (Latency{}).Loss() != 0 {
+		t.Error("Empty latency had nonzero avg or loss")
+	}
+}
+
+func TestMeasureLatency(t *testing.T) {
+	t.Parallel()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	latency := MeasureLatency("TCP", addr, 3, time.Second)
+	if latency.Samples != 3 || latency.Failures != 0 || len(latency.Times) != 3 {
+		t.Errorf("Unexpected latency to open port: %+v", latency)
+	}
+	ln.Close()
+	latency = MeasureLatency("TCP", addr, 2, time.Second)
+	if latency.Failures != 2 || len(latency.Times) != 0 {
+		t.Errorf("Unexpected latency to closed port: %+v", latency)
+	}
+
+	// a UDP service that doesn't reply isn't timed, but isn't lost either
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	udpAddr := conn.LocalAddr().String()
+	latency = MeasureLatency("UDP", udpAddr, 2, 50*time.Millisecond)
+	if latency.Failures != 0 || len(latency.Times) != 0 {
+		t.Errorf("Unexpected latency to silent UDP port: %+v", latency)
+	}
+	conn.Close()
+	latency = MeasureLatency("UDP", udpAddr, 2, time.Second)
+	if latency.Failures != 2 {
+		t.Errorf("Unexpected latency to closed UDP port: %+v", latency)
+	}
+}
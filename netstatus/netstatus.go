@@ -3,7 +3,6 @@
 package netstatus
 
 import (
-	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -11,11 +10,29 @@ import (
 	log "github.com/Sirupsen/logrus"
 )
 
-// PortOpen reports whether or not the given (decimal) port is open
+// PortOpen reports whether or not the given (decimal) port is listening
 // Its protocol argument can only be one of: "tcp" | "udp"
 func PortOpen(protocol string, port uint16) bool {
-	_, err := net.Dial(protocol, fmt.Sprintf("localhost:%v", port))
-	return err == nil
+	open, _ := PortListening(protocol, port)
+	return open
+}
+
+// ListeningPorts returns the local ports that have a socket listening on them,
+// as shown in /proc/net/{tcp,tcp6,udp,udp6}.
+// Its protocol argument can only be one of: "tcp" | "udp"
+func ListeningPorts(protocol string) (ports []uint16, err error) {
+	socks, err := ListeningSockets(protocol)
+	if err != nil {
+		return ports, err
+	}
+	seen := make(map[uint16]bool)
+	for _, sock := range socks {
+		if !seen[sock.LocalPort] {
+			seen[sock.LocalPort] = true
+			ports = append(ports, sock.LocalPort)
+		}
+	}
+	return ports, nil
 }
 
 // ValidIP returns a boolean answering the question "is this a valid IPV4/6
@@ -74,14 +91,16 @@ func Resolvable(host string) bool {
 // CanConnect tests whether a connection can be made to a given host on its
 // given port using protocol ("TCP"|"UDP")
 func CanConnect(address, protocol string, timeout time.Duration) bool {
+	var conn net.Conn
 	var err error
 	if timeout > 0 {
-		_, err = net.Dial(strings.ToLower(protocol), address)
+		conn, err = net.DialTimeout(strings.ToLower(protocol), address, timeout)
 	} else {
-		_, err = net.DialTimeout(strings.ToLower(protocol), address, timeout)
+		conn, err = net.Dial(strings.ToLower(protocol), address)
 	}
-	if err == nil {
-		return true
+	if err != nil {
+		return false
 	}
-	return false
+	conn.Close()
+	return true
 }
@@ -9,8 +9,7 @@ func TestPortOpen(t *testing.T) {
 			t.Errorf("Port was unexpectedly open over TCP: %v", port)
 		}
 		if PortOpen("udp", port) {
-			// TODO: this is a bug. PortOpen("udp") gives false positives.
-			//t.Errorf("Port was unexpectedly open over UDP: %v", port)
+			t.Errorf("Port was unexpectedly open over UDP: %v", port)
 		}
 	}
 }
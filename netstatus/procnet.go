@@ -0,0 +1,435 @@
+package netstatus
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+/// /proc/net parsing
+//
+// These functions read the kernel's view of sockets and routes directly, so
+// that they work without ss, netstat or route installed.
+
+// tcpStates are the names of the states in the st column of /proc/net/tcp, see
+// include/net/tcp_states.h
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// Socket is an entry in /proc/net/{tcp,tcp6,udp,udp6}
+type Socket struct {
+	// tcp | tcp6 | udp | udp6
+	Protocol   string
+	LocalIP    net.IP
+	LocalPort  uint16
+	RemoteIP   net.IP
+	RemotePort uint16
+	// e.g. LISTEN or ESTABLISHED. Unconnected UDP sockets are CLOSE.
+	State string
+	UID   int
+	Inode uint64
+	// PIDs of the processes with this socket open, see WithOwners
+	PIDs []int
+}
+
+// Listening reports whether this socket is accepting connections (TCP) or
+// datagrams from anyone (UDP)
+func (sock Socket) Listening() bool {
+	if strings.HasPrefix(sock.Protocol, "udp") {
+		return sock.State == "CLOSE"
+	}
+	return sock.State == "LISTEN"
+}
+
+// parseHexIP parses an address as it appears in /proc/net/{tcp,udp}{,6},
+// which is made of 32 bit words in host (little endian) byte order
+func parseHexIP(str string) (net.IP, error) {
+	data, err := hex.DecodeString(str)
+	if err != nil || (len(data) != net.IPv4len && len(data) != net.IPv6len) {
+		return nil, fmt.Errorf("Invalid address: %v", str)
+	}
+	ip := make(net.IP, len(data))
+	for word := 0; word < len(data); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = data[word+3-i]
+		}
+	}
+	return ip, nil
+}
+
+// parseHexAddress parses an IP:port as it appears in /proc/net/tcp
+func parseHexAddress(str string) (net.IP, uint16, error) {
+	colon := strings.LastIndex(str, ":")
+	if colon < 0 {
+		return nil, 0, fmt.Errorf("Invalid address: %v", str)
+	}
+	ip, err := parseHexIP(str[:colon])
+	if err != nil {
+		return nil, 0, err
+	}
+	port, err := strconv.ParseUint(str[colon+1:], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Invalid port in address: %v", str)
+	}
+	return ip, uint16(port), nil
+}
+
+// ParseSockets parses the contents of /proc/net/{tcp,tcp6,udp,udp6}, where
+// protocol is the name of the file
+func ParseSockets(protocol string, data []byte) (socks []Socket, err error) {
+	lines := strings.Split(string(data), "\n")
+	// the first line is the header
+	for _, line := range lines[1:] {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when
+		// retrnsmt uid timeout inode ...
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) < 10 {
+			return socks, fmt.Errorf("Couldn't parse %v line: %v", protocol, line)
+		}
+		sock := Socket{Protocol: protocol, State: tcpStates[fields[3]]}
+		sock.LocalIP, sock.LocalPort, err = parseHexAddress(fields[1])
+		if err != nil {
+			return socks, err
+		}
+		sock.RemoteIP, sock.RemotePort, err = parseHexAddress(fields[2])
+		if err != nil {
+			return socks, err
+		}
+		if sock.State == "" {
+			return socks, fmt.Errorf("Unknown socket state: %v", fields[3])
+		}
+		uid, err := strconv.Atoi(fields[7])
+		if err != nil {
+			return socks, fmt.Errorf("Invalid UID: %v", fields[7])
+		}
+		sock.UID = uid
+		sock.Inode, err = strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return socks, fmt.Errorf("Invalid inode: %v", fields[9])
+		}
+		socks = append(socks, sock)
+	}
+	return socks, nil
+}
+
+// Sockets reads the sockets of the given protocols: tcp | tcp6 | udp | udp6.
+// Missing IPv6 files (when IPv6 is disabled) are skipped.
+func Sockets(protocols ...string) (socks []Socket, err error) {
+	for _, protocol := range protocols {
+		data, err := ioutil.ReadFile(chkutil.ProcSelfPath("net", protocol))
+		if os.IsNotExist(err) && strings.HasSuffix(protocol, "6") {
+			continue
+		} else if err != nil {
+			return socks, err
+		}
+		parsed, err := ParseSockets(protocol, data)
+		if err != nil {
+			return socks, err
+		}
+		socks = append(socks, parsed...)
+	}
+	return socks, nil
+}
+
+// ListeningSockets returns the IPv4 and IPv6 sockets that are listening.
+// Its protocol argument can only be one of: "tcp" | "udp"
+func ListeningSockets(protocol string) (listening []Socket, err error) {
+	if protocol != "tcp" && protocol != "udp" {
+		return listening, fmt.Errorf("Invalid protocol: %v", protocol)
+	}
+	socks, err := Sockets(protocol, protocol+"6")
+	if err != nil {
+		return listening, err
+	}
+	for _, sock := range socks {
+		if sock.Listening() {
+			listening = append(listening, sock)
+		}
+	}
+	return listening, nil
+}
+
+// PortListening reports whether any socket is listening on this port.
+// Its protocol argument can only be one of: "tcp" | "udp"
+func PortListening(protocol string, port uint16) (bool, error) {
+	ports, err := ListeningPorts(protocol)
+	if err != nil {
+		return false, err
+	}
+	for _, listening := range ports {
+		if listening == port {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// unixTypes are the names of the socket types in /proc/net/unix
+var unixTypes = map[string]string{
+	"0001": "stream",
+	"0002": "dgram",
+	"0005": "seqpacket",
+}
+
+// soAcceptCon is the flag for listening sockets in /proc/net/unix
+const soAcceptCon = 1 << 16
+
+// UnixSocket is an entry in /proc/net/unix
+type UnixSocket struct {
+	// the path it's bound to, if any. Abstract sockets start with @.
+	Path string
+	// stream | dgram | seqpacket
+	Type      string
+	Listening bool
+	Inode     uint64
+	PIDs      []int
+}
+
+// ParseUnixSockets parses the contents of /proc/net/unix
+func ParseUnixSockets(data []byte) (socks []UnixSocket, err error) {
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[1:] {
+		// Num RefCount Protocol Flags Type St Inode Path
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) < 7 {
+			return socks, fmt.Errorf("Couldn't parse unix socket line: %v", line)
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return socks, fmt.Errorf("Invalid socket flags: %v", fields[3])
+		}
+		inode, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return socks, fmt.Errorf("Invalid inode: %v", fields[6])
+		}
+		sock := UnixSocket{
+			Type:      unixTypes[fields[4]],
+			Listening: flags&soAcceptCon != 0,
+			Inode:     inode,
+		}
+		if len(fields) > 7 {
+			sock.Path = strings.Join(fields[7:], " ")
+		}
+		socks = append(socks, sock)
+	}
+	return socks, nil
+}
+
+// UnixSockets reads /proc/net/unix
+func UnixSockets() ([]UnixSocket, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcSelfPath("net", "unix"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseUnixSockets(data)
+}
+
+// SocketOwners maps socket inodes to the PIDs of the processes that have them
+// open, by reading the links in /proc/*/fd. Processes that can't be read (e.g.
+// because they belong to another user) are skipped.
+func SocketOwners() (owners map[uint64][]int, err error) {
+	owners = make(map[uint64][]int)
+	procs, err := ioutil.ReadDir(chkutil.ProcPath())
+	if err != nil {
+		return owners, err
+	}
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := chkutil.ProcPath(proc.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		seen := make(map[uint64]bool)
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inodeStr := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			inode, err := strconv.ParseUint(inodeStr, 10, 64)
+			if err == nil && !seen[inode] {
+				seen[inode] = true
+				owners[inode] = append(owners[inode], pid)
+			}
+		}
+	}
+	return owners, nil
+}
+
+// WithOwners fills in the PIDs of the processes that own each socket
+func WithOwners(socks []Socket) ([]Socket, error) {
+	owners, err := SocketOwners()
+	if err != nil {
+		return socks, err
+	}
+	for i := range socks {
+		socks[i].PIDs = owners[socks[i].Inode]
+	}
+	return socks, nil
+}
+
+// Route is an entry in the kernel's IP routing table, from /proc/net/route or
+// /proc/net/ipv6_route
+type Route struct {
+	Interface   string
+	Destination net.IPNet
+	// unspecified (0.0.0.0 or ::) for directly connected networks
+	Gateway net.IP
+	Flags   uint64
+	Metric  uint64
+}
+
+// Default reports whether this is a default route, i.e. to 0.0.0.0/0 or ::/0
+func (route Route) Default() bool {
+	ones, _ := route.Destination.Mask.Size()
+	return ones == 0
+}
+
+// ParseRoutes parses the contents of /proc/net/route
+func ParseRoutes(data []byte) (routes []Route, err error) {
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[1:] {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) < 8 {
+			return routes, fmt.Errorf("Couldn't parse route line: %v", line)
+		}
+		dest, err := parseHexIP(fields[1])
+		if err != nil {
+			return routes, err
+		}
+		gateway, err := parseHexIP(fields[2])
+		if err != nil {
+			return routes, err
+		}
+		mask, err := parseHexIP(fields[7])
+		if err != nil {
+			return routes, err
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 64)
+		if err != nil {
+			return routes, fmt.Errorf("Invalid route flags: %v", fields[3])
+		}
+		metric, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return routes, fmt.Errorf("Invalid route metric: %v", fields[6])
+		}
+		routes = append(routes, Route{
+			Interface:   fields[0],
+			Destination: net.IPNet{IP: dest, Mask: net.IPMask(mask)},
+			Gateway:     gateway,
+			Flags:       flags,
+			Metric:      metric,
+		})
+	}
+	return routes, nil
+}
+
+// ParseIPv6Routes parses the contents of /proc/net/ipv6_route, whose
+// addresses are in network byte order, and which has no header
+func ParseIPv6Routes(data []byte) (routes []Route, err error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		// dest dest_prefixlen src src_prefixlen next_hop metric refcnt use
+		// flags iface
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) < 10 {
+			return routes, fmt.Errorf("Couldn't parse route line: %v", line)
+		}
+		dest, err := hex.DecodeString(fields[0])
+		if err != nil || len(dest) != net.IPv6len {
+			return routes, fmt.Errorf("Invalid address: %v", fields[0])
+		}
+		prefixLen, err := strconv.ParseUint(fields[1], 16, 8)
+		if err != nil || prefixLen > 128 {
+			return routes, fmt.Errorf("Invalid prefix length: %v", fields[1])
+		}
+		gateway, err := hex.DecodeString(fields[4])
+		if err != nil || len(gateway) != net.IPv6len {
+			return routes, fmt.Errorf("Invalid address: %v", fields[4])
+		}
+		metric, err := strconv.ParseUint(fields[5], 16, 64)
+		if err != nil {
+			return routes, fmt.Errorf("Invalid route metric: %v", fields[5])
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 64)
+		if err != nil {
+			return routes, fmt.Errorf("Invalid route flags: %v", fields[8])
+		}
+		routes = append(routes, Route{
+			Interface: fields[9],
+			Destination: net.IPNet{
+				IP:   net.IP(dest),
+				Mask: net.CIDRMask(int(prefixLen), 128),
+			},
+			Gateway: net.IP(gateway),
+			Flags:   flags,
+			Metric:  metric,
+		})
+	}
+	return routes, nil
+}
+
+// Routes reads the IPv4 and IPv6 routing tables. A missing IPv6 table (when
+// IPv6 is disabled) is skipped.
+func Routes() (routes []Route, err error) {
+	data, err := ioutil.ReadFile(chkutil.ProcSelfPath("net", "route"))
+	if err != nil {
+		return routes, err
+	}
+	if routes, err = ParseRoutes(data); err != nil {
+		return routes, err
+	}
+	data, err = ioutil.ReadFile(chkutil.ProcSelfPath("net", "ipv6_route"))
+	if os.IsNotExist(err) {
+		return routes, nil
+	} else if err != nil {
+		return routes, err
+	}
+	routes6, err := ParseIPv6Routes(data)
+	return append(routes, routes6...), err
+}
+
+// DefaultRoute returns the first default route that goes through a gateway,
+// preferring IPv4
+func DefaultRoute() (Route, error) {
+	routes, err := Routes()
+	if err != nil {
+		return Route{}, err
+	}
+	for _, route := range routes {
+		if route.Default() && !route.Gateway.IsUnspecified() {
+			return route, nil
+		}
+	}
+	return Route{}, fmt.Errorf("No default route")
+}
@@ -0,0 +1,142 @@
+package netstatus
+
+import (
+	"net"
+	"testing"
+)
+
+var procNetTCP = []byte(`  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 21830 1 0000000000000000 100 0 0 10 0
+   1: 0F02000A:0016 0202000A:C5D2 01 00000000:00000000 02:0009F3A0 00000000     0        0 31337 4 0000000000000000 20 4 31 10 -1
+`)
+
+var procNetTCP6 = []byte(`  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000    33        0 19201 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:1F90 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 19202 1 0000000000000000 100 0 0 10 0
+`)
+
+var procNetUnix = []byte(`Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 17204 /run/systemd/private
+0000000000000000: 00000003 00000000 00000000 0002 03 17210 @/org/kernel/udev/udevd
+0000000000000000: 00000003 00000000 00000000 0001 03 17299
+`)
+
+var procNetRoute = []byte(`Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	010200C0	0003	0	0	100	00000000	0	0	0
+eth0	000200C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+`)
+
+var procNetIPv6Route = []byte(`00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+`)
+
+func TestParseSockets(t *testing.T) {
+	t.Parallel()
+	socks, err := ParseSockets("tcp", procNetTCP)
+	if err != nil {
+		t.Fatalf("ParseSockets failed unexpectedly: %v", err)
+	} else if len(socks) != 2 {
+		t.Fatalf("ParseSockets: expected 2 sockets, got %v", len(socks))
+	}
+	listen, conn := socks[0], socks[1]
+	if !listen.LocalIP.Equal(net.ParseIP("127.0.0.1")) || listen.LocalPort != 3306 ||
+		!listen.Listening() || listen.UID != 999 || listen.Inode != 21830 {
+		t.Errorf("ParseSockets parsed a listening socket incorrectly: %+v", listen)
+	}
+	if !conn.RemoteIP.Equal(net.ParseIP("10.0.2.2")) || conn.RemotePort != 50642 ||
+		conn.State != "ESTABLISHED" || conn.Listening() {
+		t.Errorf("ParseSockets parsed a connected socket incorrectly: %+v", conn)
+	}
+	socks, err = ParseSockets("tcp6", procNetTCP6)
+	if err != nil {
+		t.Fatalf("ParseSockets failed unexpectedly on IPv6: %v", err)
+	} else if !socks[0].LocalIP.Equal(net.IPv6zero) || socks[0].LocalPort != 80 {
+		t.Errorf("ParseSockets parsed an IPv6 socket incorrectly: %+v", socks[0])
+	} else if !socks[1].LocalIP.Equal(net.IPv6loopback) {
+		t.Errorf("ParseSockets parsed ::1 incorrectly: %v", socks[1].LocalIP)
+	}
+	bad := []byte("header\n 0: 0100007F:0CEA 00000000:0000 ZZ 0:0 0:0 0 0 0 1\n")
+	if _, err := ParseSockets("tcp", bad); err == nil {
+		t.Error("ParseSockets accepted an unknown state")
+	}
+	udp := []byte("header\n 0: 00000000:0035 00000000:0000 07 0:0 0:0 0 0 0 1\n")
+	if socks, _ := ParseSockets("udp", udp); len(socks) != 1 || !socks[0].Listening() {
+		t.Errorf("ParseSockets didn't parse an unconnected UDP socket: %+v", socks)
+	}
+}
+
+func TestParseUnixSockets(t *testing.T) {
+	t.Parallel()
+	socks, err := ParseUnixSockets(procNetUnix)
+	if err != nil {
+		t.Fatalf("ParseUnixSockets failed unexpectedly: %v", err)
+	} else if len(socks) != 3 {
+		t.Fatalf("ParseUnixSockets: expected 3 sockets, got %v", len(socks))
+	}
+	if socks[0].Path != "/run/systemd/private" || !socks[0].Listening ||
+		socks[0].Type != "stream" {
+		t.Errorf("ParseUnixSockets parsed a listening socket incorrectly: %+v", socks[0])
+	}
+	if socks[1].Path != "@/org/kernel/udev/udevd" || socks[1].Type != "dgram" {
+		t.Errorf("ParseUnixSockets parsed an abstract socket incorrectly: %+v", socks[1])
+	}
+	if socks[2].Path != "" || socks[2].Listening {
+		t.Errorf("ParseUnixSockets parsed an unbound socket incorrectly: %+v", socks[2])
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	t.Parallel()
+	routes, err := ParseRoutes(procNetRoute)
+	if err != nil {
+		t.Fatalf("ParseRoutes failed unexpectedly: %v", err)
+	} else if len(routes) != 2 {
+		t.Fatalf("ParseRoutes: expected 2 routes, got %v", len(routes))
+	}
+	if !routes[0].Default() || !routes[0].Gateway.Equal(net.ParseIP("192.0.2.1")) ||
+		routes[0].Metric != 100 || routes[0].Interface != "eth0" {
+		t.Errorf("ParseRoutes parsed the default route incorrectly: %+v", routes[0])
+	}
+	if routes[1].Default() || routes[1].Destination.String() != "192.0.2.0/24" {
+		t.Errorf("ParseRoutes parsed a network route incorrectly: %+v", routes[1])
+	}
+	routes, err = ParseIPv6Routes(procNetIPv6Route)
+	if err != nil {
+		t.Fatalf("ParseIPv6Routes failed unexpectedly: %v", err)
+	} else if len(routes) != 2 {
+		t.Fatalf("ParseIPv6Routes: expected 2 routes, got %v", len(routes))
+	}
+	if !routes[0].Default() || !routes[0].Gateway.Equal(net.ParseIP("fe80::1")) {
+		t.Errorf("ParseIPv6Routes parsed the default route incorrectly: %+v", routes[0])
+	}
+	if routes[1].Destination.String() != "fe80::/64" || routes[1].Metric != 256 {
+		t.Errorf("ParseIPv6Routes parsed a network route incorrectly: %+v", routes[1])
+	}
+}
+
+func TestListeningSockets(t *testing.T) {
+	t.Parallel()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	socks, err := ListeningSockets("tcp")
+	if err != nil {
+		t.Fatalf("ListeningSockets failed unexpectedly: %v", err)
+	}
+	socks, err = WithOwners(socks)
+	if err != nil {
+		t.Fatalf("WithOwners failed unexpectedly: %v", err)
+	}
+	for _, sock := range socks {
+		if sock.LocalPort == port {
+			if len(sock.PIDs) < 1 {
+				t.Errorf("WithOwners didn't find the test's socket: %+v", sock)
+			}
+			return
+		}
+	}
+	t.Errorf("ListeningSockets didn't include port %v", port)
+}
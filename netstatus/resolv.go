@@ -0,0 +1,104 @@
+package netstatus
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+// ResolvConf is the resolver's configuration, from /etc/resolv.conf
+type ResolvConf struct {
+	Nameservers []string
+	// Search is the list of search domains. A domain line sets it to a single
+	// domain, and whichever of domain and search comes last wins.
+	Search []string
+	// Options maps each option to its value, e.g. ndots to 2, or to "" for
+	// options without one, like rotate
+	Options map[string]string
+}
+
+// ParseResolvConf parses the contents of /etc/resolv.conf. Lines it doesn't
+// understand are ignored, as they are by the resolver.
+func ParseResolvConf(data []byte) ResolvConf {
+	conf := ResolvConf{Options: make(map[string]string)}
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexAny(line, "#;"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			conf.Nameservers = append(conf.Nameservers, fields[1])
+		case "domain":
+			conf.Search = []string{fields[1]}
+		case "search":
+			conf.Search = fields[1:]
+		case "options":
+			for _, option := range fields[1:] {
+				name, value := option, ""
+				if i := strings.Index(option, ":"); i >= 0 {
+					name, value = option[:i], option[i+1:]
+				}
+				conf.Options[name] = value
+			}
+		}
+	}
+	return conf
+}
+
+// ReadResolvConf reads and parses /etc/resolv.conf
+func ReadResolvConf() (ResolvConf, error) {
+	data, err := ioutil.ReadFile(chkutil.HostPath("/etc/resolv.conf"))
+	if err != nil {
+		return ResolvConf{}, err
+	}
+	return ParseResolvConf(data), nil
+}
+
+// HostsEntry is a line of /etc/hosts
+type HostsEntry struct {
+	IP    string
+	Names []string
+}
+
+// ParseHosts parses the contents of /etc/hosts
+func ParseHosts(data []byte) (entries []HostsEntry) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		entries = append(entries, HostsEntry{fields[0], fields[1:]})
+	}
+	return entries
+}
+
+// HostsAddresses lists the addresses that entries map name to, in order.
+// Names are matched case insensitively.
+func HostsAddresses(entries []HostsEntry, name string) (addresses []string) {
+	for _, entry := range entries {
+		for _, entryName := range entry.Names {
+			if strings.EqualFold(entryName, name) {
+				addresses = append(addresses, entry.IP)
+				break
+			}
+		}
+	}
+	return addresses
+}
+
+// ReadHosts reads and parses /etc/hosts
+func ReadHosts() ([]HostsEntry, error) {
+	data, err := ioutil.ReadFile(chkutil.HostPath("/etc/hosts"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseHosts(data), nil
+}
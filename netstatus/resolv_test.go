@@ -0,0 +1,64 @@
+package netstatus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResolvConf(t *testing.T) {
+	t.Parallel()
+	data := []byte(`# Generated by NetworkManager
+domain corp.example.com
+search corp.example.com example.com
+nameserver 10.0.0.2
+nameserver 10.0.0.3 ; secondary
+nameserver
+options ndots:2 timeout:1
+options rotate
+sortlist 130.155.160.0/255.255.240.0
+`)
+	expected := ResolvConf{
+		Nameservers: []string{"10.0.0.2", "10.0.0.3"},
+		Search:      []string{"corp.example.com", "example.com"},
+		Options:     map[string]string{"ndots": "2", "timeout": "1", "rotate": ""},
+	}
+	if conf := ParseResolvConf(data); !reflect.DeepEqual(conf, expected) {
+		t.Errorf("Unexpected resolv.conf: %+v", conf)
+	}
+	conf := ParseResolvConf([]byte("search example.com\ndomain corp.example.com\n"))
+	if !reflect.DeepEqual(conf.Search, []string{"corp.example.com"}) {
+		t.Errorf("Expected the last domain line to win, got %v", conf.Search)
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	t.Parallel()
+	data := []byte(`127.0.0.1	localhost
+::1 localhost ip6-localhost # loopback
+# 10.0.0.9 db.example.com
+10.0.0.5 db.example.com db
+10.0.0.6 DB
+10.0.0.7
+`)
+	expected := []HostsEntry{
+		{"127.0.0.1", []string{"localhost"}},
+		{"::1", []string{"localhost", "ip6-localhost"}},
+		{"10.0.0.5", []string{"db.example.com", "db"}},
+		{"10.0.0.6", []string{"DB"}},
+	}
+	entries := ParseHosts(data)
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("Unexpected hosts entries: %+v", entries)
+	}
+	lookups := map[string][]string{
+		"localhost":      {"127.0.0.1", "::1"},
+		"db":             {"10.0.0.5", "10.0.0.6"},
+		"db.example.com": {"10.0.0.5"},
+		"web":            nil,
+	}
+	for name, addresses := range lookups {
+		if actual := HostsAddresses(entries, name); !reflect.DeepEqual(actual, addresses) {
+			t.Errorf("Unexpected addresses for %s: %v", name, actual)
+		}
+	}
+}
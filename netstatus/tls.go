@@ -0,0 +1,57 @@
+package netstatus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"time"
+)
+
+// PeerCertificates connects to addr (host:port) over TLS and returns the
+// certificate chain that it presents, leaf first. serverName is sent with SNI
+// to select the certificate; if it's empty, the host in addr is used. The
+// chain isn't verified, so that invalid certificates can still be inspected,
+// see VerifyChain.
+func PeerCertificates(addr, serverName string, timeout time.Duration) ([]*x509.Certificate, error) {
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		serverName = host
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) < 1 {
+		return certs, errors.New("No certificates presented by " + addr)
+	}
+	return certs, nil
+}
+
+// VerifyChain verifies that certs (leaf first, then intermediates) chain to
+// one of roots, and that the leaf is valid for serverName if it's given. If
+// roots is nil, the system's roots are used.
+func VerifyChain(certs []*x509.Certificate, serverName string, roots *x509.CertPool) error {
+	if len(certs) < 1 {
+		return errors.New("No certificates to verify")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
 	"os"
 
+	"github.com/CiscoCloud/distributive/checklists"
+	"github.com/CiscoCloud/distributive/chkutil"
 	"github.com/CiscoCloud/distributive/errutil"
+	"github.com/CiscoCloud/distributive/snapshot"
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
+	"github.com/ghodss/yaml"
 )
 
 const defaultVerbosity = log.WarnLevel
+const defaultPluginDir = "/etc/distributive.d/plugins/"
 
 // validateFlags ensures that all options passed via the command line are valid
 func validateFlags(file string, URL string, directory string) {
@@ -103,6 +109,65 @@ func getFlags() (f string, u string, d string, s bool) {
 			Name:  "no-cache",
 			Usage: "Don't use a cached version of a remote check, fetch it.",
 		},
+		cli.BoolFlag{
+			Name:  "remediate",
+			Usage: "Run the remediation of failing checks, then check them again",
+		},
+		cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "Report what --remediate would do, without doing it",
+		},
+		cli.StringFlag{
+			Name:  "plugins",
+			Value: defaultPluginDir,
+			Usage: "Register the executables in this directory as checks",
+		},
+		cli.StringFlag{
+			Name:  "root",
+			Value: "/",
+			Usage: "Check the filesystem mounted at this path instead of /",
+		},
+		cli.StringFlag{
+			Name:  "proc",
+			Value: "",
+			Usage: "Read procfs from this path (default: proc/ under --root)",
+		},
+		cli.StringFlag{
+			Name:  "sys",
+			Value: "",
+			Usage: "Read sysfs from this path (default: sys/ under --root)",
+		},
+		cli.StringFlag{
+			Name:  "state-dir",
+			Value: chkutil.DefaultStateDir,
+			Usage: "Keep state between runs (e.g. log file offsets) in this directory",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:  "snapshot",
+			Usage: "Print a checklist describing the current state of this host",
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "checksum",
+					Value: &cli.StringSlice{},
+					Usage: "Record the checksum of this file (may be repeated)",
+				},
+			},
+			Action: func(c *cli.Context) {
+				initializeLogrus(c.GlobalString("verbosity"))
+				chkutil.SetRoot(c.GlobalString("root"), c.GlobalString("proc"),
+					c.GlobalString("sys"))
+				data, err := yaml.Marshal(snapshot.Take(c.StringSlice("checksum")))
+				if err != nil {
+					log.WithFields(log.Fields{
+						"error": err.Error(),
+					}).Fatal("Couldn't write snapshot")
+				}
+				fmt.Print(string(data))
+				os.Exit(0)
+			},
+		},
 	}
 	var file string
 	var URL string
@@ -131,6 +196,11 @@ func getFlags() (f string, u string, d string, s bool) {
 			"stdin":     stdin,
 		}).Debug("Command line options")
 		useCache = !c.Bool("no-cache")
+		pluginDir = c.String("plugins")
+		checklists.DryRun = c.Bool("dry-run")
+		checklists.Remediate = c.Bool("remediate") || checklists.DryRun
+		chkutil.SetRoot(c.String("root"), c.String("proc"), c.String("sys"))
+		chkutil.SetStateDir(c.String("state-dir"))
 	}
 	app.Run(os.Args) // parse the arguments, execute app.Action
 	return file, URL, directory, stdin
@@ -0,0 +1,283 @@
+// procstatus provides information about the processes running on the host, by
+// reading /proc/PID directly.
+package procstatus
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/CiscoCloud/distributive/chkutil"
+)
+
+// Process is a snapshot of a running process
+type Process struct {
+	PID int
+	// Comm is the kernel's (possibly truncated) name for the process
+	Comm string
+	// Exe is the path of the executable, if it could be read. Reading it
+	// requires privileges for other users' processes.
+	Exe     string
+	Cmdline []string
+	// UID is the real user ID
+	UID int
+	// Unit is the systemd unit whose cgroup the process is in, if any
+	Unit string
+	Stat
+	// RSS is the process' resident set size, in bytes
+	RSS uint64
+}
+
+// ClockTicks is the number of clock ticks per second that /proc reports times
+// in (USER_HZ), which is 100 on every architecture Linux supports
+const ClockTicks = 100
+
+// Stat is the part of /proc/PID/stat that's used to describe processes
+type Stat struct {
+	// State is R (running), S (sleeping), D (uninterruptible sleep), Z
+	// (zombie), T (stopped), etc.
+	State   string
+	PPID    int
+	Threads int
+	// CPUTicks is the CPU time used in user and kernel mode, in clock ticks
+	CPUTicks uint64
+	// StartTicks is when the process started, in clock ticks after boot
+	StartTicks uint64
+}
+
+// ParseStat parses the contents of /proc/PID/stat
+func ParseStat(data []byte) (stat Stat, err error) {
+	// the command name is in parentheses, and may contain spaces and ")"
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return stat, fmt.Errorf("Couldn't find command name in stat")
+	}
+	// fields from the third, state, onwards
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return stat, fmt.Errorf("Too few fields in stat: %d", len(fields)+2)
+	}
+	field := func(n int) uint64 {
+		if err != nil {
+			return 0
+		}
+		var value uint64
+		if value, err = strconv.ParseUint(fields[n-3], 10, 64); err != nil {
+			err = fmt.Errorf("Invalid field %d in stat: %s", n, fields[n-3])
+		}
+		return value
+	}
+	stat.State = fields[0]
+	stat.PPID = int(field(4))
+	stat.CPUTicks = field(14) + field(15)
+	stat.Threads = int(field(20))
+	stat.StartTicks = field(22)
+	return stat, err
+}
+
+// Started is when the process started, given when the host booted
+func (stat Stat) Started(boot time.Time) time.Time {
+	return boot.Add(time.Duration(stat.StartTicks) * time.Second / ClockTicks)
+}
+
+// Name is the name of the process' executable, or its Comm if the executable
+// can't be read
+func (proc Process) Name() string {
+	if proc.Exe != "" {
+		return filepath.Base(proc.Exe)
+	}
+	return proc.Comm
+}
+
+// String describes the process like "nginx[1234]"
+func (proc Process) String() string {
+	return fmt.Sprintf("%s[%d]", proc.Name(), proc.PID)
+}
+
+// ParseStatus parses the contents of /proc/PID/status into a map of field
+// names to their (whitespace-trimmed) values
+func ParseStatus(data []byte) map[string]string {
+	status := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+		status[line[:colon]] = strings.TrimSpace(line[colon+1:])
+	}
+	return status
+}
+
+// unitSuffixes are the types of systemd unit that can contain processes
+var unitSuffixes = []string{".service", ".scope", ".socket", ".mount", ".swap"}
+
+// ParseCgroupUnit finds the systemd unit in the contents of /proc/PID/cgroup.
+// It uses systemd's hierarchy (name=systemd), or the unified hierarchy on
+// hosts that only have cgroup v2. The unit is the innermost path element with
+// a unit suffix, e.g. nginx.service in /system.slice/nginx.service.
+func ParseCgroupUnit(data []byte) string {
+	var path string
+	for _, line := range strings.Split(string(data), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		} else if fields[1] == "name=systemd" {
+			path = fields[2]
+			break
+		} else if fields[0] == "0" && fields[1] == "" {
+			path = fields[2]
+		}
+	}
+	elems := strings.Split(path, "/")
+	for i := len(elems) - 1; i >= 0; i-- {
+		for _, suffix := range unitSuffixes {
+			if strings.HasSuffix(elems[i], suffix) {
+				return elems[i]
+			}
+		}
+	}
+	return ""
+}
+
+// ReadProcess reads the process with this PID. Fields that need privileges to
+// read, like Exe, are left empty if they can't be.
+func ReadProcess(pid int) (proc Process, err error) {
+	dir := chkutil.ProcPath(strconv.Itoa(pid))
+	status, err := ioutil.ReadFile(filepath.Join(dir, "status"))
+	if err != nil {
+		return proc, err
+	}
+	fields := ParseStatus(status)
+	proc.PID = pid
+	proc.Comm = fields["Name"]
+	// Uid: real effective saved filesystem
+	uids := strings.Fields(fields["Uid"])
+	if len(uids) < 1 {
+		return proc, fmt.Errorf("Couldn't find UID of process %d", pid)
+	}
+	if proc.UID, err = strconv.Atoi(uids[0]); err != nil {
+		return proc, fmt.Errorf("Invalid UID of process %d: %s", pid, uids[0])
+	}
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		// the executable may have been replaced, e.g. by a package upgrade
+		proc.Exe = strings.TrimSuffix(exe, " (deleted)")
+	}
+	if cmdline, err := ioutil.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+		cmdline = bytes.TrimSuffix(cmdline, []byte{0})
+		if len(cmdline) > 0 {
+			proc.Cmdline = strings.Split(string(cmdline), "\x00")
+		}
+	}
+	if cgroup, err := ioutil.ReadFile(filepath.Join(dir, "cgroup")); err == nil {
+		proc.Unit = ParseCgroupUnit(cgroup)
+	}
+	stat, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return proc, err
+	}
+	if proc.Stat, err = ParseStat(stat); err != nil {
+		return proc, fmt.Errorf("Couldn't parse stat of process %d: %s", pid, err)
+	}
+	// kernel threads have no VmRSS
+	if rss := strings.Fields(fields["VmRSS"]); len(rss) == 2 && rss[1] == "kB" {
+		kb, err := strconv.ParseUint(rss[0], 10, 64)
+		if err != nil {
+			return proc, fmt.Errorf("Invalid RSS of process %d: %s", pid, rss[0])
+		}
+		proc.RSS = kb * 1024
+	}
+	return proc, nil
+}
+
+// PIDs lists the IDs of the running processes
+func PIDs() (pids []int, err error) {
+	entries, err := ioutil.ReadDir(chkutil.ProcPath())
+	if err != nil {
+		return pids, err
+	}
+	for _, entry := range entries {
+		if pid, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// Processes reads every running process, except those that exit while
+// they're being read
+func Processes() (procs []Process, err error) {
+	pids, err := PIDs()
+	if err != nil {
+		return procs, err
+	}
+	for _, pid := range pids {
+		proc, err := ReadProcess(pid)
+		if err != nil && exited(pid, err) {
+			continue
+		} else if err != nil {
+			return procs, err
+		}
+		procs = append(procs, proc)
+	}
+	return procs, nil
+}
+
+// exited reports whether an error reading a process was because it exited
+// while it was being read. Depending on the file, that gives ENOENT or ESRCH,
+// or a partial read that can't be parsed.
+func exited(pid int, err error) bool {
+	if pathErr, ok := err.(*os.PathError); ok && pathErr.Err == syscall.ESRCH {
+		return true
+	} else if os.IsNotExist(err) {
+		return true
+	}
+	_, err = os.Stat(chkutil.ProcPath(strconv.Itoa(pid)))
+	return os.IsNotExist(err)
+}
+
+// CountFDs counts the open file descriptors of the process with this PID.
+// This requires privileges for other users' processes.
+func CountFDs(pid int) (int, error) {
+	fds, err := ioutil.ReadDir(chkutil.ProcPath(strconv.Itoa(pid), "fd"))
+	return len(fds), err
+}
+
+// BootTime reads when the host booted, from /proc/stat
+func BootTime() (time.Time, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcPath("stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "btime" {
+			btime, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("Invalid boot time: %s", fields[1])
+			}
+			return time.Unix(btime, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Couldn't find boot time in /proc/stat")
+}
+
+// KernelLimit reads one of the kernel's limits on processes from
+// /proc/sys/kernel, e.g. pid_max or threads-max
+func KernelLimit(name string) (int, error) {
+	data, err := ioutil.ReadFile(chkutil.ProcPath("sys", "kernel", name))
+	if err != nil {
+		return 0, err
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid value of kernel.%s: %s", name, data)
+	}
+	return limit, nil
+}
@@ -0,0 +1,137 @@
+package procstatus
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+	data := []byte("Name:\tnginx\nState:\tS (sleeping)\nUid:\t33\t33\t33\t33\n" +
+		"VmRSS:\t    5120 kB\nThreads:\t1\n")
+	status := ParseStatus(data)
+	expected := map[string]string{
+		"Name":    "nginx",
+		"State":   "S (sleeping)",
+		"Uid":     "33\t33\t33\t33",
+		"VmRSS":   "5120 kB",
+		"Threads": "1",
+	}
+	for key, value := range expected {
+		if status[key] != value {
+			t.Errorf("Unexpected %s: %q", key, status[key])
+		}
+	}
+}
+
+func TestParseCgroupUnit(t *testing.T) {
+	t.Parallel()
+	cgroups := map[string]string{
+		// cgroup v1
+		"11:memory:/system.slice/nginx.service\n" +
+			"1:name=systemd:/system.slice/nginx.service\n": "nginx.service",
+		// a service that delegates to sub-cgroups
+		"1:name=systemd:/system.slice/docker.service/payload\n": "docker.service",
+		// cgroup v2
+		"0::/user.slice/user-1000.slice/session-2.scope\n": "session-2.scope",
+		// hybrid, which prefers systemd's own hierarchy
+		"1:name=systemd:/system.slice/sshd.service\n" +
+			"0::/system.slice/other.service\n": "sshd.service",
+		// not in a unit, e.g. in a container
+		"0::/\n": "",
+		"":       "",
+	}
+	for data, expected := range cgroups {
+		if actual := ParseCgroupUnit([]byte(data)); actual != expected {
+			t.Errorf("Unexpected unit in %q: %q", data, actual)
+		}
+	}
+}
+
+func TestReadProcess(t *testing.T) {
+	t.Parallel()
+	proc, err := ReadProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proc.PID != os.Getpid() || proc.UID != os.Getuid() {
+		t.Errorf("Unexpected PID or UID: %+v", proc)
+	}
+	if proc.Name() == "" || len(proc.Cmdline) < 1 || proc.Cmdline[0] != os.Args[0] {
+		t.Errorf("Unexpected name or command line: %+v", proc)
+	}
+	if proc.State != "R" || proc.PPID != os.Getppid() || proc.Threads < 1 || proc.RSS < 1 {
+		t.Errorf("Unexpected state, parent, threads or RSS: %+v", proc)
+	}
+	if _, err := ReadProcess(-1); err == nil {
+		t.Error("Expected an error reading a nonexistent process")
+	}
+}
+
+func TestExited(t *testing.T) {
+	t.Parallel()
+	esrch := &os.PathError{Op: "read", Path: "/proc/1/stat", Err: syscall.ESRCH}
+	parse := errors.New("Couldn't find UID of process")
+	if !exited(os.Getpid(), esrch) || !exited(-1, parse) {
+		t.Error("Expected errors from an exited process to be skipped")
+	}
+	if exited(os.Getpid(), parse) {
+		t.Error("Expected errors from a running process not to be skipped")
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	t.Parallel()
+	data := []byte("1234 (my (odd) name) S 1 1234 1234 0 -1 4194560 8000 0 12 0 " +
+		"250 130 0 0 20 0 4 0 56789 1048576000 1280 18446744073709551615\n")
+	expected := Stat{State: "S", PPID: 1, Threads: 4, CPUTicks: 380, StartTicks: 56789}
+	if stat, err := ParseStat(data); err != nil || stat != expected {
+		t.Errorf("Unexpected stat: %+v, %v", stat, err)
+	}
+	boot := time.Unix(1000000, 0)
+	if started := expected.Started(boot); !started.Equal(boot.Add(567890 * time.Millisecond)) {
+		t.Errorf("Unexpected start time: %v", started)
+	}
+	invalid := []string{"", "1234 (name S 1", "1234 (name) S 1 2 3", "1234 (name) S x" +
+		" 1234 1234 0 -1 4194560 8000 0 12 0 250 130 0 0 20 0 4 0 56789 0 0"}
+	for _, data := range invalid {
+		if _, err := ParseStat([]byte(data)); err == nil {
+			t.Errorf("Expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestProcesses(t *testing.T) {
+	t.Parallel()
+	procs, err := Processes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, proc := range procs {
+		found = found || proc.PID == os.Getpid()
+	}
+	if !found {
+		t.Errorf("Couldn't find this process among %d processes", len(procs))
+	}
+	if fds, err := CountFDs(os.Getpid()); err != nil || fds < 3 {
+		t.Errorf("Unexpected file descriptor count: %d, %v", fds, err)
+	}
+	boot, err := BootTime()
+	if err != nil {
+		t.Fatal(err)
+	} else if boot.After(time.Now()) || boot.Before(time.Unix(0, 0)) {
+		t.Errorf("Unexpected boot time: %v", boot)
+	}
+	for _, name := range []string{"pid_max", "threads-max"} {
+		if limit, err := KernelLimit(name); err != nil || limit < 1 {
+			t.Errorf("Unexpected %s: %d, %v", name, limit, err)
+		}
+	}
+	if _, err := KernelLimit("nonexistent"); err == nil {
+		t.Error("Expected an error reading a nonexistent limit")
+	}
+}
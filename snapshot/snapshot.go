@@ -0,0 +1,241 @@
+// Package snapshot inspects the host and generates a checklist that describes
+// its current state. The checklist is meant to be trimmed by hand and then used
+// as a baseline, to detect when other hosts (or this one) drift from it.
+package snapshot
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/checklists"
+	"github.com/CiscoCloud/distributive/chkutil"
+	"github.com/CiscoCloud/distributive/dockerstatus"
+	"github.com/CiscoCloud/distributive/fsstatus"
+	"github.com/CiscoCloud/distributive/netstatus"
+	"github.com/CiscoCloud/distributive/systemdstatus"
+	"github.com/CiscoCloud/distributive/tabular"
+	log "github.com/Sirupsen/logrus"
+	gosssystem "github.com/aelsabbahy/goss/system"
+	libcontaineruser "github.com/opencontainers/runc/libcontainer/user"
+)
+
+// section is one aspect of the host that can be snapshotted
+type section struct {
+	name  string
+	check func() ([]checklists.CheckYAML, error)
+}
+
+// newCheck is a shorthand for constructing a CheckYAML
+func newCheck(id string, params ...string) checklists.CheckYAML {
+	return checklists.CheckYAML{ID: id, Parameters: params}
+}
+
+// unitTypes are the kinds of systemd unit worth recording. Devices, mounts,
+// slices etc. are either too volatile or covered elsewhere.
+var unitTypes = []string{".service", ".socket", ".timer"}
+
+// units records the active systemd services, sockets and timers
+func units() (chks []checklists.CheckYAML, err error) {
+	if err := chkutil.RequireHost("SystemctlActive"); err != nil {
+		return chks, err
+	}
+	units, err := systemdstatus.ActiveUnits()
+	if err != nil {
+		return chks, err
+	}
+	for _, unit := range units {
+		for _, suffix := range unitTypes {
+			if strings.HasSuffix(unit, suffix) {
+				chks = append(chks, newCheck("SystemctlActive", unit))
+			}
+		}
+	}
+	return chks, nil
+}
+
+// ports records the listening TCP and UDP ports
+func ports() (chks []checklists.CheckYAML, err error) {
+	for _, protocol := range []string{"tcp", "udp"} {
+		ports, err := netstatus.ListeningPorts(protocol)
+		if err != nil {
+			return chks, err
+		}
+		var sorted []int
+		for _, port := range ports {
+			sorted = append(sorted, int(port))
+		}
+		sort.Ints(sorted)
+		id := map[string]string{"tcp": "PortTCP", "udp": "PortUDP"}[protocol]
+		for _, port := range sorted {
+			chks = append(chks, newCheck(id, strconv.Itoa(port)))
+		}
+	}
+	return chks, nil
+}
+
+// installedPackages lists the names of the installed packages, using
+// whichever package manager is available
+func installedPackages() ([]string, error) {
+	var cmd *exec.Cmd
+	switch gosssystem.DetectPackageManager() {
+	case "deb":
+		cmd = exec.Command("dpkg-query", "-W", "-f", "${Package}\n")
+	case "apk":
+		cmd = exec.Command("apk", "info")
+	case "pacman":
+		cmd = exec.Command("pacman", "-Qq")
+	default:
+		cmd = exec.Command("rpm", "-qa", "--qf", "%{NAME}\n")
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []string
+	for _, line := range tabular.Lines(string(out)) {
+		if pkg := strings.TrimSpace(line); pkg != "" {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs, nil
+}
+
+// packages records the installed packages
+func packages() (chks []checklists.CheckYAML, err error) {
+	if err := chkutil.RequireHost("Installed"); err != nil {
+		return chks, err
+	}
+	pkgs, err := installedPackages()
+	if err != nil {
+		return chks, err
+	}
+	for _, pkg := range pkgs {
+		chks = append(chks, newCheck("Installed", pkg))
+	}
+	return chks, nil
+}
+
+// usersAndGroups records the users and groups in /etc/passwd and /etc/group
+func usersAndGroups() (chks []checklists.CheckYAML, err error) {
+	users, err := libcontaineruser.ParsePasswdFile(chkutil.HostPath("/etc/passwd"))
+	if err != nil {
+		return chks, err
+	}
+	for _, user := range users {
+		chks = append(chks, newCheck("UserExists", user.Name))
+	}
+	groups, err := libcontaineruser.ParseGroupFile(chkutil.HostPath("/etc/group"))
+	if err != nil {
+		return chks, err
+	}
+	for _, group := range groups {
+		chks = append(chks, newCheck("GroupExists", group.Name))
+	}
+	return chks, nil
+}
+
+// containers records the running Docker containers, by image
+func containers() (chks []checklists.CheckYAML, err error) {
+	if err := chkutil.RequireHost("DockerRunning"); err != nil {
+		return chks, err
+	}
+	running, err := dockerstatus.RunningContainers()
+	if err != nil {
+		return chks, err
+	}
+	seen := make(map[string]bool)
+	for _, image := range running {
+		if image != "" && !seen[image] {
+			seen[image] = true
+			chks = append(chks, newCheck("DockerRunning", image))
+		}
+	}
+	return chks, nil
+}
+
+// mounts records the mounted filesystems that are backed by a block device
+// or a network share, skipping pseudo-filesystems like proc and cgroup
+func mounts() (chks []checklists.CheckYAML, err error) {
+	mounts, err := fsstatus.Mounts()
+	if err != nil {
+		return chks, err
+	}
+	for _, mount := range mounts {
+		if strings.HasPrefix(mount.Device, "/") || strings.Contains(mount.Device, ":/") {
+			chks = append(chks, newCheck("Mount", mount.Mountpoint, mount.FSType))
+		}
+	}
+	return chks, nil
+}
+
+// addresses records the IP addresses of each network interface
+func addresses() (chks []checklists.CheckYAML, err error) {
+	for _, iface := range netstatus.GetInterfaces() {
+		for _, ip := range netstatus.InterfaceIPs(iface.Name) {
+			if ip.To4() != nil {
+				chks = append(chks, newCheck("IP", iface.Name, ip.String()))
+			} else if ip.To16() != nil && !ip.Equal(net.IPv6loopback) {
+				chks = append(chks, newCheck("IP6", iface.Name, ip.String()))
+			}
+		}
+	}
+	return chks, nil
+}
+
+// checksums records the SHA256 checksums of the given files
+func checksums(paths []string) func() ([]checklists.CheckYAML, error) {
+	return func() (chks []checklists.CheckYAML, err error) {
+		for _, path := range paths {
+			hostPath := chkutil.HostPath(path)
+			if _, err := os.Stat(hostPath); err != nil {
+				return chks, err
+			}
+			chksum, err := fsstatus.Checksum("SHA256", chkutil.FileToBytes(hostPath))
+			if err != nil {
+				return chks, err
+			}
+			chks = append(chks, newCheck("Checksum", "SHA256", chksum, path))
+		}
+		return chks, nil
+	}
+}
+
+// Take inspects the host and returns a checklist of checks that currently
+// pass. The files at checksumPaths have their checksums recorded. Anything
+// that can't be inspected (e.g. because Docker isn't installed) is skipped
+// with a warning.
+func Take(checksumPaths []string) (chklst checklists.ChecklistYAML) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	chklst.Name = "Snapshot of " + hostname
+	sections := []section{
+		{"systemd units", units},
+		{"listening ports", ports},
+		{"packages", packages},
+		{"users and groups", usersAndGroups},
+		{"docker containers", containers},
+		{"mounts", mounts},
+		{"interface addresses", addresses},
+		{"checksums", checksums(checksumPaths)},
+	}
+	for _, sec := range sections {
+		log.Debug("Taking snapshot of " + sec.name)
+		chks, err := sec.check()
+		if err != nil {
+			log.WithFields(log.Fields{
+				"section": sec.name,
+				"error":   err.Error(),
+			}).Warn("Couldn't take snapshot, skipping")
+			continue
+		}
+		chklst.Checklist = append(chklst.Checklist, chks...)
+	}
+	return chklst
+}
@@ -0,0 +1,50 @@
+package snapshot
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/CiscoCloud/distributive/checklists"
+	_ "github.com/CiscoCloud/distributive/checks"
+	"github.com/ghodss/yaml"
+)
+
+func TestTake(t *testing.T) {
+	t.Parallel()
+	tmp, err := ioutil.TempFile("", "distributive-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString("snapshot")
+	tmp.Close()
+
+	chklstYAML := Take([]string{tmp.Name()})
+	data, err := yaml.Marshal(chklstYAML)
+	if err != nil {
+		t.Fatalf("Couldn't marshal snapshot: %v", err)
+	}
+	chklst, err := checklists.FromBytes(data)
+	if err != nil {
+		t.Fatalf("Couldn't parse snapshot: %v\n%s", err, data)
+	}
+	found := map[string]bool{}
+	for _, chk := range chklst.Checks {
+		found[chk.ID()] = true
+	}
+	for _, id := range []string{"UserExists", "GroupExists", "Checksum"} {
+		if !found[id] {
+			t.Errorf("Snapshot didn't include any %s checks:\n%s", id, data)
+		}
+	}
+	// the host hasn't changed since the snapshot, so these should pass
+	for _, chk := range chklst.Checks {
+		if chk.ID() != "Checksum" && chk.ID() != "UserExists" {
+			continue
+		}
+		if code, msg, err := chk.Status(); code != 0 || err != nil {
+			t.Errorf("Check from snapshot failed: %s %v: %s", chk.ID(), msg, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package systemdstatus
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/CiscoCloud/distributive/tabular"
+)
+
+// LastLogLines returns up to the last n lines that a unit logged to the
+// journal, without their metadata
+func LastLogLines(unit string, n int) (lines []string, err error) {
+	cmd := exec.Command("journalctl", "--no-pager", "--output=cat",
+		"--unit="+unitName(unit), "--lines="+strconv.Itoa(n))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return lines, errors.New(err.Error() + ": output: " + string(out))
+	}
+	for _, line := range tabular.Lines(string(out)) {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// Priorities are the journal's message priorities, from most to least severe.
+// A priority's index is its syslog level.
+var Priorities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// JournalMessages returns the messages logged since the given time with at
+// least the given priority (e.g. "err" includes crit, alert and emerg). If
+// unit isn't empty, only that unit's messages are returned.
+func JournalMessages(unit, priority string, since time.Time) (msgs []string, err error) {
+	args := []string{"--no-pager", "--output=cat", "--priority=" + priority,
+		"--since=" + since.Format("2006-01-02 15:04:05")}
+	if unit != "" {
+		args = append(args, "--unit="+unitName(unit))
+	}
+	cmd := exec.Command("journalctl", args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return msgs, errors.New(err.Error() + ": output: " + string(out))
+	}
+	for _, line := range tabular.Lines(string(out)) {
+		if line != "" && line != "-- No entries --" {
+			msgs = append(msgs, line)
+		}
+	}
+	return msgs, nil
+}
@@ -0,0 +1,118 @@
+package systemdstatus
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/CiscoCloud/distributive/tabular"
+)
+
+/// systemctl fallback
+//
+// When systemd's bus can't be reached (e.g. distributive is running in a
+// container that only has the systemctl binary), its state is scraped from
+// systemctl's output instead.
+
+// systemctlProperties parses the output of `systemctl show`. Unlike the
+// properties that come over D-Bus, every value is a string.
+func systemctlProperties(name string) (map[string]interface{}, error) {
+	props := make(map[string]interface{})
+	cmd := exec.Command("systemctl", "show", "--no-pager", name)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return props, errors.New(err.Error() + ": output: " + string(out))
+	}
+	for _, line := range tabular.Lines(string(out)) {
+		if i := strings.Index(line, "="); i > 0 {
+			props[line[:i]] = line[i+1:]
+		}
+	}
+	return props, nil
+}
+
+// systemctlUnits returns the names of all units in the given state, e.g.
+// "active" or "failed"
+func systemctlUnits(state string) (units []string, err error) {
+	cmd := exec.Command("systemctl", "--no-pager", "--no-legend", "--plain",
+		"list-units", "--state="+state)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return units, errors.New(err.Error() + ": output: " + string(out))
+	}
+	for _, line := range tabular.Lines(string(out)) {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			units = append(units, fields[0])
+		}
+	}
+	return units, nil
+}
+
+// systemctlFailedUnits returns the units with ActiveState=failed
+func systemctlFailedUnits() (units []Unit, err error) {
+	names, err := systemctlUnits("failed")
+	if err != nil {
+		return units, err
+	}
+	for _, name := range names {
+		props, err := systemctlProperties(name)
+		if err != nil {
+			return units, err
+		}
+		units = append(units, unitOf(name, props))
+	}
+	return units, nil
+}
+
+// systemctlListeningSockets returns the LISTENING column of
+// `systemctl list-sockets`
+func systemctlListeningSockets() (socks []string, err error) {
+	out, err := exec.Command("systemctl", "list-sockets").CombinedOutput()
+	if err != nil {
+		return socks, errors.New(err.Error() + ": output: " + string(out))
+	}
+	table := tabular.ProbabalisticSplit(string(out))
+	return tabular.GetColumnByHeader("LISTENING", table), nil
+}
+
+// systemctlTimers returns the UNIT column of `systemctl list-timers`
+func systemctlTimers(all bool) (timers []string, err error) {
+	cmd := exec.Command("systemctl", "list-timers")
+	if all {
+		cmd = exec.Command("systemctl", "list-timers", "--all")
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return timers, errors.New(err.Error() + ": output: " + string(out))
+	}
+	// last three lines are junk
+	lines := tabular.Lines(string(out))
+	if len(lines) <= 3 {
+		msg := fmt.Sprint(cmd.Args) + " didn't output enough lines"
+		return timers, errors.New(msg)
+	}
+	table := tabular.SeparateOnAlignment(tabular.Unlines(lines[:len(lines)-3]))
+	column := tabular.GetColumnByHeader("UNIT", table)
+	return column, nil
+}
+
+// systemctlUnitFileStatuses returns the first two columns of
+// `systemctl list-unit-files`
+func systemctlUnitFileStatuses() (units, statuses []string, err error) {
+	cmd := exec.Command("systemctl", "--no-pager", "list-unit-files")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		err2 := errors.New(err.Error() + ": output: " + string(out))
+		return units, statuses, err2
+	}
+	table := tabular.ProbabalisticSplit(string(out))
+	units = tabular.GetColumnNoHeader(0, table)
+	statuses = tabular.GetColumnNoHeader(1, table)
+	// last two are empty line and junk statistics we don't care about
+	if len(units) <= 3 || len(statuses) <= 3 {
+		msg := fmt.Sprint(cmd.Args) + " didn't output enough lines"
+		return units, statuses, errors.New(msg)
+	}
+	return units[:len(units)-2], statuses[:len(statuses)-2], nil
+}
@@ -1,93 +1,355 @@
-// fsstatus provides utility functions for querying several aspects of systemd's
-// status, especially as pertains to monitoring.
+// systemdstatus provides utility functions for querying several aspects of
+// systemd's status, especially as pertains to monitoring. It asks systemd
+// directly over D-Bus (org.freedesktop.systemd1), and falls back to parsing
+// systemctl's output when the bus isn't available.
 package systemdstatus
 
 import (
-	"errors"
 	"fmt"
-	"github.com/CiscoCloud/distributive/tabular"
-	"os/exec"
+	"math"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/dbus"
 )
 
-// ServiceLoaded returns whether or not the given systemd service has
-// LoadState=loaded
-func ServiceLoaded(name string) (bool, error) {
-	cmd := exec.Command("systemctl", "show", "-p", "LoadState", name)
-	out, err := cmd.CombinedOutput()
+// Systemd is the part of systemd's D-Bus API that systemdstatus uses. It's
+// satisfied by go-systemd's *dbus.Conn.
+type Systemd interface {
+	ListUnits() ([]dbus.UnitStatus, error)
+	ListUnitFiles() ([]dbus.UnitFile, error)
+	GetUnitProperties(unit string) (map[string]interface{}, error)
+	GetUnitTypeProperties(unit, unitType string) (map[string]interface{}, error)
+	Close()
+}
+
+// Unit is the state of a systemd unit
+type Unit struct {
+	Name          string
+	Description   string
+	LoadState     string
+	ActiveState   string
+	SubState      string
+	UnitFileState string
+	// Result is why the unit last stopped, e.g. "exit-code" or "timeout". Only
+	// some types of unit have it, like services, mounts and timers.
+	Result string
+}
+
+// unitSuffixes are the types of systemd unit. A name without one of these
+// suffixes is taken to be a service, like systemctl does.
+var unitSuffixes = []string{
+	".service", ".socket", ".device", ".mount", ".automount", ".swap",
+	".target", ".path", ".timer", ".slice", ".scope",
+}
+
+// connect opens a connection to systemd's bus
+func connect() (Systemd, error) {
+	conn, err := dbus.New()
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// unitName adds a .service suffix to names that don't have a unit suffix
+func unitName(name string) string {
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+// unitType is the D-Bus interface name for a unit's type, e.g. "Service"
+func unitType(name string) string {
+	suffix := strings.TrimPrefix(filepath.Ext(name), ".")
+	return strings.Title(suffix)
+}
+
+// StringProperty returns a property as a string, however it was encoded.
+// Booleans are "yes" or "no", like in `systemctl show`.
+func StringProperty(props map[string]interface{}, key string) string {
+	switch value := props[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		if value {
+			return "yes"
+		}
+		return "no"
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// NumberProperty returns a numeric property. ok is false if the property isn't
+// a number or isn't set, which systemd represents with the largest uint64
+// (e.g. MemoryCurrent when memory accounting is off).
+func NumberProperty(props map[string]interface{}, key string) (n float64, ok bool) {
+	switch value := props[key].(type) {
+	case uint64:
+		return float64(value), value != math.MaxUint64
+	case uint32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case string:
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// timestampLayout is how systemctl formats timestamps
+const timestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// TimeProperty returns a timestamp property, e.g. ActiveEnterTimestamp. Over
+// D-Bus, these are microseconds since the epoch. ok is false if the property
+// isn't set, e.g. because the unit has never been active.
+func TimeProperty(props map[string]interface{}, key string) (t time.Time, ok bool) {
+	switch value := props[key].(type) {
+	case uint64:
+		if value == 0 {
+			return t, false
+		}
+		return time.Unix(0, int64(value)*int64(time.Microsecond)), true
+	case string:
+		t, err := time.Parse(timestampLayout, value)
+		return t, err == nil
+	}
+	return t, false
+}
+
+// unitProperties gets the properties of a unit, including those specific to
+// its type (e.g. MainPID for services) when it's loaded
+func unitProperties(conn Systemd, name string) (map[string]interface{}, error) {
+	name = unitName(name)
+	props, err := conn.GetUnitProperties(name)
+	if err != nil {
+		return props, err
+	}
+	if StringProperty(props, "LoadState") != "loaded" {
+		return props, nil
+	}
+	typeProps, err := conn.GetUnitTypeProperties(name, unitType(name))
 	if err != nil {
-		return false, errors.New(err.Error() + ": output: " + string(out))
+		return props, err
 	}
-	return strings.Contains(string(out), "LoadState=loaded"), nil
+	for key, value := range typeProps {
+		props[key] = value
+	}
+	return props, nil
+}
+
+// Properties returns all of the properties of a unit, keyed by name, as
+// listed by `systemctl show`. Values have their D-Bus types, or are strings
+// if systemd's bus isn't available.
+func Properties(name string) (map[string]interface{}, error) {
+	conn, err := connect()
+	if err != nil {
+		return systemctlProperties(unitName(name))
+	}
+	defer conn.Close()
+	return unitProperties(conn, name)
+}
+
+// unitOf fills a Unit from a unit's properties
+func unitOf(name string, props map[string]interface{}) Unit {
+	return Unit{
+		Name:          unitName(name),
+		Description:   StringProperty(props, "Description"),
+		LoadState:     StringProperty(props, "LoadState"),
+		ActiveState:   StringProperty(props, "ActiveState"),
+		SubState:      StringProperty(props, "SubState"),
+		UnitFileState: StringProperty(props, "UnitFileState"),
+		Result:        StringProperty(props, "Result"),
+	}
+}
+
+// GetUnit returns the state of the named unit. Units that don't exist have
+// the LoadState "not-found".
+func GetUnit(name string) (Unit, error) {
+	props, err := Properties(name)
+	if err != nil {
+		return Unit{}, err
+	}
+	return unitOf(name, props), nil
 }
 
 // ServiceLoaded returns whether or not the given systemd service has
+// LoadState=loaded
+func ServiceLoaded(name string) (bool, error) {
+	unit, err := GetUnit(name)
+	return unit.LoadState == "loaded", err
+}
+
+// ServiceActive returns whether or not the given systemd service has
 // ActiveState=active
 func ServiceActive(name string) (bool, error) {
-	cmd := exec.Command("systemctl", "show", "-p", "ActiveState", name)
-	out, err := cmd.CombinedOutput()
+	unit, err := GetUnit(name)
+	return unit.ActiveState == "active", err
+}
+
+// activeUnits lists the units with ActiveState=active
+func activeUnits(conn Systemd) (units []string, err error) {
+	statuses, err := conn.ListUnits()
 	if err != nil {
-		return false, errors.New(err.Error() + ": output: " + string(out))
+		return units, err
+	}
+	for _, status := range statuses {
+		if status.ActiveState == "active" {
+			units = append(units, status.Name)
+		}
 	}
-	return strings.Contains(string(out), "ActiveState=active"), nil
+	return units, nil
 }
 
-// ListeningSockets returns a list of all sockets in the "LISTENING" state
+// ActiveUnits returns the names of all units with ActiveState=active
+func ActiveUnits() (units []string, err error) {
+	conn, err := connect()
+	if err != nil {
+		return systemctlUnits("active")
+	}
+	defer conn.Close()
+	return activeUnits(conn)
+}
+
+// failedUnits lists the units with ActiveState=failed
+func failedUnits(conn Systemd) (units []Unit, err error) {
+	statuses, err := conn.ListUnits()
+	if err != nil {
+		return units, err
+	}
+	for _, status := range statuses {
+		if status.ActiveState != "failed" {
+			continue
+		}
+		props, err := unitProperties(conn, status.Name)
+		if err != nil {
+			return units, err
+		}
+		units = append(units, unitOf(status.Name, props))
+	}
+	return units, nil
+}
+
+// FailedUnits returns the units with ActiveState=failed
+func FailedUnits() ([]Unit, error) {
+	conn, err := connect()
+	if err != nil {
+		return systemctlFailedUnits()
+	}
+	defer conn.Close()
+	return failedUnits(conn)
+}
+
+// listenAddresses extracts the addresses from a socket's Listen property,
+// which is an array of (type, address) pairs
+func listenAddresses(listen interface{}) (addrs []string) {
+	var pairs []interface{}
+	switch value := listen.(type) {
+	case [][]interface{}:
+		for _, pair := range value {
+			pairs = append(pairs, pair)
+		}
+	case []interface{}:
+		pairs = value
+	}
+	for _, pair := range pairs {
+		if fields, ok := pair.([]interface{}); ok && len(fields) == 2 {
+			if addr, ok := fields[1].(string); ok {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+// listeningSockets lists the addresses of active socket units
+func listeningSockets(conn Systemd) (socks []string, err error) {
+	statuses, err := conn.ListUnits()
+	if err != nil {
+		return socks, err
+	}
+	for _, status := range statuses {
+		if !strings.HasSuffix(status.Name, ".socket") || status.ActiveState != "active" {
+			continue
+		}
+		props, err := conn.GetUnitTypeProperties(status.Name, "Socket")
+		if err != nil {
+			return socks, err
+		}
+		socks = append(socks, listenAddresses(props["Listen"])...)
+	}
+	return socks, nil
+}
+
+// ListeningSockets returns the addresses (paths, ports, etc.) that active
+// systemd socket units are listening on
 func ListeningSockets() (socks []string, err error) {
-	out, err := exec.Command("systemctl", "list-sockets").CombinedOutput()
+	conn, err := connect()
 	if err != nil {
-		return socks, errors.New(err.Error() + ": output: " + string(out))
+		return systemctlListeningSockets()
 	}
-	table := tabular.ProbabalisticSplit(string(out))
-	return tabular.GetColumnByHeader("LISTENING", table), nil
+	defer conn.Close()
+	return listeningSockets(conn)
 }
 
-// Timers returns a list of the active systemd timers, as found under the
-// UNIT column of `systemctl list-timers`. It can optionally list all timers.
-func Timers(all bool) (timers []string, err error) {
-	cmd := exec.Command("systemctl", "list-timers")
-	if all {
-		cmd = exec.Command("systemctl", "list-timers", "--all")
+// timers lists the loaded timer units, optionally including inactive ones
+func timers(conn Systemd, all bool) (timers []string, err error) {
+	statuses, err := conn.ListUnits()
+	if err != nil {
+		return timers, err
+	}
+	for _, status := range statuses {
+		if !strings.HasSuffix(status.Name, ".timer") {
+			continue
+		} else if all || status.ActiveState == "active" {
+			timers = append(timers, status.Name)
+		}
+	}
+	return timers, nil
+}
+
+// Timers returns a list of the active systemd timers, like
+// `systemctl list-timers`. It can optionally list all loaded timers.
+func Timers(all bool) ([]string, error) {
+	conn, err := connect()
+	if err != nil {
+		return systemctlTimers(all)
 	}
-	out, err := cmd.CombinedOutput()
+	defer conn.Close()
+	return timers(conn, all)
+}
+
+// unitFileStatuses lists the unit files and their enablement states
+func unitFileStatuses(conn Systemd) (units, statuses []string, err error) {
+	files, err := conn.ListUnitFiles()
 	if err != nil {
-		return timers, errors.New(err.Error() + ": output: " + string(out))
+		return units, statuses, err
 	}
-	// last three lines are junk
-	lines := tabular.Lines(string(out))
-	if len(lines) <= 3 {
-		msg := fmt.Sprint(cmd.Args) + " didn't output enough lines"
-		return timers, errors.New(msg)
+	for _, file := range files {
+		units = append(units, filepath.Base(file.Path))
+		statuses = append(statuses, file.Type)
 	}
-	table := tabular.SeparateOnAlignment(tabular.Unlines(lines[:len(lines)-3]))
-	column := tabular.GetColumnByHeader("UNIT", table)
-	return column, nil
+	return units, statuses, nil
 }
 
 // UnitFileStatuses returns a list of all unit files with their current status,
 // as shown by `systemctl list-unit-files`.
 func UnitFileStatuses() (units, statuses []string, err error) {
-	cmd := exec.Command("systemctl", "--no-pager", "list-unit-files")
-	out, err := cmd.CombinedOutput()
+	conn, err := connect()
 	if err != nil {
-		err2 := errors.New(err.Error() + ": output: " + string(out))
-		return units, statuses, err2
-	}
-	table := tabular.ProbabalisticSplit(string(out))
-	units = tabular.GetColumnNoHeader(0, table)
-	// last two are empty line and junk statistics we don't care about
-	if len(units) <= 3 {
-		msg := fmt.Sprint(cmd.Args) + " didn't output enough lines"
-		return units, statuses, errors.New(msg)
-	}
-	cmd = exec.Command("systemctl", "--no-pager", "list-unit-files")
-	table = tabular.ProbabalisticSplit(string(out))
-	statuses = tabular.GetColumnNoHeader(1, table)
-	// last two are empty line and junk statistics we don't care about
-	if len(statuses) <= 3 {
-		msg := fmt.Sprint(cmd.Args) + " didn't output enough lines"
-		return units, statuses, errors.New(msg)
-	}
-	return units[:len(units)-2], statuses[:len(statuses)-2], nil
-
+		return systemctlUnitFileStatuses()
+	}
+	defer conn.Close()
+	return unitFileStatuses(conn)
 }
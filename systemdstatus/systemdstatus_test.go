@@ -1,9 +1,13 @@
 package systemdstatus
 
 import (
+	"errors"
 	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/coreos/go-systemd/dbus"
 )
 
 var dummyServices = []string{"foo", "bar", "ipsum lorem", "541"}
@@ -83,3 +87,166 @@ func TestUnitFileStatuses(t *testing.T) {
 		}
 	}
 }
+
+// fakeSystemd stands in for systemd's bus
+type fakeSystemd struct {
+	units     []dbus.UnitStatus
+	files     []dbus.UnitFile
+	props     map[string]map[string]interface{}
+	typeProps map[string]map[string]interface{}
+}
+
+func (fake fakeSystemd) ListUnits() ([]dbus.UnitStatus, error) {
+	return fake.units, nil
+}
+
+func (fake fakeSystemd) ListUnitFiles() ([]dbus.UnitFile, error) {
+	return fake.files, nil
+}
+
+func (fake fakeSystemd) GetUnitProperties(unit string) (map[string]interface{}, error) {
+	props, ok := fake.props[unit]
+	if !ok {
+		return map[string]interface{}{
+			"LoadState": "not-found", "ActiveState": "inactive",
+		}, nil
+	}
+	copied := make(map[string]interface{})
+	for key, value := range props {
+		copied[key] = value
+	}
+	return copied, nil
+}
+
+func (fake fakeSystemd) GetUnitTypeProperties(unit, unitType string) (map[string]interface{}, error) {
+	if props, ok := fake.typeProps[unit+" "+unitType]; ok {
+		return props, nil
+	}
+	return nil, errors.New("No such interface: " + unitType)
+}
+
+func (fake fakeSystemd) Close() {}
+
+var fake = fakeSystemd{
+	units: []dbus.UnitStatus{
+		{Name: "sshd.service", LoadState: "loaded", ActiveState: "active"},
+		{Name: "docker.socket", LoadState: "loaded", ActiveState: "active"},
+		{Name: "old.socket", LoadState: "loaded", ActiveState: "inactive"},
+		{Name: "logrotate.timer", LoadState: "loaded", ActiveState: "active"},
+		{Name: "fstrim.timer", LoadState: "loaded", ActiveState: "inactive"},
+		{Name: "backup.service", LoadState: "loaded", ActiveState: "failed"},
+	},
+	files: []dbus.UnitFile{
+		{Path: "/usr/lib/systemd/system/sshd.service", Type: "enabled"},
+		{Path: "/usr/lib/systemd/system/dbus.service", Type: "static"},
+	},
+	props: map[string]map[string]interface{}{
+		"sshd.service": {
+			"Description":   "OpenSSH Daemon",
+			"LoadState":     "loaded",
+			"ActiveState":   "active",
+			"SubState":      "running",
+			"UnitFileState": "enabled",
+		},
+		"backup.service": {
+			"LoadState":   "loaded",
+			"ActiveState": "failed",
+			"SubState":    "failed",
+		},
+	},
+	typeProps: map[string]map[string]interface{}{
+		"sshd.service Service":   {"MainPID": uint32(412), "Result": "success"},
+		"backup.service Service": {"Result": "exit-code"},
+		"docker.socket Socket": {
+			"Listen": [][]interface{}{{"Stream", "/var/run/docker.sock"}},
+		},
+	},
+}
+
+func TestUnitProperties(t *testing.T) {
+	t.Parallel()
+	props, err := unitProperties(fake, "sshd")
+	if err != nil {
+		t.Fatalf("unitProperties failed unexpectedly: %v", err)
+	} else if props["MainPID"] != uint32(412) {
+		t.Errorf("unitProperties didn't include type properties: %v", props)
+	}
+	unit := unitOf("sshd", props)
+	expected := Unit{
+		"sshd.service", "OpenSSH Daemon", "loaded", "active", "running", "enabled", "success",
+	}
+	if unit != expected {
+		t.Errorf("unitOf: expected %+v, got %+v", expected, unit)
+	}
+	props, err = unitProperties(fake, "missing.service")
+	if err != nil {
+		t.Errorf("unitProperties failed on a missing unit: %v", err)
+	} else if unit := unitOf("missing.service", props); unit.LoadState != "not-found" {
+		t.Errorf("Missing unit had the wrong LoadState: %+v", unit)
+	}
+}
+
+func TestUnitLists(t *testing.T) {
+	t.Parallel()
+	active, _ := activeUnits(fake)
+	expected := []string{"sshd.service", "docker.socket", "logrotate.timer"}
+	if !reflect.DeepEqual(active, expected) {
+		t.Errorf("activeUnits: expected %v, got %v", expected, active)
+	}
+	socks, _ := listeningSockets(fake)
+	if !reflect.DeepEqual(socks, []string{"/var/run/docker.sock"}) {
+		t.Errorf("listeningSockets returned %v", socks)
+	}
+	for all, expected := range map[bool][]string{
+		false: {"logrotate.timer"},
+		true:  {"logrotate.timer", "fstrim.timer"},
+	} {
+		if actual, _ := timers(fake, all); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("timers(%v): expected %v, got %v", all, expected, actual)
+		}
+	}
+	failed, _ := failedUnits(fake)
+	if len(failed) != 1 || failed[0].Name != "backup.service" || failed[0].Result != "exit-code" {
+		t.Errorf("failedUnits returned %+v", failed)
+	}
+	units, statuses, _ := unitFileStatuses(fake)
+	if !reflect.DeepEqual(units, []string{"sshd.service", "dbus.service"}) ||
+		!reflect.DeepEqual(statuses, []string{"enabled", "static"}) {
+		t.Errorf("unitFileStatuses returned %v, %v", units, statuses)
+	}
+}
+
+func TestPropertyConversions(t *testing.T) {
+	t.Parallel()
+	props := map[string]interface{}{
+		"CanStart":              true,
+		"NRestarts":             uint32(4),
+		"ExecMainStatus":        int32(-1),
+		"MemoryCurrent":         ^uint64(0),
+		"TasksCurrent":          "12",
+		"ActiveEnterTimestamp":  uint64(1500000000 * 1000000),
+		"InactiveExitTimestamp": "Fri 2017-07-14 02:40:00 UTC",
+		"StateChangeTimestamp":  uint64(0),
+	}
+	if str := StringProperty(props, "CanStart"); str != "yes" {
+		t.Errorf("StringProperty: expected yes, got %v", str)
+	}
+	for key, expected := range map[string]float64{
+		"NRestarts": 4, "ExecMainStatus": -1, "TasksCurrent": 12,
+	} {
+		if n, ok := NumberProperty(props, key); !ok || n != expected {
+			t.Errorf("NumberProperty(%v): expected %v, got %v, %v", key, expected, n, ok)
+		}
+	}
+	if _, ok := NumberProperty(props, "MemoryCurrent"); ok {
+		t.Error("NumberProperty didn't treat the largest uint64 as unset")
+	}
+	for _, key := range []string{"ActiveEnterTimestamp", "InactiveExitTimestamp"} {
+		if ts, ok := TimeProperty(props, key); !ok || ts.Unix() != 1500000000 {
+			t.Errorf("TimeProperty(%v): expected 1500000000, got %v, %v", key, ts.Unix(), ok)
+		}
+	}
+	if _, ok := TimeProperty(props, "StateChangeTimestamp"); ok {
+		t.Error("TimeProperty didn't treat a zero timestamp as unset")
+	}
+}